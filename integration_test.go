@@ -93,6 +93,8 @@ func TestBackupAndRestore(t *testing.T) {
 		}
 	}()
 
+	backup.TrustOnFirstUse = true
+	defer func() { backup.TrustOnFirstUse = false }()
 	backup.ProcessConfiguration(configsDir, backupDir, []string{"TestApp"}, true, true, 1, false, "")
 
 	latestVersion, _, err := backup.GetLatestVersionPath(backupDir)
@@ -2,17 +2,48 @@ package interfaces
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type OutputHandler func(line string)
 
+// CommandOptions controls how a command runs. The zero value runs it in the
+// current directory with the inherited environment and no timeout.
+type CommandOptions struct {
+	// Timeout, when positive, kills the command and every process it started
+	// once it has run for this long.
+	Timeout time.Duration
+	// Dir is the working directory of the command.
+	Dir string
+	// Env holds KEY=VALUE pairs added to the inherited environment.
+	Env []string
+	// Stdin, when set, is the standard input of the command.
+	Stdin io.Reader
+	// Stdout, when set, receives the standard output of the command instead
+	// of the stdout handler.
+	Stdout io.Writer
+}
+
+// outputDrainDelay bounds how long ExecuteWithOptions keeps reading output
+// after the command exited, since a background process it started may keep the
+// output open for as long as it runs.
+const outputDrainDelay = 250 * time.Millisecond
+
+// ErrCommandTimeout is returned when a command was killed after its timeout.
+var ErrCommandTimeout = errors.New("command timed out")
+
 type CommandExecutor interface {
 	Execute(commandLine string, stdout, stderr io.Writer) bool
 	ExecuteWithCallback(commandLine string, stdoutHandler, stderrHandler OutputHandler) bool
+	ExecuteWithOptions(commandLine string, opts CommandOptions, stdoutHandler, stderrHandler OutputHandler) error
 }
 
 type OsCommandExecutor struct{}
@@ -27,61 +58,82 @@ func (e *OsCommandExecutor) Execute(commandLine string, stdout, stderr io.Writer
 }
 
 func (e *OsCommandExecutor) ExecuteWithCallback(commandLine string, stdoutHandler, stderrHandler OutputHandler) bool {
-	cmd := exec.Command("bash", "-c", commandLine)
+	return e.ExecuteWithOptions(commandLine, CommandOptions{}, stdoutHandler, stderrHandler) == nil
+}
 
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		fmt.Printf("Error creating stdout pipe: %v\n", err)
-		return false
+// ExecuteWithOptions runs a command line through bash, passing every line of
+// output to the handlers. The command runs in its own process group so that a
+// timeout also kills the processes it started.
+func (e *OsCommandExecutor) ExecuteWithOptions(commandLine string, opts CommandOptions, stdoutHandler, stderrHandler OutputHandler) error {
+	cmd := exec.Command("bash", "-c", commandLine)
+	cmd.Dir = opts.Dir
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
 	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Stdin = opts.Stdin
 
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		fmt.Printf("Error creating stderr pipe: %v\n", err)
-		return false
+	// Output is copied into pipes read line by line. Wait returns when the
+	// command exits, even if a process it started in the background still
+	// holds the output open, once outputDrainDelay has passed.
+	var wg sync.WaitGroup
+	var writers []*io.PipeWriter
+	closeOutput := func() {
+		for _, writer := range writers {
+			_ = writer.Close()
+		}
+		wg.Wait()
+	}
+	lineWriter := func(handler OutputHandler) io.Writer {
+		reader, writer := io.Pipe()
+		writers = append(writers, writer)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scanner := bufio.NewScanner(reader)
+			for scanner.Scan() {
+				if handler != nil {
+					handler(scanner.Text())
+				}
+			}
+			// Keep consuming output after an overlong line
+			_, _ = io.Copy(io.Discard, reader)
+		}()
+		return writer
 	}
+	if opts.Stdout != nil {
+		cmd.Stdout = opts.Stdout
+	} else {
+		cmd.Stdout = lineWriter(stdoutHandler)
+	}
+	cmd.Stderr = lineWriter(stderrHandler)
+	cmd.WaitDelay = outputDrainDelay
 
 	if err := cmd.Start(); err != nil {
-		fmt.Printf("Error starting command: %v\n", err)
-		return false
+		closeOutput()
+		return fmt.Errorf("error starting command: %w", err)
 	}
 
-	// Use WaitGroup to ensure stdout/stderr reading goroutines complete
-	// before returning. This prevents:
-	// - Output truncation
-	// - Resource leaks
-	// - Race conditions between goroutine completion and cmd.Wait()
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stdoutPipe)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if stdoutHandler != nil {
-				stdoutHandler(line)
-			}
-		}
-	}()
-
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stderrPipe)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if stderrHandler != nil {
-				stderrHandler(line)
-			}
-		}
-	}()
-
-	err = cmd.Wait()
+	var timedOut atomic.Bool
+	if opts.Timeout > 0 {
+		timer := time.AfterFunc(opts.Timeout, func() {
+			timedOut.Store(true)
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		})
+		defer timer.Stop()
+	}
 
-	// Wait for both goroutines to finish reading all output
-	wg.Wait()
+	err := cmd.Wait()
+	closeOutput()
+	// The command succeeded but left a background process holding its output
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
 
-	return err == nil
+	if timedOut.Load() {
+		return fmt.Errorf("%w after %s", ErrCommandTimeout, opts.Timeout)
+	}
+	return err
 }
 
 func NewOsCommandExecutor() *OsCommandExecutor {
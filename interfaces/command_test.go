@@ -2,10 +2,14 @@ package interfaces
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestOsCommandExecutor_Execute(t *testing.T) {
@@ -145,6 +149,72 @@ func TestOsCommandExecutor_ExecuteWithCallback_NilHandlers(t *testing.T) {
 	}
 }
 
+func TestOsCommandExecutor_ExecuteWithOptions(t *testing.T) {
+	executor := NewOsCommandExecutor()
+
+	dir := t.TempDir()
+	var lines []string
+	err := executor.ExecuteWithOptions("pwd; echo $HOOK_VALUE", CommandOptions{Dir: dir, Env: []string{"HOOK_VALUE=from option"}},
+		func(line string) { lines = append(lines, line) }, nil)
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions failed: %v", err)
+	}
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], filepath.Base(dir)) || lines[1] != "from option" {
+		t.Errorf("Unexpected output: %v", lines)
+	}
+
+	if err := executor.ExecuteWithOptions("exit 3", CommandOptions{}, nil, nil); err == nil {
+		t.Error("Expected error for failing command")
+	}
+}
+
+func TestOsCommandExecutor_ExecuteWithOptions_Timeout(t *testing.T) {
+	executor := NewOsCommandExecutor()
+
+	// The background sleep keeps the output pipes open, so the call only
+	// returns early if the whole process group is killed
+	start := time.Now()
+	err := executor.ExecuteWithOptions("sleep 30 & sleep 30; wait", CommandOptions{Timeout: 200 * time.Millisecond}, nil, nil)
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Command was not killed on timeout, took %s", elapsed)
+	}
+}
+
+func TestOsCommandExecutor_ExecuteWithOptions_BackgroundChild(t *testing.T) {
+	executor := NewOsCommandExecutor()
+
+	// A hook relaunching an app in the background returns once the shell
+	// exits, without waiting for the app or killing it on timeout
+	marker := filepath.Join(t.TempDir(), "child-done")
+	var lines []string
+	start := time.Now()
+	err := executor.ExecuteWithOptions("(sleep 1; touch "+marker+") & echo started", CommandOptions{Timeout: time.Hour},
+		func(line string) { lines = append(lines, line) }, nil)
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Expected the call not to wait for the background child, took %s", elapsed)
+	}
+	if len(lines) != 1 || lines[0] != "started" {
+		t.Errorf("Unexpected output: %v", lines)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(marker); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the background child to keep running after the call returned")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 func TestNewOsCommandExecutor(t *testing.T) {
 	executor := NewOsCommandExecutor()
 	if executor == nil {
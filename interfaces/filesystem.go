@@ -4,15 +4,25 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type FileSystem interface {
 	Open(name string) (File, error)
 	Create(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
 	ReadDir(dirname string) ([]os.DirEntry, error)
 	MkdirAll(path string, perm os.FileMode) error
+	Remove(name string) error
 	RemoveAll(path string) error
 	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+	Link(oldname, newname string) error
+	Rename(oldpath, newpath string) error
+	Chmod(name string, mode os.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
 	ReadFile(filename string) ([]byte, error)
 	WriteFile(filename string, data []byte, perm os.FileMode) error
 
@@ -51,6 +61,14 @@ func (fs *OsFileSystem) Create(name string) (File, error) {
 	return &OsFile{File: file}, nil
 }
 
+func (fs *OsFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	file, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &OsFile{File: file}, nil
+}
+
 func (fs *OsFileSystem) ReadDir(dirname string) ([]os.DirEntry, error) {
 	return os.ReadDir(dirname)
 }
@@ -59,6 +77,10 @@ func (fs *OsFileSystem) MkdirAll(path string, perm os.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
 
+func (fs *OsFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
 func (fs *OsFileSystem) RemoveAll(path string) error {
 	return os.RemoveAll(path)
 }
@@ -67,6 +89,34 @@ func (fs *OsFileSystem) Stat(name string) (os.FileInfo, error) {
 	return os.Stat(name)
 }
 
+func (fs *OsFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (fs *OsFileSystem) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (fs *OsFileSystem) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (fs *OsFileSystem) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+func (fs *OsFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (fs *OsFileSystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (fs *OsFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
 func (fs *OsFileSystem) ReadFile(filename string) ([]byte, error) {
 	return os.ReadFile(filename)
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestOsFileSystem_CreateAndOpen(t *testing.T) {
@@ -270,6 +271,67 @@ func TestOsFileSystem_EvalSymlinks(t *testing.T) {
 	}
 }
 
+func TestOsFileSystem_Symlinks(t *testing.T) {
+	fs := NewOsFileSystem()
+	tempDir := t.TempDir()
+
+	realFile := filepath.Join(tempDir, "real.txt")
+	if err := os.WriteFile(realFile, []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	link := filepath.Join(tempDir, "link.txt")
+	if err := fs.Symlink("real.txt", link); err != nil {
+		t.Fatalf("Symlink() failed: %v", err)
+	}
+
+	// Lstat describes the link itself, Stat what it points to
+	info, err := fs.Lstat(link)
+	if err != nil {
+		t.Fatalf("Lstat() failed: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Lstat() should report a symlink")
+	}
+	if info, err := fs.Stat(link); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("Stat() should follow the symlink, got %v, %v", info, err)
+	}
+
+	target, err := fs.Readlink(link)
+	if err != nil {
+		t.Fatalf("Readlink() failed: %v", err)
+	}
+	if target != "real.txt" {
+		t.Errorf("Readlink() = %q, want %q", target, "real.txt")
+	}
+}
+
+func TestOsFileSystem_ChmodAndChtimes(t *testing.T) {
+	fs := NewOsFileSystem()
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := fs.Chmod(testFile, 0600); err != nil {
+		t.Fatalf("Chmod() failed: %v", err)
+	}
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := fs.Chtimes(testFile, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes() failed: %v", err)
+	}
+
+	info, err := os.Stat(testFile)
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Mode = %v, want 0600", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("ModTime = %v, want %v", info.ModTime(), modTime)
+	}
+}
+
 func TestNewOsFileSystem(t *testing.T) {
 	fs := NewOsFileSystem()
 	if fs == nil {
@@ -1,52 +1,125 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 )
 
+// Level is the severity of a log message. Messages below the level of the
+// logger are dropped.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// Log file formats: text lines with a timestamp, or one JSON object per line.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ansiEscape matches the colour and style codes stripped when colour is off.
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;]*m")
+
 type Logger struct {
 	logFile   *os.File
 	logger    *log.Logger
 	cliLogger *log.Logger
 	enabled   bool
+	level     Level
+	color     bool
+	jsonFile  bool
+	logPath   string
+	rotation  Rotation
+	// started is the time of the first entry of the log file.
+	started time.Time
 }
 
 func NewLogger(logFilePath string) (*Logger, error) {
-	var file *os.File
-	var err error
-
+	l := &Logger{
+		cliLogger: log.New(os.Stdout, "", 0),
+		level:     LevelInfo,
+		color:     SupportsColor(os.Stdout),
+	}
 	if logFilePath != "" {
-		logDir := filepath.Dir(logFilePath)
-		if _, err := os.Stat(logDir); os.IsNotExist(err) {
-			err := os.MkdirAll(logDir, 0755)
-			if err != nil {
-				return nil, fmt.Errorf("error creating log directory: %w", err)
-			}
+		if err := l.OpenLogFile(logFilePath); err != nil {
+			return nil, err
 		}
+	}
+	return l, nil
+}
 
-		file, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+// OpenLogFile starts writing the log to logFilePath, in addition to the
+// console, closing the previous log file if any. The file is rotated first if
+// it is due.
+func (l *Logger) OpenLogFile(logFilePath string) error {
+	if err := l.openLogFile(logFilePath); err != nil {
+		return err
+	}
+	return l.rotateIfDue()
+}
+
+func (l *Logger) openLogFile(logFilePath string) error {
+	logDir := filepath.Dir(logFilePath)
+	if _, err := os.Stat(logDir); os.IsNotExist(err) {
+		err := os.MkdirAll(logDir, 0755)
 		if err != nil {
-			return nil, fmt.Errorf("error creating log file: %w", err)
+			return fmt.Errorf("error creating log directory: %w", err)
 		}
 	}
 
-	var fileLogger *log.Logger
-	if file != nil {
-		fileLogger = log.New(file, "", log.LstdFlags)
+	file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("error creating log file: %w", err)
 	}
 
-	cliLogger := log.New(os.Stdout, "", 0)
+	l.Close()
+	l.logFile = file
+	l.logger = log.New(file, "", log.LstdFlags)
+	l.enabled = true
+	l.logPath = logFilePath
+	l.started = logStarted(file)
+	return nil
+}
 
-	return &Logger{
-		logFile:   file,
-		logger:    fileLogger,
-		cliLogger: cliLogger,
-		enabled:   logFilePath != "",
-	}, nil
+// SupportsColor reports whether w is a terminal that should get ANSI colours.
+// Colours are off for pipes and files, such as cron output, and when the
+// NO_COLOR environment variable is set.
+func SupportsColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 func (l *Logger) GetCliLoggerWriter() io.Writer {
@@ -57,34 +130,126 @@ func (l *Logger) SetCliLoggerOutput(writer io.Writer) {
 	l.cliLogger.SetOutput(writer)
 }
 
-func (l *Logger) Logf(format string, v ...interface{}) {
-	l.cliLogger.Printf(format, v...)
+// SetLevel drops messages below level, on the console and in the log file.
+func (l *Logger) SetLevel(level Level) {
+	l.level = level
+}
 
-	if l.enabled && l.logger != nil {
-		l.logger.Printf(format, v...)
-	}
+// SetColor turns ANSI colours on the console on or off.
+func (l *Logger) SetColor(enabled bool) {
+	l.color = enabled
 }
 
-func (l *Logger) Log(v ...interface{}) {
-	l.cliLogger.Println(v...)
+// SetFileFormat selects the format of the log file: FormatText or FormatJSON.
+func (l *Logger) SetFileFormat(format string) error {
+	switch format {
+	case FormatText:
+		l.jsonFile = false
+	case FormatJSON:
+		l.jsonFile = true
+	default:
+		return fmt.Errorf("unknown log format %q: use %s or %s", format, FormatText, FormatJSON)
+	}
+	return nil
+}
 
-	if l.enabled && l.logger != nil {
-		l.logger.Println(v...)
+// logMessage writes a formatted message at the given level to the console and
+// the log file, unless the level of the logger drops it.
+func (l *Logger) logMessage(level Level, message string) {
+	if level < l.level {
+		return
 	}
+	l.write(level, message)
 }
 
-func (l *Logger) LogErrorf(format string, v ...interface{}) error {
-	logMessage := "Error: " + format
+// write writes a message to the console and the log file.
+func (l *Logger) write(level Level, message string) {
+	prefix := ""
+	switch level {
+	case LevelWarn:
+		prefix = "Warning: "
+	case LevelError:
+		prefix = "Error: "
+	}
 
-	// For CLI, print in red
-	redErrorFormat := "\033[31mError: " + format + "\033[0m"
-	l.cliLogger.Printf(redErrorFormat, v...)
+	switch {
+	case !l.color:
+		l.cliLogger.Print(prefix + ansiEscape.ReplaceAllString(message, ""))
+	case level == LevelError:
+		// For CLI, print errors in red
+		l.cliLogger.Print("\033[31m" + prefix + message + "\033[0m")
+	case level == LevelWarn:
+		l.cliLogger.Print("\033[33m" + prefix + message + "\033[0m")
+	default:
+		l.cliLogger.Print(message)
+	}
 
-	if l.enabled && l.logger != nil {
-		l.logger.Printf(logMessage, v...)
+	if !l.enabled || l.logger == nil {
+		return
+	}
+	if err := l.rotateIfDue(); err != nil {
+		// Not logged through logMessage, which would try to rotate again
+		l.cliLogger.Print("Warning: " + err.Error())
+		if l.logger == nil {
+			return
+		}
+	}
+	if l.started.IsZero() {
+		l.started = time.Now()
+	}
+	plain := ansiEscape.ReplaceAllString(message, "")
+	if !l.jsonFile {
+		l.logger.Print(prefix + plain)
+		return
+	}
+	line, err := json.Marshal(struct {
+		Time    string `json:"time"`
+		Level   string `json:"level"`
+		Message string `json:"msg"`
+	}{time.Now().Format(time.RFC3339Nano), level.String(), strings.TrimSpace(plain)})
+	if err == nil {
+		_, _ = l.logFile.Write(append(line, '\n'))
 	}
+}
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.logMessage(LevelDebug, fmt.Sprintf(format, v...))
+}
 
-	return fmt.Errorf(logMessage, v...)
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.logMessage(LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.logMessage(LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.logMessage(LevelError, fmt.Sprintf(format, v...))
+}
+
+// Logf logs at info level.
+func (l *Logger) Logf(format string, v ...interface{}) {
+	l.logMessage(LevelInfo, fmt.Sprintf(format, v...))
+}
+
+// Outputf writes the result of an action, such as a listing or a report, at
+// info level. Unlike Logf it is never dropped by the level of the logger, so
+// -quiet still shows what was asked for.
+func (l *Logger) Outputf(format string, v ...interface{}) {
+	l.write(LevelInfo, fmt.Sprintf(format, v...))
+}
+
+// Log logs at info level.
+func (l *Logger) Log(v ...interface{}) {
+	l.logMessage(LevelInfo, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+// LogErrorf logs at error level and returns the message as an error.
+func (l *Logger) LogErrorf(format string, v ...interface{}) error {
+	err := fmt.Errorf("Error: "+format, v...)
+	l.logMessage(LevelError, strings.TrimPrefix(err.Error(), "Error: "))
+	return err
 }
 
 func (l *Logger) Close() {
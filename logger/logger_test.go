@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -155,9 +156,10 @@ func TestLogger_LogErrorf(t *testing.T) {
 	}
 	defer logger.Close()
 
-	// Capture CLI output
+	// Capture CLI output, as a colour terminal would get it
 	var cliOutput bytes.Buffer
 	logger.SetCliLoggerOutput(&cliOutput)
+	logger.SetColor(true)
 
 	testMsg := "Error message with %s"
 	testArg := "details"
@@ -320,4 +322,125 @@ func TestLogger_MultipleWrites(t *testing.T) {
 			t.Errorf("Log file does not contain message: %s", msg)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestLogger_Levels(t *testing.T) {
+	logger, err := NewLogger("")
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	defer logger.Close()
+
+	var cliOutput bytes.Buffer
+	logger.SetCliLoggerOutput(&cliOutput)
+	logger.SetColor(false)
+
+	logger.Debugf("debug message")
+	logger.Infof("info message")
+	logger.Warnf("warn message")
+	if got := cliOutput.String(); got != "info message\nWarning: warn message\n" {
+		t.Errorf("Unexpected output at info level: %q", got)
+	}
+
+	cliOutput.Reset()
+	logger.SetLevel(LevelDebug)
+	logger.Debugf("debug message")
+	if got := cliOutput.String(); got != "debug message\n" {
+		t.Errorf("Unexpected output at debug level: %q", got)
+	}
+
+	cliOutput.Reset()
+	logger.SetLevel(LevelWarn)
+	logger.Logf("info message")
+	logger.Errorf("error message")
+	if got := cliOutput.String(); got != "Error: error message\n" {
+		t.Errorf("Unexpected output at warn level: %q", got)
+	}
+
+	cliOutput.Reset()
+	logger.SetLevel(LevelError)
+	logger.Outputf("result %d", 1)
+	if got := cliOutput.String(); got != "result 1\n" {
+		t.Errorf("Outputf should not depend on the level, got %q", got)
+	}
+}
+
+func TestLogger_NoColorStripsCodes(t *testing.T) {
+	logger, err := NewLogger("")
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	defer logger.Close()
+
+	var cliOutput bytes.Buffer
+	logger.SetCliLoggerOutput(&cliOutput)
+	logger.SetColor(false)
+
+	logger.Logf("\033[32mgreen\033[0m")
+	logger.Errorf("failed")
+	if got := cliOutput.String(); strings.Contains(got, "\033[") || got != "green\nError: failed\n" {
+		t.Errorf("Expected output without colour codes, got %q", got)
+	}
+}
+
+func TestSupportsColor(t *testing.T) {
+	if SupportsColor(&bytes.Buffer{}) {
+		t.Error("A buffer should not support colour")
+	}
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer file.Close()
+	if SupportsColor(file) {
+		t.Error("A regular file should not support colour")
+	}
+}
+
+func TestLogger_JSONFileFormat(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := NewLogger(logFile)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	defer logger.Close()
+	logger.SetCliLoggerOutput(&bytes.Buffer{})
+
+	if err := logger.SetFileFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown log format")
+	}
+	if err := logger.SetFileFormat(FormatJSON); err != nil {
+		t.Fatalf("SetFileFormat failed: %v", err)
+	}
+	logger.Logf("Backed up \033[32m%d\033[0m file(s)", 3)
+	logger.Warnf("disk almost full")
+	logger.Debugf("not logged")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d:\n%s", len(lines), content)
+	}
+
+	var entry struct {
+		Time  string `json:"time"`
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Log line is not JSON: %v\n%s", err, lines[0])
+	}
+	if entry.Level != "info" || entry.Msg != "Backed up 3 file(s)" || entry.Time == "" {
+		t.Errorf("Unexpected first entry: %+v", entry)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Log line is not JSON: %v\n%s", err, lines[1])
+	}
+	if entry.Level != "warn" || entry.Msg != "disk almost full" {
+		t.Errorf("Unexpected second entry: %+v", entry)
+	}
+}
@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Rotation configures when the log file is rotated and how many rotated files
+// are kept. Rotated files are named <log>.1 (the newest) to <log>.<Keep>.
+type Rotation struct {
+	// MaxSize rotates the log file once it holds this many bytes; 0 disables
+	// size-based rotation.
+	MaxSize int64
+	// MaxAge rotates the log file once its first entry is this old; 0
+	// disables age-based rotation.
+	MaxAge time.Duration
+	// Keep is the number of rotated files to keep; older ones are deleted.
+	Keep int
+	// Compress gzips the rotated files, which then end in .gz.
+	Compress bool
+}
+
+func (r Rotation) enabled() bool {
+	return r.MaxSize > 0 || r.MaxAge > 0
+}
+
+// SetRotation sets how the log file is rotated, and rotates it right away if
+// it is already too large or too old.
+func (l *Logger) SetRotation(rotation Rotation) error {
+	if rotation.MaxSize < 0 || rotation.MaxAge < 0 || rotation.Keep < 0 {
+		return fmt.Errorf("invalid log rotation: size, age and number of kept files must not be negative")
+	}
+	l.rotation = rotation
+	return l.rotateIfDue()
+}
+
+// rotateIfDue rotates the log file when it reached the maximum size or age.
+func (l *Logger) rotateIfDue() error {
+	if l.logFile == nil || !l.rotation.enabled() {
+		return nil
+	}
+
+	due := l.rotation.MaxAge > 0 && !l.started.IsZero() && time.Since(l.started) >= l.rotation.MaxAge
+	if l.rotation.MaxSize > 0 {
+		info, err := l.logFile.Stat()
+		if err != nil {
+			return fmt.Errorf("error checking log file: %w", err)
+		}
+		due = due || info.Size() >= l.rotation.MaxSize
+	}
+	if !due {
+		return nil
+	}
+	return l.rotate()
+}
+
+// rotate moves the log file to <log>.1, shifting older rotated files up and
+// deleting those beyond Keep, and starts a new log file.
+func (l *Logger) rotate() error {
+	path := l.logPath
+	l.Close()
+	l.logFile, l.logger, l.enabled = nil, nil, false
+
+	rotateErr := shiftRotatedLogs(path, l.rotation.Keep)
+	if rotateErr == nil {
+		if l.rotation.Keep == 0 {
+			rotateErr = os.Remove(path)
+		} else if rotateErr = os.Rename(path, rotatedLogName(path, 1, "")); rotateErr == nil && l.rotation.Compress {
+			rotateErr = gzipFile(rotatedLogName(path, 1, ""))
+		}
+	}
+
+	// Keep logging to a file even if the old one could not be rotated
+	if err := l.openLogFile(path); err != nil {
+		return err
+	}
+	if rotateErr != nil {
+		return fmt.Errorf("error rotating log file: %w", rotateErr)
+	}
+	return nil
+}
+
+// rotatedLogName returns the name of the n-th rotated file of the log.
+func rotatedLogName(path string, n int, suffix string) string {
+	return fmt.Sprintf("%s.%d%s", path, n, suffix)
+}
+
+// shiftRotatedLogs makes room for a new <log>.1 by renaming <log>.N to
+// <log>.N+1, compressed or not, and deleting the files that would go beyond
+// keep.
+func shiftRotatedLogs(path string, keep int) error {
+	suffixes := []string{"", ".gz"}
+
+	for n := keep; ; n++ {
+		found := false
+		for _, suffix := range suffixes {
+			err := os.Remove(rotatedLogName(path, n, suffix))
+			if err == nil {
+				found = true
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if !found && n > keep {
+			break
+		}
+	}
+
+	for n := keep - 1; n >= 1; n-- {
+		for _, suffix := range suffixes {
+			err := os.Rename(rotatedLogName(path, n, suffix), rotatedLogName(path, n+1, suffix))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gzipFile compresses path to path.gz and removes path.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// logStarted returns the time of the first entry of a log file, in either
+// format, or the zero time if it is empty. Files whose first line cannot be
+// parsed fall back to their modification time.
+func logStarted(file *os.File) time.Time {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return time.Time{}
+	}
+
+	f, err := os.Open(file.Name())
+	if err != nil {
+		return info.ModTime()
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	var entry struct {
+		Time time.Time `json:"time"`
+	}
+	if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &entry) == nil && !entry.Time.IsZero() {
+		return entry.Time
+	}
+	// Text lines start with the log.LstdFlags timestamp
+	if len(line) >= len(textTimeLayout) {
+		if started, err := time.ParseInLocation(textTimeLayout, line[:len(textTimeLayout)], time.Local); err == nil {
+			return started
+		}
+	}
+	return info.ModTime()
+}
+
+// textTimeLayout is the timestamp written by log.LstdFlags.
+const textTimeLayout = "2006/01/02 15:04:05"
@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRotationTestLogger(t *testing.T, rotation Rotation) (*Logger, string) {
+	t.Helper()
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	logger, err := NewLogger(logFile)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	t.Cleanup(logger.Close)
+	logger.SetCliLoggerOutput(&bytes.Buffer{})
+	if err := logger.SetRotation(rotation); err != nil {
+		t.Fatalf("SetRotation failed: %v", err)
+	}
+	return logger, logFile
+}
+
+func readLogFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestLogger_RotatesBySize(t *testing.T) {
+	logger, logFile := newRotationTestLogger(t, Rotation{MaxSize: 10, Keep: 2})
+
+	for _, msg := range []string{"first message", "second message", "third message", "fourth message"} {
+		logger.Logf("%s", msg)
+	}
+
+	if content := readLogFile(t, logFile); !strings.Contains(content, "fourth message") || strings.Contains(content, "third") {
+		t.Errorf("Expected only the newest message in the log file, got %q", content)
+	}
+	if content := readLogFile(t, logFile+".1"); !strings.Contains(content, "third message") {
+		t.Errorf("Expected the third message in %s.1, got %q", logFile, content)
+	}
+	if content := readLogFile(t, logFile+".2"); !strings.Contains(content, "second message") {
+		t.Errorf("Expected the second message in %s.2, got %q", logFile, content)
+	}
+	if _, err := os.Stat(logFile + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 rotated files to be kept, stat .3: %v", err)
+	}
+}
+
+func TestLogger_RotatesByAgeOnOpen(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	old := time.Now().Add(-48 * time.Hour).Format(textTimeLayout)
+	if err := os.WriteFile(logFile, []byte(old+" old run\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	logger, err := NewLogger(logFile)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	defer logger.Close()
+	logger.SetCliLoggerOutput(&bytes.Buffer{})
+
+	if err := logger.SetRotation(Rotation{MaxAge: 72 * time.Hour, Keep: 1}); err != nil {
+		t.Fatalf("SetRotation failed: %v", err)
+	}
+	if _, err := os.Stat(logFile + ".1"); !os.IsNotExist(err) {
+		t.Fatal("Log file younger than the maximum age should not be rotated")
+	}
+
+	if err := logger.SetRotation(Rotation{MaxAge: 24 * time.Hour, Keep: 1}); err != nil {
+		t.Fatalf("SetRotation failed: %v", err)
+	}
+	logger.Logf("new run")
+
+	if content := readLogFile(t, logFile+".1"); !strings.Contains(content, "old run") {
+		t.Errorf("Expected the old run in the rotated file, got %q", content)
+	}
+	if content := readLogFile(t, logFile); strings.Contains(content, "old run") || !strings.Contains(content, "new run") {
+		t.Errorf("Expected only the new run in the log file, got %q", content)
+	}
+}
+
+func TestLogger_RotationCompress(t *testing.T) {
+	logger, logFile := newRotationTestLogger(t, Rotation{MaxSize: 10, Keep: 1, Compress: true})
+
+	logger.Logf("first message")
+	logger.Logf("second message")
+	logger.Logf("third message")
+
+	if _, err := os.Stat(logFile + ".1"); !os.IsNotExist(err) {
+		t.Error("Uncompressed rotated file should have been removed")
+	}
+	if _, err := os.Stat(logFile + ".2.gz"); !os.IsNotExist(err) {
+		t.Error("Only one rotated file should be kept")
+	}
+
+	file, err := os.Open(logFile + ".1.gz")
+	if err != nil {
+		t.Fatalf("Expected a compressed rotated file: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Reading compressed log failed: %v", err)
+	}
+	if !strings.Contains(string(content), "second message") {
+		t.Errorf("Unexpected compressed log content: %q", content)
+	}
+}
+
+func TestLogger_RotationKeepZero(t *testing.T) {
+	logger, logFile := newRotationTestLogger(t, Rotation{MaxSize: 10})
+
+	logger.Logf("first message")
+	logger.Logf("second message")
+
+	if content := readLogFile(t, logFile); strings.Contains(content, "first") || !strings.Contains(content, "second message") {
+		t.Errorf("Unexpected log file content: %q", content)
+	}
+	if _, err := os.Stat(logFile + ".1"); !os.IsNotExist(err) {
+		t.Error("No rotated file should be kept with Keep 0")
+	}
+}
+
+func TestLogger_SetRotationInvalid(t *testing.T) {
+	logger, err := NewLogger("")
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	defer logger.Close()
+
+	if err := logger.SetRotation(Rotation{MaxSize: -1}); err == nil {
+		t.Error("Expected an error for a negative maximum size")
+	}
+}
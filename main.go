@@ -9,6 +9,7 @@ import (
 	"SettingsSentry/pkg/printer"
 	"SettingsSentry/pkg/util"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -22,10 +23,39 @@ var (
 	Version string = "1.2.0"
 )
 
+// Exit codes, so cron and scripts can tell a partial backup from a failed one.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+	exitPartial = 3
+)
+
+// exitError ends the program with a specific exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitCode returns the exit code for the error returned by run.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitFailure
+}
+
 func main() {
 	if err := run(os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -41,6 +71,12 @@ func run(args []string) error {
 		return fmt.Errorf("error initializing logger: %w", err)
 	}
 
+	// Keep stdout for the JSON report; logs and progress go to stderr
+	if jsonOutputRequested(args) {
+		appLogger.SetCliLoggerOutput(os.Stderr)
+		appLogger.SetColor(logger.SupportsColor(os.Stderr))
+	}
+
 	// Set up panic recovery
 	defer func() {
 		if r := recover(); r != nil {
@@ -62,6 +98,7 @@ func run(args []string) error {
 	config.Fs = util.Fs
 	backup.AppLogger = appLogger
 	backup.Fs = util.Fs
+	backup.ToolVersion = Version
 	command.CmdExecutor = util.CmdExecutor
 	printer.AppLogger = util.AppLogger
 
@@ -78,7 +115,11 @@ func run(args []string) error {
 	action, flags, err := cli.ParseFlags(args)
 	if err != nil {
 		cli.ShowHelp()
-		return fmt.Errorf("flag parsing error: %w", err)
+		return &exitError{code: exitUsage, err: fmt.Errorf("flag parsing error: %w", err)}
+	}
+
+	if err := cli.ConfigureLogger(flags); err != nil {
+		return fmt.Errorf("error configuring logger: %w", err)
 	}
 
 	// Execute the action
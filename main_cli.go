@@ -12,9 +12,11 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // CLI handles command-line interface operations
@@ -30,7 +32,13 @@ type CLI struct {
 	envCommands      bool
 	envDryRun        bool
 	envZip           bool
+	envSkipUnchanged bool
 	envPassword      string
+	// stdout receives the JSON report of -output=json
+	stdout io.Writer
+	// interactive is set when standard input is a terminal, so that new
+	// commands are trusted on first use instead of refused
+	interactive bool
 }
 
 // NewCLI creates a new CLI instance
@@ -41,9 +49,18 @@ func NewCLI(logger *logger.Logger, fs interfaces.FileSystem, cmdExecutor interfa
 		cmdExecutor:     cmdExecutor,
 		embeddedConfigs: embeddedConfigs,
 		version:         version,
+		stdout:          os.Stdout,
+		interactive:     isTerminal(os.Stdin),
 	}
 }
 
+// isTerminal reports whether f is a terminal rather than a pipe or a file, as
+// for runs started by cron.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 // ParseFlags parses command-line arguments and environment variables
 func (c *CLI) ParseFlags(args []string) (action string, flags map[string]interface{}, err error) {
 	if len(args) < 1 {
@@ -53,7 +70,7 @@ func (c *CLI) ParseFlags(args []string) (action string, flags map[string]interfa
 	// Get iCloud path for default backup location
 	icloudPath, err := config.GetICloudFolderLocation()
 	if err != nil {
-		c.logger.Logf("Error: iCloud path not found - %v", err)
+		c.logger.Debugf("iCloud path not found - %v", err)
 		icloudPath = ""
 	} else {
 		icloudPath = filepath.Join(icloudPath, "settingssentry_backups")
@@ -62,7 +79,7 @@ func (c *CLI) ParseFlags(args []string) (action string, flags map[string]interfa
 		homeDir, homeErr := config.GetHomeDirectory()
 		if homeErr == nil {
 			icloudPath = filepath.Join(homeDir, ".settingssentry_backups")
-			c.logger.Logf("Warning: iCloud path not found, using default backup path: %s", icloudPath)
+			c.logger.Warnf("iCloud path not found, using default backup path: %s", icloudPath)
 		} else {
 			return "", nil, errors.New("cannot determine iCloud or home directory for default backup path")
 		}
@@ -75,6 +92,7 @@ func (c *CLI) ParseFlags(args []string) (action string, flags map[string]interfa
 	c.envCommands = os.Getenv("SETTINGSSENTRY_COMMANDS") == "true"
 	c.envDryRun = os.Getenv("SETTINGSSENTRY_DRY_RUN") == "true"
 	c.envZip = os.Getenv("SETTINGSSENTRY_ZIP") == "true"
+	c.envSkipUnchanged = os.Getenv("SETTINGSSENTRY_SKIP_UNCHANGED") == "true"
 	c.envPassword = os.Getenv("SETTINGSSENTRY_PASSWORD")
 
 	action = args[0]
@@ -91,10 +109,31 @@ func (c *CLI) ParseFlags(args []string) (action string, flags map[string]interfa
 	appNameFlag := actionFlags.String("app", c.envAppName, "Optional: Comma-separated list of application names to process (env: SETTINGSSENTRY_APP)")
 	commands := actionFlags.Bool("allow-commands", c.envCommands, "Optional: Allow execution of pre-backup/restore commands from config files. SECURITY WARNING: Only enable for trusted configs! Commands execute with full user privileges. (env: SETTINGSSENTRY_COMMANDS)")
 	dryRunFlag := actionFlags.Bool("dry-run", c.envDryRun, "Optional: Perform a dry run without making any changes (env: SETTINGSSENTRY_DRY_RUN)")
-	versionsToKeep := actionFlags.Int("versions", 1, "Number of backup versions to keep")
+	versionsToKeep := actionFlags.Int("versions", 1, "Keep the newest <n> backup versions; 0 = no limit (default 1 only applies to a backup without -keep-* rules)")
+	keepDaily := actionFlags.Int("keep-daily", 0, "Optional: Also keep the newest version of each of the last <n> days (backup and prune)")
+	keepWeekly := actionFlags.Int("keep-weekly", 0, "Optional: Also keep the newest version of each of the last <n> weeks (backup and prune)")
+	keepMonthly := actionFlags.Int("keep-monthly", 0, "Optional: Also keep the newest version of each of the last <n> months (backup and prune)")
+	keepWithin := actionFlags.String("keep-within", "", "Optional: Also keep every version newer than this, e.g. 30d, 4w or 36h (backup and prune)")
 	password := actionFlags.String("password", c.envPassword, "Optional: Password to encrypt/decrypt backups (env: SETTINGSSENTRY_PASSWORD)")
 	zipFlag := actionFlags.Bool("zip", c.envZip, "Optional: Create backup as a zip archive instead of a directory (env: SETTINGSSENTRY_ZIP)")
+	dedupFlag := actionFlags.Bool("dedup", false, "Optional: Store each file content once in an object store shared by the versions (backup only)")
+	skipUnchanged := actionFlags.Bool("skip-unchanged", c.envSkipUnchanged, "Optional: Do not keep a new version when nothing changed since the latest one (backup only, env: SETTINGSSENTRY_SKIP_UNCHANGED)")
+	incrementalFlag := actionFlags.Bool("incremental", false, "Optional: Reuse the files that did not change since the previous version instead of copying them (backup only)")
 	logFilePath := actionFlags.String("logfile", "", "Optional: Path to log file.")
+	logFormat := actionFlags.String("logformat", logger.FormatText, "Optional: Format of the log file: text or json (one JSON object per line)")
+	logMaxSize := actionFlags.Int("logmaxsize", 0, "Optional: Rotate the log file once it reaches this many megabytes (0 = no limit)")
+	logMaxAge := actionFlags.Int("logmaxage", 0, "Optional: Rotate the log file once its first entry is this many days old (0 = no limit)")
+	logKeep := actionFlags.Int("logkeep", 5, "Optional: Number of rotated log files to keep")
+	logCompress := actionFlags.Bool("logcompress", false, "Optional: Gzip rotated log files")
+	verbose := actionFlags.Bool("verbose", false, "Optional: Also log debug messages")
+	quiet := actionFlags.Bool("quiet", false, "Optional: Only log warnings and errors")
+	versionFlag := actionFlags.String("version", "", "Optional: Backup version to restore, diff, list or verify: a timestamp (YYYYMMDD-HHMMSS), latest or latest~N (default: latest)")
+	targetFlag := actionFlags.String("target", "", "Optional: Restore into this directory instead of the home directory (restore and diff only)")
+	beforeFlag := actionFlags.String("before", "", "Optional: Only consider backup versions created before this date (YYYY-MM-DD)")
+	reportFlag := actionFlags.String("report", "", "Optional: Write a JSON report of the backup or restore to this file")
+	outputFlag := actionFlags.String("output", "text", "Optional: Output format of backup and restore: text or json (JSON report on stdout, logs on stderr)")
+	approveFlag := actionFlags.Bool("approve", false, "Optional: Approve the new and changed commands shown by the trust action")
+	onHookFailure := actionFlags.String("on-hook-failure", "", "Optional: What to do when a config command fails, for apps that do not set on_hook_failure: continue, skip-app or abort-run (default: skip-app)")
 
 	// Parse arguments starting from the one after the action
 	if err := actionFlags.Parse(args[1:]); err != nil {
@@ -106,6 +145,86 @@ func (c *CLI) ParseFlags(args []string) (action string, flags map[string]interfa
 		return "", nil, fmt.Errorf("versions must be non-negative, got %d", *versionsToKeep)
 	}
 
+	retention := backup.RetentionPolicy{Daily: *keepDaily, Weekly: *keepWeekly, Monthly: *keepMonthly}
+	if retention.Daily < 0 || retention.Weekly < 0 || retention.Monthly < 0 {
+		return "", nil, fmt.Errorf("-keep-daily, -keep-weekly and -keep-monthly must be non-negative")
+	}
+	if *keepWithin != "" {
+		within, err := backup.ParseRetentionDuration(*keepWithin)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid -keep-within value: %w", err)
+		}
+		retention.Within = within
+	}
+	if !retention.IsZero() && action != "backup" && action != "prune" {
+		return "", nil, fmt.Errorf("-keep-daily, -keep-weekly, -keep-monthly and -keep-within can only be used with the backup and prune actions")
+	}
+	// -versions is a rule like the -keep-* ones, 0 meaning no rule, for backup
+	// and prune alike. Its default of 1 only applies to a backup given no rule
+	// at all; prune needs one
+	actionFlags.Visit(func(f *flag.Flag) {
+		if f.Name == "versions" {
+			retention.Last = *versionsToKeep
+		}
+	})
+	if action == "backup" && retention.IsZero() {
+		retention.Last = *versionsToKeep
+	}
+	if action == "prune" && retention.IsZero() {
+		return "", nil, fmt.Errorf("prune needs a retention rule: -versions, -keep-daily, -keep-weekly, -keep-monthly or -keep-within")
+	}
+
+	// Restoring into another root only makes sense for restore and diff
+	if *targetFlag != "" && action != "restore" && action != "diff" {
+		return "", nil, fmt.Errorf("-target can only be used with the restore and diff actions")
+	}
+
+	if *dedupFlag && action != "backup" {
+		return "", nil, fmt.Errorf("-dedup can only be used with the backup action")
+	}
+	if *dedupFlag && *zipFlag {
+		return "", nil, fmt.Errorf("-dedup cannot be combined with -zip")
+	}
+	if *incrementalFlag && action != "backup" {
+		return "", nil, fmt.Errorf("-incremental can only be used with the backup action")
+	}
+	if *incrementalFlag && *zipFlag {
+		return "", nil, fmt.Errorf("-incremental cannot be combined with -zip")
+	}
+
+	if *verbose && *quiet {
+		return "", nil, fmt.Errorf("-verbose and -quiet cannot be used together")
+	}
+	if *logFormat != logger.FormatText && *logFormat != logger.FormatJSON {
+		return "", nil, fmt.Errorf("invalid -logformat value %q: use text or json", *logFormat)
+	}
+
+	if *logMaxSize < 0 || *logMaxAge < 0 || *logKeep < 0 {
+		return "", nil, fmt.Errorf("-logmaxsize, -logmaxage and -logkeep must be non-negative")
+	}
+
+	if *outputFlag != "text" && *outputFlag != "json" {
+		return "", nil, fmt.Errorf("invalid -output value %q: use text or json", *outputFlag)
+	}
+	if (*reportFlag != "" || *outputFlag == "json") && action != "backup" && action != "restore" {
+		return "", nil, fmt.Errorf("-report and -output=json can only be used with the backup and restore actions")
+	}
+
+	if *approveFlag && action != "trust" {
+		return "", nil, fmt.Errorf("-approve can only be used with the trust action")
+	}
+
+	// Validate the before date early so typos are reported as usage errors
+	if *beforeFlag != "" {
+		if _, err := backup.ParseBeforeDate(*beforeFlag); err != nil {
+			return "", nil, fmt.Errorf("invalid -before value: %w", err)
+		}
+	}
+
+	if *onHookFailure != "" && !config.ValidHookFailurePolicy(*onHookFailure) {
+		return "", nil, fmt.Errorf("invalid -on-hook-failure value %q: use continue, skip-app or abort-run", *onHookFailure)
+	}
+
 	// Split the appNameFlag string into a slice
 	var appNames []string
 	if *appNameFlag != "" {
@@ -130,18 +249,85 @@ func (c *CLI) ParseFlags(args []string) (action string, flags map[string]interfa
 		"versionsToKeep": *versionsToKeep,
 		"password":       *password,
 		"zip":            *zipFlag,
+		"dedup":          *dedupFlag,
+		"incremental":    *incrementalFlag,
+		"skipUnchanged":  *skipUnchanged,
+		"retention":      retention,
 		"logFilePath":    *logFilePath,
+		"logFormat":      *logFormat,
+		"logMaxSize":     *logMaxSize,
+		"logMaxAge":      *logMaxAge,
+		"logKeep":        *logKeep,
+		"logCompress":    *logCompress,
+		"verbose":        *verbose,
+		"quiet":          *quiet,
+		"version":        *versionFlag,
+		"before":         *beforeFlag,
+		"target":         *targetFlag,
+		"onHookFailure":  *onHookFailure,
+		"approve":        *approveFlag,
+		"report":         *reportFlag,
+		"output":         *outputFlag,
 		"extraArgs":      actionFlags.Args(),
 	}
 
 	return action, flags, nil
 }
 
+// ConfigureLogger applies the logging flags: the level, the log file, its
+// format and rotation.
+func (c *CLI) ConfigureLogger(flags map[string]interface{}) error {
+	switch {
+	case flags["verbose"] == true:
+		c.logger.SetLevel(logger.LevelDebug)
+	case flags["quiet"] == true:
+		c.logger.SetLevel(logger.LevelWarn)
+	}
+
+	if format, ok := flags["logFormat"].(string); ok && format != "" {
+		if err := c.logger.SetFileFormat(format); err != nil {
+			return err
+		}
+	}
+	maxSize, _ := flags["logMaxSize"].(int)
+	maxAge, _ := flags["logMaxAge"].(int)
+	keep, _ := flags["logKeep"].(int)
+	compress, _ := flags["logCompress"].(bool)
+	rotation := logger.Rotation{
+		MaxSize:  int64(maxSize) * 1024 * 1024,
+		MaxAge:   time.Duration(maxAge) * 24 * time.Hour,
+		Keep:     keep,
+		Compress: compress,
+	}
+	if err := c.logger.SetRotation(rotation); err != nil {
+		return err
+	}
+
+	if path, ok := flags["logFilePath"].(string); ok && path != "" {
+		if err := c.logger.OpenLogFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ExecuteAction executes the specified action with the given flags
 func (c *CLI) ExecuteAction(action string, flags map[string]interface{}) error {
 	switch action {
 	case "backup", "restore":
 		return c.executeBackupRestore(action, flags)
+	case "verify":
+		return c.executeVerify(flags)
+	case "list":
+		return c.executeList(flags)
+	case "prune":
+		return c.executePrune(flags)
+	case "diff":
+		return c.executeDiff(flags)
+	case "undo":
+		return c.executeUndo(flags)
+	case "trust":
+		return c.executeTrust(flags)
 	case "configsinit":
 		return c.executeConfigsInit()
 	case "install":
@@ -162,16 +348,219 @@ func (c *CLI) executeBackupRestore(action string, flags map[string]interface{})
 	dryRun := flags["dryRun"].(bool)
 	versionsToKeep := flags["versionsToKeep"].(int)
 	zipFlag := flags["zip"].(bool)
+	dedup, _ := flags["dedup"].(bool)
+	incremental, _ := flags["incremental"].(bool)
+	skipUnchanged, _ := flags["skipUnchanged"].(bool)
+	retention, _ := flags["retention"].(backup.RetentionPolicy)
 	password := flags["password"].(string)
+	version, _ := flags["version"].(string)
+	before, _ := flags["before"].(string)
+	target, _ := flags["target"].(string)
+	onHookFailure, _ := flags["onHookFailure"].(string)
+	reportPath, _ := flags["report"].(string)
+	jsonOutput := flags["output"] == "json"
 
 	util.DryRun = dryRun
 	backup.DryRun = dryRun
+	backup.TrustOnFirstUse = c.interactive
 
 	mainPrinter := printer.NewPrinter("", c.logger)
 	backup.Printer = mainPrinter
 
-	isBackup := action == "backup"
-	backup.ProcessConfiguration(configFolder, backupFolder, appNames, isBackup, commands, versionsToKeep, zipFlag, password)
+	result, err := backup.Process(backup.Options{
+		ConfigFolder:   configFolder,
+		BackupFolder:   backupFolder,
+		AppNames:       appNames,
+		IsBackup:       action == "backup",
+		Commands:       commands,
+		VersionsToKeep: versionsToKeep,
+		ZipBackup:      zipFlag,
+		Password:       password,
+		Dedup:          dedup,
+		Incremental:    incremental,
+		SkipUnchanged:  skipUnchanged,
+		Retention:      retention,
+		Version:        version,
+		Before:         before,
+		Target:         target,
+		OnHookFailure:  onHookFailure,
+	})
+	if result != nil {
+		c.logger.Logf("%s", result.Summary())
+		for _, failure := range result.Failures() {
+			c.logger.Logf("  failed: %s", failure)
+		}
+	}
+
+	if reportPath != "" || jsonOutput {
+		report, reportErr := backup.NewReport(action == "backup", result, err).JSON()
+		if reportErr != nil {
+			return reportErr
+		}
+		if reportPath != "" {
+			if writeErr := c.fs.WriteFile(reportPath, report, 0644); writeErr != nil {
+				return fmt.Errorf("failed to write report '%s': %w", reportPath, writeErr)
+			}
+		}
+		if jsonOutput {
+			if _, writeErr := c.stdout.Write(report); writeErr != nil {
+				return fmt.Errorf("failed to write report: %w", writeErr)
+			}
+		}
+	}
+
+	// Some items made it into the version: report a partial failure
+	if backup.RunStatus(result, err) == backup.RunPartial {
+		return &exitError{code: exitPartial, err: err}
+	}
+	return err
+}
+
+// executeUndo handles undo action
+func (c *CLI) executeUndo(flags map[string]interface{}) error {
+	dryRun := flags["dryRun"].(bool)
+	util.DryRun = dryRun
+	backup.DryRun = dryRun
+	backup.Printer = printer.NewPrinter("", c.logger)
+
+	if err := backup.Undo(backup.Options{
+		ConfigFolder: flags["configFolder"].(string),
+		BackupFolder: flags["backupFolder"].(string),
+		Password:     flags["password"].(string),
+	}); err != nil {
+		return fmt.Errorf("failed to undo restore: %w", err)
+	}
+	return nil
+}
+
+// executeTrust handles trust action
+func (c *CLI) executeTrust(flags map[string]interface{}) error {
+	approve, _ := flags["approve"].(bool)
+	dryRun := flags["dryRun"].(bool)
+	util.DryRun = dryRun
+	backup.DryRun = dryRun
+	backup.Printer = printer.NewPrinter("", c.logger)
+
+	results, err := backup.ReviewTrust(backup.Options{
+		ConfigFolder: flags["configFolder"].(string),
+		BackupFolder: flags["backupFolder"].(string),
+		AppNames:     flags["appNames"].([]string),
+	}, approve)
+	if err != nil {
+		return fmt.Errorf("failed to review trusted commands: %w", err)
+	}
+	backup.PrintTrust(results, approve)
+
+	if !approve {
+		pending := 0
+		for _, r := range results {
+			if r.Status != backup.TrustOK {
+				pending++
+			}
+		}
+		if pending > 0 {
+			c.logger.Logf("%d application(s) have commands that are not approved; run 'settingssentry trust -approve' to approve them", pending)
+		}
+	}
+	return nil
+}
+
+// executeVerify handles verify action
+func (c *CLI) executeVerify(flags map[string]interface{}) error {
+	backupFolder := flags["backupFolder"].(string)
+	password := flags["password"].(string)
+
+	versionName, _ := flags["version"].(string)
+	before, _ := flags["before"].(string)
+	extraArgs := flags["extraArgs"].([]string)
+	if versionName == "" && len(extraArgs) > 0 {
+		versionName = extraArgs[0]
+	}
+
+	versionPath, isZip, err := backup.FindVersion(backupFolder, versionName, before)
+	if err != nil {
+		return fmt.Errorf("cannot find backup version to verify: %w", err)
+	}
+
+	report, err := backup.VerifyVersion(versionPath, isZip, password)
+	if err != nil {
+		return fmt.Errorf("failed to verify backup version: %w", err)
+	}
+	backup.PrintVerifyReport(report)
+
+	if !report.OK() {
+		return fmt.Errorf("backup verification failed: %d missing, %d corrupt, %d extra file(s)",
+			len(report.Missing), len(report.Corrupt), len(report.Extra))
+	}
+	return nil
+}
+
+// executeList handles list action
+func (c *CLI) executeList(flags map[string]interface{}) error {
+	backupFolder := flags["backupFolder"].(string)
+	appNames, _ := flags["appNames"].([]string)
+	versionName, _ := flags["version"].(string)
+	before, _ := flags["before"].(string)
+
+	if versionName == "" && before == "" {
+		if err := backup.PrintVersionList(backupFolder); err != nil {
+			return fmt.Errorf("failed to list backup versions: %w", err)
+		}
+		return nil
+	}
+
+	versionPath, isZip, err := backup.FindVersion(backupFolder, versionName, before)
+	if err != nil {
+		return fmt.Errorf("cannot find backup version to list: %w", err)
+	}
+	if err := backup.PrintVersionContents(versionPath, isZip, appNames); err != nil {
+		return fmt.Errorf("failed to list backup version contents: %w", err)
+	}
+	return nil
+}
+
+// executePrune handles prune action
+func (c *CLI) executePrune(flags map[string]interface{}) error {
+	dryRun := flags["dryRun"].(bool)
+	util.DryRun = dryRun
+	backup.DryRun = dryRun
+
+	retention, _ := flags["retention"].(backup.RetentionPolicy)
+	if err := backup.Prune(flags["backupFolder"].(string), retention); err != nil {
+		return fmt.Errorf("failed to prune backup versions: %w", err)
+	}
+	return nil
+}
+
+// executeDiff handles diff action
+func (c *CLI) executeDiff(flags map[string]interface{}) error {
+	version, _ := flags["version"].(string)
+	before, _ := flags["before"].(string)
+	target, _ := flags["target"].(string)
+
+	diffs, versionName, err := backup.CompareWithVersion(backup.Options{
+		ConfigFolder: flags["configFolder"].(string),
+		BackupFolder: flags["backupFolder"].(string),
+		AppNames:     flags["appNames"].([]string),
+		Password:     flags["password"].(string),
+		Version:      version,
+		Before:       before,
+		Target:       target,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compare with backup: %w", err)
+	}
+	backup.PrintDiffs(diffs, versionName)
+
+	failed := 0
+	for _, d := range diffs {
+		if d.Status == backup.DiffError {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) could not be compared", failed)
+	}
 	return nil
 }
 
@@ -229,6 +618,13 @@ func (c *CLI) ShowHelp() {
 	c.logger.Logf("Actions:")
 	c.logger.Logf("  backup      - Backup configuration files to the specified backup folder")
 	c.logger.Logf("  restore     - Restore the files to their original locations")
+	c.logger.Logf("  undo        - Revert the last restore using the snapshot it saved")
+	c.logger.Logf("  trust       - Review the commands of each config and approve new or changed ones with -approve")
+	c.logger.Logf("  diff        - Show how current files differ from a backup version (what restore would change)")
+	c.logger.Logf("  list        - List backup versions, or the files of one version with -version")
+	c.logger.Logf("  verify      - Check a backup version (latest by default) against its manifest")
+	c.logger.Logf("                You can provide a version name as parameter (e.g., '20250101-120000')")
+	c.logger.Logf("  prune       - Remove the backup versions not kept by the -versions and -keep-* rules")
 	c.logger.Logf("  configsinit - Extract embedded default configs to a 'configs' directory next to the executable")
 	c.logger.Logf("  install     - Install the application as a CRON job that runs at every reboot")
 	c.logger.Logf("                You can provide a valid cron expression as parameter (e.g., '0 9 * * *')")
@@ -242,10 +638,33 @@ func (c *CLI) ShowHelp() {
 	c.logger.Logf("  -allow-commands       [SECURITY WARNING] Allow execution of pre/post backup/restore commands")
 	c.logger.Logf("                        Commands execute with full user privileges. Only enable for trusted configs!")
 	c.logger.Logf("  -dry-run              Perform a dry run without making any changes")
-	c.logger.Logf("  -versions=<n>         Number of backup versions to keep (default: 1, 0 = keep all)")
+	c.logger.Logf("  -versions=<n>         Keep the newest <n> backup versions; 0 = no limit (default: 1 for a backup without -keep-* rules)")
+	c.logger.Logf("  -keep-daily=<n>       Also keep the newest version of each of the last <n> days (backup and prune)")
+	c.logger.Logf("  -keep-weekly=<n>      Also keep the newest version of each of the last <n> weeks (backup and prune)")
+	c.logger.Logf("  -keep-monthly=<n>     Also keep the newest version of each of the last <n> months (backup and prune)")
+	c.logger.Logf("  -keep-within=<d>      Also keep every version newer than <d>, e.g. 30d, 4w or 36h (backup and prune)")
+	c.logger.Logf("                        A version is kept when any of -versions and -keep-* keeps it; with -keep-* rules,")
+	c.logger.Logf("                        -versions only counts when it is given")
 	c.logger.Logf("  -zip                  Create backup as a zip archive instead of a directory")
+	c.logger.Logf("  -dedup                Store each file content once, shared by all versions (backup only)")
+	c.logger.Logf("  -incremental          Link files unchanged since the previous version instead of copying them (backup only)")
+	c.logger.Logf("  -skip-unchanged       Do not keep a new version when nothing changed since the latest one (backup only)")
 	c.logger.Logf("  -password=<pwd>       Password to encrypt/decrypt backups (AES-256-GCM)")
 	c.logger.Logf("  -logfile=<path>       Path to log file (logs to console + file if provided)")
+	c.logger.Logf("  -logformat=<format>   Format of the log file: text (default) or json (one JSON object per line)")
+	c.logger.Logf("  -logmaxsize=<MB>      Rotate the log file once it reaches this size (default: 0, no limit)")
+	c.logger.Logf("  -logmaxage=<days>     Rotate the log file once its first entry is this old (default: 0, no limit)")
+	c.logger.Logf("  -logkeep=<n>          Number of rotated log files to keep (default: 5)")
+	c.logger.Logf("  -logcompress          Gzip rotated log files")
+	c.logger.Logf("  -verbose              Also log debug messages")
+	c.logger.Logf("  -quiet                Only log warnings and errors")
+	c.logger.Logf("  -version=<selector>   Version to restore, diff, list or verify: YYYYMMDD-HHMMSS, latest or latest~N (default: latest)")
+	c.logger.Logf("  -before=<YYYY-MM-DD>  Only consider versions created before this date")
+	c.logger.Logf("  -target=<dir>         Restore into <dir> instead of the home directory (restore commands are skipped)")
+	c.logger.Logf("  -report=<path>        Write a JSON report of the backup or restore to <path>")
+	c.logger.Logf("  -output=<format>      Output of backup and restore: text (default) or json (report on stdout)")
+	c.logger.Logf("  -approve              Approve the new and changed commands shown by trust")
+	c.logger.Logf("  -on-hook-failure=<p>  When a command fails: continue, skip-app or abort-run (default: skip-app)")
 	c.logger.Logf("")
 	c.logger.Logf("Environment Variables:")
 	c.logger.Logf("  SETTINGSSENTRY_CONFIG      Path to configuration folder")
@@ -255,12 +674,24 @@ func (c *CLI) ShowHelp() {
 	c.logger.Logf("  SETTINGSSENTRY_DRY_RUN     Set to 'true' for dry-run mode")
 	c.logger.Logf("  SETTINGSSENTRY_ZIP         Set to 'true' to create zip archives")
 	c.logger.Logf("  SETTINGSSENTRY_PASSWORD    Password for encryption/decryption")
+	c.logger.Logf("  SETTINGSSENTRY_SKIP_UNCHANGED  Set to 'true' to skip backups identical to the latest version")
 	c.logger.Logf("")
 	c.logger.Logf("Examples:")
 	c.logger.Logf("  settingssentry backup")
 	c.logger.Logf("  settingssentry backup -dry-run")
 	c.logger.Logf("  settingssentry backup -app=Brew,Git -zip -password=mypass")
 	c.logger.Logf("  settingssentry restore -app=Brew")
+	c.logger.Logf("  settingssentry backup -output=json > report.json")
+	c.logger.Logf("  settingssentry restore -version=latest~1")
+	c.logger.Logf("  settingssentry restore -before=2025-06-01")
+	c.logger.Logf("  settingssentry restore -target=/tmp/old-settings")
+	c.logger.Logf("  settingssentry undo")
+	c.logger.Logf("  settingssentry trust -app=Brew -approve")
+	c.logger.Logf("  settingssentry diff -app=Git -version=latest~1")
+	c.logger.Logf("  settingssentry list")
+	c.logger.Logf("  settingssentry list -version=latest -app=Git")
+	c.logger.Logf("  settingssentry verify -password=mypass")
+	c.logger.Logf("  settingssentry prune -keep-daily=7 -keep-weekly=4 -keep-monthly=12 -dry-run")
 	c.logger.Logf("  settingssentry install --allow-commands")
 	c.logger.Logf("  settingssentry install '0 9 * * *'  # Daily at 9 AM")
 	c.logger.Logf("")
@@ -269,7 +700,7 @@ func (c *CLI) ShowHelp() {
 
 	installed, err := cronjob.IsCronJobInstalled()
 	if err != nil {
-		c.logger.Logf("Error checking CRON job installation: %v", err)
+		c.logger.Errorf("failed to check CRON job installation: %v", err)
 		return
 	}
 
@@ -282,7 +713,7 @@ func (c *CLI) ShowHelp() {
 
 // isValidAction checks if the action is valid
 func isValidAction(action string) bool {
-	validActions := []string{"backup", "restore", "configsinit", "install", "remove"}
+	validActions := []string{"backup", "restore", "undo", "trust", "diff", "list", "verify", "prune", "configsinit", "install", "remove"}
 	for _, valid := range validActions {
 		if action == valid {
 			return true
@@ -291,6 +722,22 @@ func isValidAction(action string) bool {
 	return false
 }
 
+// jsonOutputRequested reports whether args ask for -output=json, before the
+// flags are parsed, so that even early log lines stay off stdout.
+func jsonOutputRequested(args []string) bool {
+	for i, arg := range args {
+		switch arg {
+		case "-output=json", "--output=json":
+			return true
+		case "-output", "--output":
+			if i+1 < len(args) && args[i+1] == "json" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // getEnvWithDefault gets an environment variable with a default value
 func getEnvWithDefault(key, defaultValue string) string {
 	value := os.Getenv(key)
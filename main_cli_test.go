@@ -3,12 +3,17 @@ package main
 import (
 	"SettingsSentry/interfaces"
 	"SettingsSentry/logger"
+	"SettingsSentry/pkg/backup"
+	"SettingsSentry/pkg/config"
 	"SettingsSentry/pkg/testutil"
+	"bytes"
 	"embed"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 //go:embed configs/*.cfg
@@ -634,6 +639,156 @@ func TestExecuteAction_InvalidAction(t *testing.T) {
 	}
 }
 
+// TestExecuteAction_BackupExitCodes tests that backup failures map to exit codes
+func TestExecuteAction_BackupExitCodes(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+	backup.AppLogger = testLogger
+	backup.Fs = cli.fs
+	config.AppLogger = testLogger
+	config.Fs = cli.fs
+
+	homeDir := t.TempDir()
+	originalGetHomeDir := config.GetHomeDirectory
+	config.GetHomeDirectory = func() (string, error) { return homeDir, nil }
+	defer func() { config.GetHomeDirectory = originalGetHomeDir }()
+	if err := os.WriteFile(filepath.Join(homeDir, ".good"), []byte("good"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		files string
+		want  int
+	}{
+		{"success", ".good\n", exitOK},
+		// A path below a regular file cannot be accessed
+		{"partial failure", ".good\n.good/child\n", exitPartial},
+		{"total failure", ".good/child\n", exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configDir := t.TempDir()
+			cfg := "[application]\nname = App\n[configuration_files]\n" + tt.files
+			if err := os.WriteFile(filepath.Join(configDir, "app.cfg"), []byte(cfg), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := cli.ExecuteAction("backup", map[string]interface{}{
+				"configFolder":   configDir,
+				"backupFolder":   t.TempDir(),
+				"appNames":       []string{},
+				"commands":       false,
+				"dryRun":         false,
+				"versionsToKeep": 1,
+				"zip":            false,
+				"password":       "",
+			})
+			if got := exitCode(err); got != tt.want {
+				t.Errorf("exitCode = %d, want %d (err: %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+// TestExecuteAction_BackupReport tests the JSON report of -report and -output=json
+func TestExecuteAction_BackupReport(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+	backup.AppLogger = testLogger
+	backup.Fs = cli.fs
+	config.AppLogger = testLogger
+	config.Fs = cli.fs
+
+	homeDir := t.TempDir()
+	originalGetHomeDir := config.GetHomeDirectory
+	config.GetHomeDirectory = func() (string, error) { return homeDir, nil }
+	defer func() { config.GetHomeDirectory = originalGetHomeDir }()
+
+	configDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(homeDir, ".good"), []byte("good"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "app.cfg"), []byte("[application]\nname = App\n[configuration_files]\n.good\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout strings.Builder
+	cli.stdout = &stdout
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	err := cli.ExecuteAction("backup", map[string]interface{}{
+		"configFolder":   configDir,
+		"backupFolder":   t.TempDir(),
+		"appNames":       []string{},
+		"commands":       false,
+		"dryRun":         false,
+		"versionsToKeep": 1,
+		"zip":            false,
+		"password":       "",
+		"report":         reportPath,
+		"output":         "json",
+	})
+	if err != nil {
+		t.Fatalf("ExecuteAction failed: %v", err)
+	}
+
+	written, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Report file not written: %v", err)
+	}
+	if string(written) != stdout.String() {
+		t.Errorf("Report file and stdout differ:\n%s\n%s", written, stdout.String())
+	}
+	var report backup.Report
+	if err := json.Unmarshal(written, &report); err != nil {
+		t.Fatalf("Report is not valid JSON: %v", err)
+	}
+	if report.Status != backup.RunSucceeded || len(report.Items) != 1 || report.Items[0].Source != filepath.Join(homeDir, ".good") {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestParseFlags_Output(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"restore", "-output=json", "-report=/tmp/report.json"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if flags["output"] != "json" || flags["report"] != "/tmp/report.json" {
+		t.Errorf("Unexpected flags: output=%v report=%v", flags["output"], flags["report"])
+	}
+
+	for _, args := range [][]string{
+		{"backup", "-output=yaml"},
+		{"list", "-output=json"},
+		{"verify", "-report=/tmp/report.json"},
+	} {
+		if _, _, err := cli.ParseFlags(args); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestJSONOutputRequested(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"backup", "-output=json"}, true},
+		{[]string{"backup", "--output", "json"}, true},
+		{[]string{"backup", "-output=text"}, false},
+		{[]string{"backup", "-output"}, false},
+		{[]string{"backup"}, false},
+	}
+	for _, tt := range tests {
+		if got := jsonOutputRequested(tt.args); got != tt.want {
+			t.Errorf("jsonOutputRequested(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
 // TestExecuteAction_ConfigsInit tests configsinit action
 func TestExecuteAction_ConfigsInit(t *testing.T) {
 	cli, testLogger := setupCLITest()
@@ -663,6 +818,11 @@ func TestIsValidAction(t *testing.T) {
 	}{
 		{"backup", true},
 		{"restore", true},
+		{"undo", true},
+		{"trust", true},
+		{"diff", true},
+		{"list", true},
+		{"verify", true},
 		{"configsinit", true},
 		{"install", true},
 		{"remove", true},
@@ -844,6 +1004,291 @@ func TestParseFlags_NegativeVersions(t *testing.T) {
 	}
 }
 
+// TestParseFlags_VersionSelection tests the -version and -before flags
+func TestParseFlags_VersionSelection(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"restore", "-version=latest~2", "-before=2026-09-01"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if version := flags["version"].(string); version != "latest~2" {
+		t.Errorf("version = %q, want 'latest~2'", version)
+	}
+	if before := flags["before"].(string); before != "2026-09-01" {
+		t.Errorf("before = %q, want '2026-09-01'", before)
+	}
+
+	if _, _, err := cli.ParseFlags([]string{"restore", "-before=01/09/2026"}); err == nil {
+		t.Error("Expected error for malformed -before date")
+	}
+}
+
+// TestParseFlags_Target tests that -target is limited to restore and diff
+func TestParseFlags_Target(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"restore", "-target=/tmp/seed"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if target := flags["target"].(string); target != "/tmp/seed" {
+		t.Errorf("target = %q, want '/tmp/seed'", target)
+	}
+
+	if _, _, err := cli.ParseFlags([]string{"diff", "-target=/tmp/seed"}); err != nil {
+		t.Errorf("diff should accept -target: %v", err)
+	}
+	if _, _, err := cli.ParseFlags([]string{"backup", "-target=/tmp/seed"}); err == nil {
+		t.Error("Expected error for -target with backup")
+	}
+}
+
+func TestParseFlags_OnHookFailure(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"backup", "-on-hook-failure=abort-run"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if policy := flags["onHookFailure"].(string); policy != "abort-run" {
+		t.Errorf("onHookFailure = %q, want 'abort-run'", policy)
+	}
+
+	if _, _, err := cli.ParseFlags([]string{"backup", "-on-hook-failure=ignore"}); err == nil {
+		t.Error("Expected error for unknown -on-hook-failure policy")
+	}
+}
+
+func TestParseFlags_Approve(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"trust", "-approve"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if !flags["approve"].(bool) {
+		t.Error("Expected approve to be true")
+	}
+
+	if _, _, err := cli.ParseFlags([]string{"backup", "-approve"}); err == nil {
+		t.Error("Expected error for -approve outside the trust action")
+	}
+}
+
+func TestParseFlags_Dedup(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"backup", "-dedup"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if !flags["dedup"].(bool) {
+		t.Error("Expected dedup to be true")
+	}
+
+	if _, _, err := cli.ParseFlags([]string{"backup", "-dedup", "-zip"}); err == nil {
+		t.Error("Expected error for -dedup with -zip")
+	}
+	if _, _, err := cli.ParseFlags([]string{"restore", "-dedup"}); err == nil {
+		t.Error("Expected error for -dedup outside the backup action")
+	}
+}
+
+func TestParseFlags_Incremental(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"backup", "-incremental", "-dedup"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if !flags["incremental"].(bool) {
+		t.Error("Expected incremental to be true")
+	}
+
+	if _, _, err := cli.ParseFlags([]string{"backup", "-incremental", "-zip"}); err == nil {
+		t.Error("Expected error for -incremental with -zip")
+	}
+	if _, _, err := cli.ParseFlags([]string{"restore", "-incremental"}); err == nil {
+		t.Error("Expected error for -incremental outside the backup action")
+	}
+}
+
+func TestParseFlags_SkipUnchanged(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"backup"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if flags["skipUnchanged"].(bool) {
+		t.Error("Expected skipUnchanged to be false by default")
+	}
+
+	_ = os.Setenv("SETTINGSSENTRY_SKIP_UNCHANGED", "true")
+	defer func() {
+		_ = os.Unsetenv("SETTINGSSENTRY_SKIP_UNCHANGED")
+	}()
+	if _, flags, err = cli.ParseFlags([]string{"backup"}); err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if !flags["skipUnchanged"].(bool) {
+		t.Error("Expected skipUnchanged to be true from env var")
+	}
+	if _, flags, err = cli.ParseFlags([]string{"backup", "-skip-unchanged=false"}); err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if flags["skipUnchanged"].(bool) {
+		t.Error("Expected -skip-unchanged=false to override the env var")
+	}
+}
+
+func TestParseFlags_Retention(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"backup", "-keep-daily=7", "-keep-weekly=4", "-keep-monthly=12", "-keep-within=30d"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	want := backup.RetentionPolicy{Daily: 7, Weekly: 4, Monthly: 12, Within: 30 * 24 * time.Hour}
+	if got := flags["retention"].(backup.RetentionPolicy); got != want {
+		t.Errorf("retention = %+v, want %+v", got, want)
+	}
+
+	// -versions only counts when given, unless a backup has no other rule
+	for _, tt := range []struct {
+		args []string
+		want backup.RetentionPolicy
+	}{
+		{[]string{"backup"}, backup.RetentionPolicy{Last: 1}},
+		{[]string{"backup", "-versions=0"}, backup.RetentionPolicy{}},
+		{[]string{"backup", "-keep-daily=7"}, backup.RetentionPolicy{Daily: 7}},
+		{[]string{"backup", "-versions=0", "-keep-daily=7"}, backup.RetentionPolicy{Daily: 7}},
+		{[]string{"backup", "-versions=2", "-keep-daily=7"}, backup.RetentionPolicy{Last: 2, Daily: 7}},
+		{[]string{"prune", "-versions=0", "-keep-daily=7"}, backup.RetentionPolicy{Daily: 7}},
+	} {
+		_, flags, err := cli.ParseFlags(tt.args)
+		if err != nil {
+			t.Fatalf("ParseFlags(%v) failed: %v", tt.args, err)
+		}
+		if got := flags["retention"].(backup.RetentionPolicy); got != tt.want {
+			t.Errorf("ParseFlags(%v) retention = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+
+	// prune only uses -versions when it is given
+	if _, flags, err = cli.ParseFlags([]string{"prune", "-keep-daily=7"}); err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if got := flags["retention"].(backup.RetentionPolicy); got.Last != 0 {
+		t.Errorf("Expected prune to ignore the default of -versions, got %+v", got)
+	}
+	if _, flags, err = cli.ParseFlags([]string{"prune", "-versions=3"}); err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if got := flags["retention"].(backup.RetentionPolicy); got.Last != 3 {
+		t.Errorf("Expected prune to keep the last 3 versions, got %+v", got)
+	}
+
+	for _, args := range [][]string{
+		{"prune"},
+		{"prune", "-versions=0"},
+		{"backup", "-keep-daily=-1"},
+		{"backup", "-keep-within=month"},
+		{"restore", "-keep-weekly=4"},
+	} {
+		if _, _, err := cli.ParseFlags(args); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_Logging(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"backup", "-verbose", "-logformat=json"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if !flags["verbose"].(bool) || flags["quiet"].(bool) {
+		t.Errorf("Unexpected verbose/quiet flags: %v/%v", flags["verbose"], flags["quiet"])
+	}
+	if format := flags["logFormat"].(string); format != "json" {
+		t.Errorf("logFormat = %q, want 'json'", format)
+	}
+
+	if _, _, err := cli.ParseFlags([]string{"backup", "-verbose", "-quiet"}); err == nil {
+		t.Error("Expected error for -verbose with -quiet")
+	}
+	if _, _, err := cli.ParseFlags([]string{"backup", "-logformat=xml"}); err == nil {
+		t.Error("Expected error for unknown -logformat")
+	}
+}
+
+func TestParseFlags_LogRotation(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	_, flags, err := cli.ParseFlags([]string{"backup", "-logmaxsize=10", "-logmaxage=7", "-logkeep=3", "-logcompress"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if flags["logMaxSize"].(int) != 10 || flags["logMaxAge"].(int) != 7 || flags["logKeep"].(int) != 3 || !flags["logCompress"].(bool) {
+		t.Errorf("Unexpected rotation flags: %v", flags)
+	}
+
+	_, flags, err = cli.ParseFlags([]string{"backup"})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if flags["logKeep"].(int) != 5 {
+		t.Errorf("logKeep = %v, want 5", flags["logKeep"])
+	}
+
+	if _, _, err := cli.ParseFlags([]string{"backup", "-logkeep=-1"}); err == nil {
+		t.Error("Expected error for negative -logkeep")
+	}
+}
+
+func TestConfigureLogger(t *testing.T) {
+	cli, testLogger := setupCLITest()
+	defer testLogger.Close()
+
+	var output bytes.Buffer
+	testLogger.SetCliLoggerOutput(&output)
+	logFile := filepath.Join(t.TempDir(), "run.log")
+
+	_, flags, err := cli.ParseFlags([]string{"backup", "-quiet", "-logfile=" + logFile})
+	if err != nil {
+		t.Fatalf("ParseFlags failed: %v", err)
+	}
+	if err := cli.ConfigureLogger(flags); err != nil {
+		t.Fatalf("ConfigureLogger failed: %v", err)
+	}
+
+	testLogger.Logf("hidden")
+	testLogger.Warnf("shown")
+	if got := output.String(); strings.Contains(got, "hidden") || !strings.Contains(got, "shown") {
+		t.Errorf("Expected only the warning with -quiet, got %q", got)
+	}
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Expected -logfile to be written: %v", err)
+	}
+	if !strings.Contains(string(content), "Warning: shown") {
+		t.Errorf("Unexpected log file content: %q", content)
+	}
+}
+
 // TestParseFlags_EmptyAppNames tests that empty app names are filtered out
 func TestParseFlags_EmptyAppNames(t *testing.T) {
 	cli, testLogger := setupCLITest()
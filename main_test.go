@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -17,4 +19,21 @@ func TestMain(m *testing.M) {
 	// and flag parsing constraints. Coverage for main logic is achieved through
 	// testing the run() function's components and integration tests.
 	m.Run()
-}
\ No newline at end of file
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errors.New("boom"), exitFailure},
+		{&exitError{code: exitUsage, err: errors.New("bad flag")}, exitUsage},
+		{fmt.Errorf("wrapped: %w", &exitError{code: exitPartial, err: errors.New("1 failed")}), exitPartial},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -7,11 +7,13 @@ import (
 	"SettingsSentry/pkg/config"
 	"SettingsSentry/pkg/printer"
 	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 )
@@ -33,136 +35,34 @@ func GetLatestVersionPath(baseBackupPath string) (path string, isZip bool, err e
 		return "", false, err
 	}
 
-	// Read the directory entries
-	entries, err := Fs.ReadDir(baseBackupPath)
+	versions, err := ListVersions(baseBackupPath)
 	if err != nil {
 		err = AppLogger.LogErrorf("failed to read backup directory: %w", err)
 		return "", false, err
 	}
-	var latestPath string
-	var latestIsZip bool
-	var latestTime time.Time
-	foundVersion := false
-
-	for _, entry := range entries {
-		entryName := entry.Name()
-		isDir := entry.IsDir()
-		isZipFile := !isDir && strings.HasSuffix(entryName, ".zip")
-		timestampStr := ""
-
-		if isDir {
-			timestampStr = entryName
-		} else if isZipFile {
-			timestampStr = strings.TrimSuffix(entryName, ".zip")
-		} else {
-			continue
-		}
-
-		t, parseErr := time.Parse("20060102-150405", timestampStr)
-		if parseErr != nil {
-			continue
-		}
 
-		if !foundVersion || t.After(latestTime) {
-			latestPath = Fs.Join(baseBackupPath, entryName)
-			latestIsZip = isZipFile
-			latestTime = t
-			foundVersion = true
-		}
-	}
-
-	if !foundVersion {
+	if len(versions) == 0 {
 		err = AppLogger.LogErrorf("no version backups found in %s", baseBackupPath)
 		return "", false, err
 	}
 
-	return latestPath, latestIsZip, nil
+	return versions[0].Path, versions[0].IsZip, nil
 }
 
-// CleanupOldVersions removes old versions to keep only the specified number
+// CleanupOldVersions removes old versions to keep only the specified number,
+// then the objects of deduplicated versions that no version references anymore.
 func CleanupOldVersions(baseBackupPath string, maxVersions int) error {
 	if maxVersions <= 0 {
 		return nil
 	}
-
-	_, err := Fs.Stat(baseBackupPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return AppLogger.LogErrorf("failed to stat backup path for cleanup: %w", err)
-	}
-
-	entries, err := Fs.ReadDir(baseBackupPath)
-	if err != nil {
-		return AppLogger.LogErrorf("failed to read backup directory for cleanup: %w", err)
-	}
-
-	type versionInfo struct {
-		path      string
-		timestamp time.Time
-		isZip     bool
-	}
-	var versions []versionInfo
-
-	for _, entry := range entries {
-		entryName := entry.Name()
-		isDir := entry.IsDir()
-		isZipFile := !isDir && strings.HasSuffix(entryName, ".zip")
-		timestampStr := ""
-
-		if isDir {
-			timestampStr = entryName
-		} else if isZipFile {
-			timestampStr = strings.TrimSuffix(entryName, ".zip")
-		} else {
-			continue
-		}
-
-		t, parseErr := time.Parse("20060102-150405", timestampStr)
-		if parseErr != nil {
-			continue
-		}
-
-		versions = append(versions, versionInfo{
-			path:      Fs.Join(baseBackupPath, entryName),
-			timestamp: t,
-			isZip:     isZipFile,
-		})
-	}
-
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[i].timestamp.After(versions[j].timestamp)
-	})
-
-	if len(versions) > maxVersions {
-		for i := maxVersions; i < len(versions); i++ {
-			_, statErr := Fs.Stat(versions[i].path)
-			if statErr != nil {
-				if os.IsNotExist(statErr) {
-					AppLogger.Logf("Skipping version that no longer exists: %s", versions[i].path)
-					continue
-				}
-				AppLogger.Logf("Error stating old version %s: %v", versions[i].path, statErr)
-				continue
-			}
-			if DryRun {
-				AppLogger.Logf("Would remove old version: %s", versions[i].path)
-			} else {
-				AppLogger.Logf("Removing old version: %s", versions[i].path)
-				err := Fs.RemoveAll(versions[i].path)
-				if err != nil {
-					AppLogger.Logf("Failed to remove old version %s: %v", versions[i].path, err)
-				}
-			}
-		}
-	}
-
-	return nil
+	_, err := ApplyRetention(baseBackupPath, RetentionPolicy{Last: maxVersions})
+	return err
 }
 
-// copyFile copies a single file from src to dst.
-func copyFile(src, dst string) error {
+// copyFileWithChecksum copies a single file from src to dst, with its mode and
+// modification time, and returns the hex encoded SHA-256 checksum of the copied
+// content.
+func copyFileWithChecksum(src, dst string) (string, error) {
 	if Fs == nil {
 		panic("Fs is nil in copyFile!")
 	}
@@ -171,72 +71,49 @@ func copyFile(src, dst string) error {
 	}
 	srcFile, err := Fs.Open(src)
 	if err != nil {
-		AppLogger.Logf("copyFile: Fs.Open failed for '%s': %v", src, err)
-		return AppLogger.LogErrorf("failed to open source file '%s': %w", src, err)
+		AppLogger.Debugf("copyFile: Fs.Open failed for '%s': %v", src, err)
+		return "", AppLogger.LogErrorf("failed to open source file '%s': %w", src, err)
 	}
 	defer func() {
 		if err := srcFile.Close(); err != nil {
-			AppLogger.Logf("Error closing source file %s: %v", src, err)
+			AppLogger.Errorf("failed to close source file %s: %v", src, err)
 		}
 	}()
 
-	err = Fs.MkdirAll(Fs.Dir(dst), 0755)
+	srcInfo, err := Fs.Stat(src)
 	if err != nil {
-		return AppLogger.LogErrorf("failed to create destination directory '%s': %w", Fs.Dir(dst), err)
+		return "", AppLogger.LogErrorf("failed to get source file info '%s': %w", src, err)
 	}
 
-	dstFile, err := Fs.Create(dst)
+	err = Fs.MkdirAll(Fs.Dir(dst), 0755)
 	if err != nil {
-		return AppLogger.LogErrorf("failed to create destination file '%s': %w", dst, err)
+		return "", AppLogger.LogErrorf("failed to create destination directory '%s': %w", Fs.Dir(dst), err)
 	}
-	defer func() {
-		if err := dstFile.Close(); err != nil {
-			AppLogger.Logf("Error closing destination file %s: %v", dst, err)
-		}
-	}()
 
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		return AppLogger.LogErrorf("failed to copy file contents from '%s' to '%s': %w", src, dst, err)
+	// Created with the source permissions so the copy is never readable by more
+	// users than the source, even before its exact mode is applied
+	if err := prepareOverwrite(dst, srcInfo.Mode().Perm()); err != nil {
+		return "", AppLogger.LogErrorf("failed to replace destination file '%s': %w", dst, err)
 	}
-	return nil
-}
-
-// copyDirectory recursively copies a directory from src to dst.
-func copyDirectory(src, dst string) error {
-	srcInfo, err := Fs.Stat(src)
+	dstFile, err := Fs.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, srcInfo.Mode().Perm())
 	if err != nil {
-		return AppLogger.LogErrorf("failed to get source directory info '%s': %w", src, err)
+		return "", AppLogger.LogErrorf("failed to create destination file '%s': %w", dst, err)
 	}
 
-	err = Fs.MkdirAll(dst, srcInfo.Mode())
-	if err != nil {
-		return AppLogger.LogErrorf("failed to create destination directory '%s': %w", dst, err)
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(dstFile, hash), srcFile)
+	if closeErr := dstFile.Close(); closeErr != nil && err == nil {
+		err = closeErr
 	}
-
-	entries, err := Fs.ReadDir(src)
 	if err != nil {
-		return AppLogger.LogErrorf("failed to read source directory '%s': %w", src, err)
+		return "", AppLogger.LogErrorf("failed to copy file contents from '%s' to '%s': %w", src, dst, err)
 	}
 
-	for _, entry := range entries {
-		srcPath := Fs.Join(src, entry.Name())
-		dstPath := Fs.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			err = copyDirectory(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
-		} else {
-			err = copyFile(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
-		}
+	// Applied once the content is written, so the copy keeps the source mtime
+	if err := preserveMetadata(dst, srcInfo.Mode(), srcInfo.ModTime()); err != nil {
+		return "", AppLogger.LogErrorf("failed to copy '%s' to '%s': %w", src, dst, err)
 	}
-
-	return nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // sanitizeConfigName sanitizes a configuration name to prevent path traversal attacks.
@@ -256,57 +133,183 @@ func sanitizeConfigName(name string) string {
 	// Additional security: if the cleaned name starts with "..", replace entirely
 	// This catches cases like "../../../../etc" which Clean() may not fully sanitize
 	if strings.HasPrefix(cleaned, "..") {
-		AppLogger.Logf("Warning: Config name '%s' contains path traversal sequences, using sanitized name 'unknown'", name)
+		AppLogger.Warnf("Config name '%s' contains path traversal sequences, using sanitized name 'unknown'", name)
 		return "unknown"
 	}
 
 	// If the name was modified, log a warning
 	if cleaned != name {
-		AppLogger.Logf("Warning: Config name '%s' was sanitized to '%s' to prevent path traversal", name, cleaned)
+		AppLogger.Warnf("Config name '%s' was sanitized to '%s' to prevent path traversal", name, cleaned)
 	}
 
 	return cleaned
 }
 
+// Options holds the settings of a backup or restore run.
+type Options struct {
+	ConfigFolder   string
+	BackupFolder   string
+	AppNames       []string
+	IsBackup       bool
+	Commands       bool
+	VersionsToKeep int
+	ZipBackup      bool
+	Password       string
+	// Dedup stores each file content once in the object store of the backup
+	// folder; versions then only hold their manifest. Backup only, and not
+	// with ZipBackup.
+	Dedup bool
+	// Incremental links or references the files that did not change since
+	// the previous version instead of copying them. Backup only, and not
+	// with ZipBackup.
+	Incremental bool
+	// SkipUnchanged does not keep the new version when its files are
+	// identical to those of the latest version. Ignored on restore.
+	SkipUnchanged bool
+	// Retention decides which versions are kept after a backup. When it has
+	// any rule it replaces VersionsToKeep, so its Last field holds the number
+	// of newest versions to keep, if any.
+	Retention RetentionPolicy
+	// Version selects the version to restore from: empty or "latest" for the
+	// newest one, "latest~N", or an exact timestamp. See SelectVersion.
+	Version string
+	// Before restricts restore to versions created before this date (YYYY-MM-DD).
+	Before string
+	// Target restores into this directory instead of the home directory.
+	// Restore commands are not run when it is set.
+	Target string
+	// OnHookFailure is the hook failure policy of apps that do not set
+	// on_hook_failure themselves. Defaults to skip-app.
+	OnHookFailure string
+}
+
 // ProcessConfiguration processes configuration files for backup or restore.
-// Accepts a slice of app names to process specific applications.
-func ProcessConfiguration(configFolder, backupFolder string, appNames []string, isBackup bool, commands bool, versionsToKeep int, zipBackup bool, password string) {
+// Accepts a slice of app names to process specific applications. See Process
+// for the result and error.
+func ProcessConfiguration(configFolder, backupFolder string, appNames []string, isBackup bool, commands bool, versionsToKeep int, zipBackup bool, password string) (*Result, error) {
+	return Process(Options{
+		ConfigFolder:   configFolder,
+		BackupFolder:   backupFolder,
+		AppNames:       appNames,
+		IsBackup:       isBackup,
+		Commands:       commands,
+		VersionsToKeep: versionsToKeep,
+		ZipBackup:      zipBackup,
+		Password:       password,
+	})
+}
+
+// Process runs a backup or restore with the given options. The result holds
+// the outcome of every file, command phase and command output; it is nil when
+// the run could not be started. The error is set when the run could not be
+// started, was aborted, or when any item failed.
+func Process(opts Options) (*Result, error) {
+	started := time.Now()
+	isBackup := opts.IsBackup
+	commands := opts.Commands
+
 	// Create backup context
-	ctx, err := NewBackupContext(configFolder, backupFolder, appNames, isBackup, commands, versionsToKeep, zipBackup, password)
+	ctx, err := NewBackupContext(opts.ConfigFolder, opts.BackupFolder, opts.AppNames, isBackup, commands, opts.VersionsToKeep, opts.ZipBackup, opts.Password)
 	if err != nil {
-		AppLogger.Logf("Error creating backup context: %v", err)
-		return
+		return nil, fmt.Errorf("error creating backup context: %w", err)
+	}
+	if opts.Dedup {
+		if !isBackup || opts.ZipBackup {
+			return nil, fmt.Errorf("deduplicated storage is only available for directory backups")
+		}
+		ctx.Dedup = true
+		ctx.Manifest = newManifest(ctx)
+	}
+	if opts.Incremental {
+		if !isBackup || opts.ZipBackup {
+			return nil, fmt.Errorf("incremental backups are only available for directory backups")
+		}
+		ctx.Incremental = true
+		ctx.Manifest = newManifest(ctx)
+	}
+	ctx.SkipUnchanged = opts.SkipUnchanged && isBackup
+	ctx.Retention = opts.Retention
+	if !isBackup {
+		if ctx.TargetDir, err = resolveTargetDir(opts.Target); err != nil {
+			return nil, err
+		}
+		if ctx.TargetDir != "" {
+			AppLogger.Logf("Restoring into target directory: %s", ctx.TargetDir)
+		}
+	}
+
+	if commands {
+		if ctx.Trust, err = LoadTrustStore(trustStorePath(ctx.HomeDir)); err != nil {
+			return nil, err
+		}
 	}
 
 	// Setup backup directory
 	if err := ctx.SetupBackupDirectory(); err != nil {
-		AppLogger.Logf("Error setting up backup directory: %v", err)
-		return
+		return nil, fmt.Errorf("error setting up backup directory: %w", err)
 	}
+	defer ctx.unlockBackupFolder()
 
 	// Cleanup staging directory if created
 	if ctx.StagingDir != "" {
 		defer func() {
-			AppLogger.Logf("Cleaning up staging directory: %s", ctx.StagingDir)
+			AppLogger.Debugf("Cleaning up staging directory: %s", ctx.StagingDir)
 			if err := Fs.RemoveAll(ctx.StagingDir); err != nil {
-				AppLogger.Logf("Error removing staging directory %s: %v", ctx.StagingDir, err)
+				AppLogger.Errorf("failed to remove staging directory %s: %v", ctx.StagingDir, err)
 			}
 		}()
 	}
 
+	if ctx.Incremental {
+		if err := ctx.loadPreviousVersion(); err != nil {
+			return nil, err
+		}
+		if ctx.Previous != nil {
+			defer func() {
+				if err := ctx.Previous.Close(); err != nil {
+					AppLogger.Errorf("failed to close backup version %s: %v", ctx.Previous.Path, err)
+				}
+			}()
+		}
+	}
+
 	// Load config files
 	currentFS, files, err := ctx.LoadConfigFiles()
 	if err != nil {
-		AppLogger.Logf("%v", err)
-		return
+		return nil, err
 	}
 
-	configReadDir := ctx.ConfigFolder
+	// Open the version to restore from once for all apps
+	var version *backupVersion
+	if !isBackup {
+		selected, err := SelectVersion(ctx.BackupFolder, opts.Version, opts.Before)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select version to restore: %w", err)
+		}
+		versionPath := selected.Path
+		AppLogger.Logf("Restoring from backup version %s", selected.Name)
+		version, err = openBackupVersion(versionPath, selected.IsZip)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open backup version '%s': %w", versionPath, err)
+		}
+		defer func() {
+			if err := version.Close(); err != nil {
+				AppLogger.Errorf("failed to close backup version %s: %v", versionPath, err)
+			}
+		}()
+	}
+
+	ctx.Result = &Result{IsBackup: isBackup, Version: ctx.Timestamp, Started: started}
+	if version != nil {
+		ctx.Result.Version = Fs.Base(version.Path)
+	}
+	defer func() { ctx.Result.Finished = time.Now() }()
 
 	// Filter config files based on app names
 	filteredFiles := ctx.FilterConfigFiles(files)
-	
+
 	foundCfg := false
+	aborted := false
 	for _, file := range filteredFiles {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".cfg") {
 			continue
@@ -315,7 +318,7 @@ func ProcessConfiguration(configFolder, backupFolder string, appNames []string,
 
 		cfg, err := config.ParseConfig(currentFS, file.Name())
 		if err != nil {
-			AppLogger.Logf("Error parsing config file '%s': %v", file.Name(), err)
+			AppLogger.Errorf("failed to parse config file '%s': %v", file.Name(), err)
 			continue
 		}
 
@@ -323,402 +326,258 @@ func ProcessConfiguration(configFolder, backupFolder string, appNames []string,
 		cfg.Name = sanitizeConfigName(cfg.Name)
 
 		if Printer == nil {
-			AppLogger.Logf("Error: Printer is not initialized.")
+			AppLogger.Errorf("Printer is not initialized.")
 			continue
 		}
 		Printer.Reset()
 		Printer.SetAppName(cfg.Name)
+		ctx.Excludes = cfg.Excludes
 
-		// --- Pre-load zip content map for restore operations ---
-		var zipFileMap map[string]bool
-		var zipReader *zip.ReadCloser
-		var latestVersionForCfg string
-		var latestIsZipForCfg bool
-
-		if !isBackup {
-			var findErr error
-			latestVersionForCfg, latestIsZipForCfg, findErr = GetLatestVersionPath(backupFolder)
-			if findErr != nil {
-				AppLogger.Logf("Failed to find latest version in '%s' for app '%s': %v", backupFolder, cfg.Name, findErr)
-				continue
-			}
+		policy := cfg.OnHookFailure
+		if policy == "" {
+			policy = opts.OnHookFailure
+		}
+		if policy == "" {
+			policy = config.HookFailureSkipApp
+		}
 
-			if latestIsZipForCfg {
-				var openErr error
-				zipReader, openErr = zip.OpenReader(latestVersionForCfg)
-				if openErr != nil {
-					AppLogger.Logf("Failed to open zip backup '%s' for app '%s': %v", latestVersionForCfg, cfg.Name, openErr)
-					continue
-				}
-				defer func() {
-					if zipReader != nil {
-						if err := zipReader.Close(); err != nil {
-							AppLogger.Logf("Error closing zip reader for %s: %v", latestVersionForCfg, err)
-						}
-					}
-				}()
-
-				zipFileMap = make(map[string]bool)
-				for _, f := range zipReader.File {
-					zipFileMap[f.Name] = true
-				}
-			}
+		if ctx.processApp(cfg, ctx.configFilePath(file.Name()), version, policy) {
+			aborted = true
+			break
 		}
-		// --- End pre-load ---
+	}
 
-		if isBackup && commands {
-			for _, backupCmd := range cfg.PreBackupCommands {
-				if DryRun {
-					Printer.Print("Would execute pre-backup command: %s", backupCmd)
-					continue
-				}
-				err := command.SafeExecute("pre-backup command execution", func() error {
-					if !command.ExecuteCommandLine(backupCmd) {
-						return AppLogger.LogErrorf("command execution failed")
-					}
-					return nil
-				})
-				if err != nil {
-					AppLogger.Logf("Failed to execute pre-backup command: %v", err)
-				}
-			}
-			for _, backupCmd := range cfg.PostBackupCommands {
-				if DryRun {
-					Printer.Print("Would execute post-backup command: %s", backupCmd)
-					continue
-				}
-				err := command.SafeExecute("post-backup command execution", func() error {
-					if !command.ExecuteCommandLine(backupCmd) {
-						return AppLogger.LogErrorf("command execution failed")
-					}
-					return nil
-				})
-				if err != nil {
-					AppLogger.Logf("Failed to execute post-backup command: %v", err)
-				}
-			}
+	if !foundCfg {
+		AppLogger.Warnf("No .cfg files found to process in %s.", ctx.ConfigFolder)
+	}
+
+	if ctx.Snapshot != nil {
+		AppLogger.Logf("Previous files saved to %s; run 'settingssentry undo' to revert this restore", ctx.snapshotDir())
+		if _, err := pruneSnapshots(ctx.BackupFolder); err != nil {
+			AppLogger.Errorf("failed to clean up old pre-restore snapshots: %v", err)
 		}
+	}
 
-		for _, configFile := range cfg.Files {
-			configFile = ctx.ResolveConfigFilePath(configFile)
+	result := ctx.Result
+	if aborted {
+		result.Aborted = true
+		if isBackup {
+			ctx.DiscardVersion()
+			return result, errors.New("backup aborted after a failed command (on_hook_failure = abort-run); no version was written")
+		}
+		return result, errors.New("restore aborted after a failed command (on_hook_failure = abort-run)")
+	}
 
-			var versionedBackupPath string
-			var latestVersion string
-			var targetPath string
+	if ctx.Previous != nil {
+		AppLogger.Logf("Incremental backup: %d file(s) unchanged since %s", ctx.unchangedFiles, Fs.Base(ctx.Previous.Path))
+	}
 
-			var latestIsZip bool
+	if latest, ok := ctx.unchangedSinceLatest(); ok {
+		result.Version = latest
+		result.Unchanged = true
+		if err := ctx.discardUnchanged(latest); err != nil {
+			result.NotWritten = true
+			return result, err
+		}
+		return result, nil
+	}
 
-			if ctx.IsBackup {
-				latestIsZip = ctx.ZipBackup
-				if ctx.ZipBackup {
-					targetPath = Fs.Join(ctx.StagingDir, cfg.Name, Fs.Base(configFile))
-				} else {
-					targetPath = Fs.Join(ctx.BackupFolder, ctx.Timestamp, cfg.Name, Fs.Base(configFile))
-				}
-				versionedBackupPath = targetPath
-
-				_, err := Fs.Stat(configFile)
-				if os.IsNotExist(err) {
-					continue
-				} else if err != nil {
-					AppLogger.Logf("Error checking source file %s: %v", configFile, err)
-					continue
-				}
+	// Finalize backup (creates zip and cleans up old versions)
+	if err := ctx.FinalizeBackup(); err != nil {
+		return result, fmt.Errorf("error finalizing backup: %w", err)
+	}
 
-				if DryRun {
-					finalDirForLog := Fs.Dir(Fs.Join(ctx.BackupFolder, ctx.Timestamp, cfg.Name, Fs.Base(configFile)))
-					if ctx.ZipBackup {
-						finalDirForLog = Fs.Dir(Fs.Join(ctx.StagingDir, cfg.Name, Fs.Base(configFile)))
-						Printer.Print("Would create staging directory: %s\n", finalDirForLog)
-					} else {
-						Printer.Print("Would create versioned backup directory: %s\n", finalDirForLog)
-					}
-				} else {
-					err = Fs.MkdirAll(Fs.Dir(targetPath), 0755)
-					if err != nil {
-						AppLogger.Logf("Failed to create target directory '%s': %v", Fs.Dir(targetPath), err)
-						continue
-					}
-				}
-			} else {
-				latestVersion = latestVersionForCfg
-				latestIsZip = latestIsZipForCfg
-
-				if latestIsZip {
-					versionedBackupPath = filepath.ToSlash(Fs.Join(cfg.Name, Fs.Base(configFile)))
-				} else {
-					versionedBackupPath = Fs.Join(latestVersion, cfg.Name, Fs.Base(configFile))
+	if failed := result.Count(ItemFailed); failed > 0 {
+		operation := "restore"
+		if isBackup {
+			operation = "backup"
+		}
+		return result, fmt.Errorf("%s failed for %d item(s)", operation, failed)
+	}
+	return result, nil
+}
+
+// processApp backs up or restores one application in three phases: the pre
+// commands, the files and command outputs, then the post commands, recording
+// the outcome of each in the result. It returns whether the policy aborts the
+// run. With skip-app a failed pre phase skips the rest of the app; failures
+// after the pre phase still run the post phase, which may undo what pre did.
+func (ctx *BackupContext) processApp(cfg config.Config, configPath string, version *backupVersion, policy string) (abort bool) {
+	ctx.startApp(cfg.Name)
+
+	preType, preCommands := "pre-restore", cfg.PreRestoreCommands
+	postType, postCommands := "post-restore", cfg.PostRestoreCommands
+	if ctx.IsBackup {
+		preType, preCommands = "pre-backup", cfg.PreBackupCommands
+		postType, postCommands = "post-backup", cfg.PostBackupCommands
+	}
+	// skipReason tells why the commands of the app do not run
+	skipReason := ""
+	switch {
+	case !ctx.Commands:
+		skipReason = "command execution is disabled"
+	case !ctx.IsBackup && ctx.TargetDir != "":
+		if len(preCommands) > 0 || len(postCommands) > 0 || len(cfg.CommandOutputs) > 0 {
+			AppLogger.Logf("Skipping restore commands for %s: restoring into a target directory", cfg.Name)
+		}
+		skipReason = "restoring into a target directory"
+	default:
+		if err := ctx.trustCommands(configPath, cfg); err != nil {
+			skipReason = err.Error()
+		}
+	}
+	runCommands := skipReason == ""
+
+	if runCommands {
+		err := ctx.ExecuteCommands(preCommands, preType)
+		ctx.recordCommands(preType, preCommands, err)
+		if err != nil {
+			switch policy {
+			case config.HookFailureContinue:
+				AppLogger.Warnf("%v; continuing with %s (on_hook_failure = continue)", err, cfg.Name)
+			case config.HookFailureAbortRun:
+				AppLogger.Errorf("%v; aborting run (on_hook_failure = abort-run)", err)
+				return true
+			default:
+				AppLogger.Errorf("%v; skipping %s (on_hook_failure = skip-app)", err, cfg.Name)
+				for _, configFile := range cfg.Files {
+					ctx.recordSkip(ctx.ResolveConfigFilePath(configFile), preType+" commands failed")
 				}
+				return false
 			}
-			// --- Encryption Logic Start ---
-			if isBackup && password != "" {
-				err := command.SafeExecute("encryption operation", func() error {
-					plaintext, readErr := Fs.ReadFile(configFile)
-					if os.IsNotExist(readErr) {
-						if DryRun {
-							Printer.Print("Would skip encryption of %s (doesn't exist)", configFile)
-						}
-						return nil
-					} else if readErr != nil {
-						Printer.Print("Error reading source file %s for encryption: %v\n", configFile, readErr)
-						return readErr
-					}
-
-					encryptedData, encErr := encrypt(plaintext, password)
-					if encErr != nil {
-						Printer.Print("Error encrypting %s: %v", configFile, encErr)
-						return encErr
-					}
-
-					encryptedTargetPath := targetPath + ".encrypted"
-
-					if DryRun {
-						Printer.Print("Would encrypt %s to %s", configFile, encryptedTargetPath)
-						return nil
-					}
-
-					if mkErr := Fs.MkdirAll(Fs.Dir(encryptedTargetPath), 0755); mkErr != nil {
-						AppLogger.Logf("Failed to create target directory '%s' for encrypted file: %v", Fs.Dir(encryptedTargetPath), mkErr)
-						return mkErr
-					}
-
-					if writeErr := Fs.WriteFile(encryptedTargetPath, encryptedData, 0644); writeErr != nil {
-						Printer.Print("Error writing encrypted file %s: %v", encryptedTargetPath, writeErr)
-						return writeErr
-					}
-
-					Printer.Print("Encrypted %s to %s", configFile, encryptedTargetPath)
-					return nil
-				})
-
-				if err != nil {
-					AppLogger.Logf("Encryption operation failed for %s: %v", configFile, err)
+		}
+	}
+
+	for _, configFile := range cfg.Files {
+		resolved := ctx.ResolveConfigFilePath(configFile)
+		isPattern := hasGlobMeta(resolved)
+
+		if ctx.IsBackup {
+			err := command.SafeExecute("backup operation", func() error {
+				if isPattern {
+					return ctx.BackupPattern(cfg.Name, configFile, resolved)
 				}
-				continue
+				return ctx.BackupEntry(cfg.Name, resolved)
+			})
+			if err != nil {
+				AppLogger.Errorf("Backup operation failed for %s: %v", resolved, err)
+				ctx.recordFailure(resolved, err)
 			}
-			// --- Encryption Logic End ---
-
-			// --- Decryption Logic Start ---
-			if !isBackup {
-				encryptedSourcePath := versionedBackupPath + ".encrypted"
-				encryptedSourcePathInZip := filepath.ToSlash(Fs.Join(cfg.Name, Fs.Base(configFile))) + ".encrypted"
-				encryptedFileExists := false
-				var readErr error
-
-				if latestIsZip {
-					if zipFileMap != nil {
-						_, encryptedFileExists = zipFileMap[encryptedSourcePathInZip]
-					} else {
-						AppLogger.Logf("Error: zipFileMap is nil despite latestIsZip being true for app %s", cfg.Name)
-						encryptedFileExists = false
-					}
-				} else {
-					_, readErr = Fs.Stat(encryptedSourcePath)
-					if readErr == nil {
-						encryptedFileExists = true
-					}
+		} else {
+			err := command.SafeExecute("restore operation", func() error {
+				if isPattern {
+					return ctx.RestorePattern(version, cfg.Name, configFile, resolved)
 				}
+				return ctx.RestoreEntry(version, cfg.Name, resolved)
+			})
+			if err != nil {
+				AppLogger.Errorf("Restore operation failed for %s: %v", resolved, err)
+				ctx.recordFailure(resolved, err)
+			}
+		}
+	}
 
-				if encryptedFileExists {
-					if password == "" {
-						_ = AppLogger.LogErrorf("Encrypted backup file found for '%s' but no password provided. Use -password flag.", configFile)
-						continue
-					}
-
-					err := command.SafeExecute("decryption operation", func() error {
-						var encryptedData []byte
-						var readErr error
-
-						if latestIsZip {
-							tempFile, err := os.CreateTemp("", "settingssentry-decrypt-")
-							if err != nil {
-								return fmt.Errorf("failed to create temp file for zip extraction: %w", err)
-							}
-							tempFilePath := tempFile.Name()
-							// Check error on close immediately
-							if closeErr := tempFile.Close(); closeErr != nil {
-								// Attempt to remove before returning, but prioritize close error
-								_ = os.Remove(tempFilePath) // Ignore remove error if close failed
-								return fmt.Errorf("failed to close temp file handle: %w", closeErr)
-							}
-							defer func() {
-								if removeErr := os.Remove(tempFilePath); removeErr != nil {
-									AppLogger.Logf("Warning: Failed to remove temp decryption file %s: %v", tempFilePath, removeErr)
-								}
-							}() // Check remove error in defer
-
-							err = extractFromZip(latestVersion, encryptedSourcePathInZip, tempFilePath)
-							if err != nil {
-								Printer.Print("Info: Encrypted file %s not found in zip archive %s.", encryptedSourcePathInZip, latestVersion)
-								return nil
-							}
-							encryptedData, readErr = os.ReadFile(tempFilePath)
-						} else {
-							encryptedData, readErr = Fs.ReadFile(encryptedSourcePath)
-						}
-
-						if readErr != nil {
-							Printer.Print("Error reading encrypted file %s: %v", encryptedSourcePath, readErr)
-							return nil
-						}
-
-						plaintext, decErr := decrypt(encryptedData, password)
-						if decErr != nil {
-							Printer.Print("Error decrypting %s: %v (Wrong password or corrupt data?)", encryptedSourcePath, decErr)
-							return fmt.Errorf("decryption failed")
-						}
-
-						if DryRun {
-							Printer.Print("Would restore (decrypted) %s to %s", encryptedSourcePath, configFile)
-							return nil
-						}
-
-						if mkErr := Fs.MkdirAll(Fs.Dir(configFile), 0755); mkErr != nil {
-							Printer.Print("Error creating destination directory '%s': %v", Fs.Dir(configFile), mkErr)
-							return mkErr
-						}
-
-						if writeErr := Fs.WriteFile(configFile, plaintext, 0644); writeErr != nil {
-							Printer.Print("Error writing decrypted file %s: %v", configFile, writeErr)
-							return writeErr
-						}
-
-						Printer.Print("Restored (decrypted) %s to %s", encryptedSourcePath, configFile)
-						return nil
-					})
-
-					if err != nil {
-						AppLogger.Logf("Decryption/Restore operation failed for %s: %v", configFile, err)
-					}
-					continue
-				}
+	if len(cfg.CommandOutputs) > 0 && !runCommands {
+		if !ctx.Commands {
+			AppLogger.Logf("Skipping command outputs of %s: command execution is disabled (use -allow-commands)", cfg.Name)
+		}
+		for _, output := range cfg.CommandOutputs {
+			if !ctx.IsBackup && output.RestoreCommand == "" {
+				continue
 			}
-			// --- Decryption Logic End ---
-
-			// --- Standard Backup/Restore (if not handled by encryption/decryption) ---
-			if isBackup {
-				err := command.SafeExecute("backup operation", func() error {
-					info, err := Fs.Stat(configFile)
-					if os.IsNotExist(err) {
-						if DryRun {
-							Printer.Print("Would skip backup of %s (doesn't exist)", configFile)
-						}
-						return nil
-					} else if err != nil {
-						Printer.Print("Error accessing %s: %v\n", configFile, err)
-						return err
-					}
-
-					if DryRun {
-						Printer.Print("Would back up %s to %s", configFile, versionedBackupPath) // TODO: Fix log path for zip
-						return nil
-					}
-
-					if info.IsDir() {
-						err = copyDirectory(configFile, targetPath)
-					} else {
-						err = copyFile(configFile, targetPath)
-					}
-
-					if err != nil {
-						Printer.Print("Error backing up %s: %v", configFile, err)
-						return err
-					}
-
-					finalBackupPath := Fs.Join(ctx.BackupFolder, ctx.Timestamp, cfg.Name, Fs.Base(configFile))
-					if ctx.ZipBackup {
-						finalBackupPath = Fs.Join(ctx.BackupFolder, ctx.Timestamp+".zip", cfg.Name, Fs.Base(configFile)) // Indicate path within zip
-					}
-					Printer.Print("Backed up %s to %s", configFile, finalBackupPath)
-					return nil
-				})
-
-				if err != nil {
-					AppLogger.Logf("Backup operation failed for %s: %v", configFile, err)
-				}
-			} else {
-				err := command.SafeExecute("restore operation", func() error {
-
-					if DryRun {
-						Printer.Print("Would restore %s to %s", versionedBackupPath, configFile)
-						return nil
-					}
-
-					err = Fs.MkdirAll(Fs.Dir(configFile), 0755)
-					if err != nil {
-						Printer.Print("Error creating destination directory '%s': %v", Fs.Dir(configFile), err)
-						return err
-					}
-
-					if latestIsZip {
-						err = extractFromZip(latestVersion, versionedBackupPath, configFile)
-						// Need to handle directory extraction if source was a dir
-						// TODO: Enhance extractFromZip to handle directories if needed, or adjust logic here.
-						// Current extractFromZip likely only handles files.
-					} else {
-						backupSourceInfo, statErr := Fs.Stat(versionedBackupPath)
-						if statErr != nil {
-							//Printer.Print("Error accessing backup source %s: %v\n", versionedBackupPath, statErr)
-							return nil
-						}
-						if backupSourceInfo.IsDir() {
-							err = copyDirectory(versionedBackupPath, configFile)
-						} else {
-							err = copyFile(versionedBackupPath, configFile)
-						}
-					}
-
-					if err != nil {
-						Printer.Print("Error restoring %s: %v", versionedBackupPath, err)
-						return err
-					}
-
-					Printer.Print("Restored %s to %s", versionedBackupPath, configFile)
-					return nil
-				})
-
-				if err != nil {
-					AppLogger.Logf("Restore operation failed for %s: %v", configFile, err)
+			item := ctx.outputItem(cfg.Name, output)
+			item.Status, item.Reason = ItemSkipped, skipReason
+			ctx.record(item)
+		}
+	} else if runCommands {
+		for _, output := range cfg.CommandOutputs {
+			err := command.SafeExecute("command output", func() error {
+				if ctx.IsBackup {
+					return ctx.BackupCommandOutput(cfg.Name, output)
 				}
+				return ctx.RestoreCommandOutput(version, cfg.Name, output)
+			})
+			if err == nil {
+				continue
+			}
+			AppLogger.Errorf("Command output %s failed for %s: %v", output.Name, cfg.Name, err)
+			item := ctx.outputItem(cfg.Name, output)
+			item.Status, item.Err = ItemFailed, err
+			ctx.record(item)
+			if policy != config.HookFailureContinue {
+				abort = policy == config.HookFailureAbortRun
+				break
 			}
-		} // End loop through cfg.Files
+		}
+	}
 
-		if !ctx.IsBackup && ctx.Commands {
-			ctx.ExecuteCommands(cfg.PreRestoreCommands, "pre-restore")
-			ctx.ExecuteCommands(cfg.PostRestoreCommands, "post-restore")
+	if runCommands {
+		err := ctx.ExecuteCommands(postCommands, postType)
+		ctx.recordCommands(postType, postCommands, err)
+		if err != nil {
+			AppLogger.Errorf("%s phase of %s failed: %v", postType, cfg.Name, err)
+			abort = abort || policy == config.HookFailureAbortRun
 		}
-	} // End loop through config files
+	}
+	return abort
+}
 
-	if !foundCfg {
-		AppLogger.Logf("No .cfg files found to process in %s.", configReadDir)
+// recordCommands records the outcome of a command phase that has commands.
+func (ctx *BackupContext) recordCommands(commandType string, commands []string, err error) {
+	if err != nil {
+		ctx.record(ItemResult{Action: commandType, Status: ItemFailed, Err: err})
+	} else if len(commands) > 0 {
+		ctx.record(ItemResult{Action: commandType, Status: ItemSucceeded})
 	}
+}
 
-	// Finalize backup (creates zip and cleans up old versions)
-	if err := ctx.FinalizeBackup(); err != nil {
-		AppLogger.Logf("Error finalizing backup: %v", err)
+// resolveTargetDir validates the -target directory of a restore and returns its
+// absolute path, or an empty string when no target is set.
+func resolveTargetDir(target string) (string, error) {
+	if target == "" {
+		return "", nil
 	}
-} // Closing brace for ProcessConfiguration
+	absTarget, err := Fs.Abs(config.ExpandEnvVars(target))
+	if err != nil {
+		return "", fmt.Errorf("invalid target directory '%s': %w", target, err)
+	}
+	if info, err := Fs.Stat(absTarget); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("target '%s' is not a directory", absTarget)
+	}
+	return absTarget, nil
+}
 
 // createZipArchive creates a zip archive from the contents of a source directory.
+// The archive is written with the partial suffix, synced and then renamed to
+// targetZipPath, so that an interrupted run never leaves a truncated zip.
 func createZipArchive(sourceDir, targetZipPath string) error {
-	zipFile, err := os.Create(targetZipPath)
+	partialPath := targetZipPath + partialSuffix
+	zipFile, err := os.Create(partialPath)
 	if err != nil {
-		return fmt.Errorf("failed to create zip file '%s': %w", targetZipPath, err)
+		return fmt.Errorf("failed to create zip file '%s': %w", partialPath, err)
 	}
-	defer func() {
-		if err := zipFile.Close(); err != nil {
-			AppLogger.Logf("Error closing zip file %s: %v", targetZipPath, err)
-		}
-	}()
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer func() {
-		if err := zipWriter.Close(); err != nil {
-			AppLogger.Logf("Error closing zip writer for %s: %v", targetZipPath, err)
-		}
-	}()
+	err = writeZipArchive(zipFile, sourceDir)
+	if err == nil {
+		err = zipFile.Sync()
+	}
+	if closeErr := zipFile.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close zip file '%s': %w", partialPath, closeErr)
+	}
+	if err == nil {
+		err = os.Rename(partialPath, targetZipPath)
+	}
+	if err != nil {
+		_ = os.Remove(partialPath)
+		return err
+	}
+	return nil
+}
 
-	err = filepath.Walk(sourceDir, func(filePath string, info os.FileInfo, err error) error {
+// writeZipArchive writes the contents of sourceDir as a zip archive to w.
+func writeZipArchive(w io.Writer, sourceDir string) error {
+	zipWriter := zip.NewWriter(w)
+	err := filepath.Walk(sourceDir, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -750,13 +609,25 @@ func createZipArchive(sourceDir, targetZipPath string) error {
 			return nil
 		}
 
+		// Symlink entries hold the link target, as written by zip tools
+		if isSymlink(info.Mode()) {
+			linkTarget, err := os.Readlink(filePath)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink '%s' for zipping: %w", filePath, err)
+			}
+			if _, err := io.WriteString(writer, linkTarget); err != nil {
+				return fmt.Errorf("failed to add symlink '%s' to zip: %w", filePath, err)
+			}
+			return nil
+		}
+
 		fileToZip, err := os.Open(filePath)
 		if err != nil {
 			return fmt.Errorf("failed to open file '%s' for zipping: %w", filePath, err)
 		}
 		defer func() {
 			if err := fileToZip.Close(); err != nil {
-				AppLogger.Logf("Error closing file %s during zipping: %v", filePath, err)
+				AppLogger.Errorf("failed to close file %s during zipping: %v", filePath, err)
 			}
 		}()
 
@@ -769,102 +640,12 @@ func createZipArchive(sourceDir, targetZipPath string) error {
 	})
 
 	if err != nil {
+		_ = zipWriter.Close()
 		return fmt.Errorf("error walking staging directory '%s': %w", sourceDir, err)
 	}
-
-	return nil
-}
-
-// extractFromZip extracts a specific file or directory from a zip archive to a destination path.
-func extractFromZip(zipPath, entryPath, destinationPath string) error {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return fmt.Errorf("failed to open zip archive '%s': %w", zipPath, err)
-	}
-	defer func() {
-		if err := r.Close(); err != nil {
-			AppLogger.Logf("Error closing zip reader for %s: %v", zipPath, err)
-		}
-	}()
-
-	entryPath = filepath.ToSlash(entryPath) // Normalize entryPath to use forward slashes, as used in zip headers
-
-	found := false
-	for _, f := range r.File {
-		if f.Name == entryPath || strings.HasPrefix(f.Name, entryPath+"/") {
-			found = true
-			extractPath := ""
-			if f.Name == entryPath {
-				extractPath = destinationPath
-			} else {
-				relPath := strings.TrimPrefix(f.Name, entryPath+"/")
-				extractPath = filepath.Join(destinationPath, relPath)
-			}
-
-			// Validate extractPath to prevent directory traversal (Zip Slip)
-			cleanExtractPath := filepath.Clean(extractPath)
-			absExtractPath, err := filepath.Abs(cleanExtractPath)
-			if err != nil {
-				return fmt.Errorf("failed to resolve absolute path for '%s': %w", cleanExtractPath, err)
-			}
-			absDestinationPath, err := filepath.Abs(destinationPath)
-			if err != nil {
-				return fmt.Errorf("failed to resolve absolute path for destination '%s': %w", destinationPath, err)
-			}
-			if !strings.HasPrefix(absExtractPath, absDestinationPath+string(filepath.Separator)) && absExtractPath != absDestinationPath {
-				return fmt.Errorf("invalid file path '%s': outside of destination '%s'", absExtractPath, absDestinationPath)
-			}
-	
-			if f.FileInfo().IsDir() {
-				if err := os.MkdirAll(cleanExtractPath, f.Mode()); err != nil {
-					return fmt.Errorf("failed to create directory '%s': %w", cleanExtractPath, err)
-				}
-				continue
-			}
-
-			if err := os.MkdirAll(filepath.Dir(extractPath), os.ModePerm); err != nil {
-				return fmt.Errorf("failed to create directory for file '%s': %w", extractPath, err)
-			}
-
-			rc, err := f.Open()
-			if err != nil {
-				return fmt.Errorf("failed to open file '%s' in zip: %w", f.Name, err)
-			}
-
-			dstFile, err := os.OpenFile(extractPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-			if err != nil {
-				_ = rc.Close()
-				return fmt.Errorf("failed to create destination file '%s': %w", extractPath, err)
-			}
-
-			_, err = io.Copy(dstFile, rc)
-
-			closeErrRc := rc.Close()
-			closeErrDst := dstFile.Close()
-
-			if closeErrRc != nil {
-				AppLogger.Logf("Error closing zip entry reader for %s: %v", f.Name, closeErrRc)
-			}
-			if closeErrDst != nil {
-				AppLogger.Logf("Error closing destination file %s: %v", extractPath, closeErrDst)
-			}
-
-			if err != nil {
-				return fmt.Errorf("failed to copy content to '%s': %w", extractPath, err)
-			}
-			if closeErrDst != nil {
-				return fmt.Errorf("error closing destination file '%s': %w", extractPath, closeErrDst)
-			}
-			if closeErrRc != nil {
-				return fmt.Errorf("error closing zip entry reader '%s': %w", f.Name, closeErrRc)
-			}
-		}
-	}
-
-	if !found {
-		// Return nil error if the specific entry wasn't found, consistent with restore logic skipping non-existent files.
-		return nil
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finish zip archive: %w", err)
 	}
-
 	return nil
 }
+
@@ -6,10 +6,11 @@ import (
 	"SettingsSentry/pkg/command"
 	"SettingsSentry/pkg/config"
 	"SettingsSentry/pkg/printer"
-	"archive/zip"
 	"fmt"
+	"io"
 	iofs "io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -28,9 +29,46 @@ type BackupContext struct {
 	HomeDir        string
 	Timestamp      string
 	StagingDir     string
+	Manifest       *Manifest
 	Logger         *logger.Logger
 	FS             interfaces.FileSystem
 	Printer        *printer.Printer
+
+	// Dedup stores file contents in the object store of the backup folder
+	// instead of in the version directory.
+	Dedup bool
+	// objectDirs are the object store directories new objects were added to.
+	objectDirs map[string]bool
+	// Incremental reuses the files of the Previous version that did not
+	// change instead of copying them again.
+	Incremental     bool
+	Previous        *backupVersion
+	previousEntries map[string]*ManifestEntry
+	unchangedFiles  int
+	// SkipUnchanged discards the new version when its files are identical
+	// to those of the latest version. contents holds the checksum of the
+	// plaintext of every stored file to compare them.
+	SkipUnchanged bool
+	contents      map[string]string
+	// Retention is applied after a backup. When it has no rule, the newest
+	// VersionsToKeep versions are kept instead, 0 keeping all.
+	Retention RetentionPolicy
+	// TargetDir, when set, is the root restored files are written under
+	// instead of their original locations.
+	TargetDir string
+	// Excludes are the exclude patterns of the application being processed.
+	Excludes []string
+	// Snapshot is the manifest of the pre-restore snapshot, created when the
+	// restore first overwrites a file.
+	Snapshot    *Manifest
+	snapshotted map[string]bool
+	// Trust holds the approved commands of each app when commands are allowed.
+	Trust *TrustStore
+	// Result collects the outcome of every item of a backup or restore run.
+	Result     *Result
+	currentApp *AppResult
+	// unlock releases the lock of the backup folder taken by a backup run.
+	unlock func()
 }
 
 // NewBackupContext creates a new backup context with validated paths
@@ -69,6 +107,9 @@ func NewBackupContext(configFolder, backupFolder string, appNames []string, isBa
 		FS:             Fs,
 		Printer:        Printer,
 	}
+	if isBackup {
+		ctx.Manifest = newManifest(ctx)
+	}
 
 	return ctx, nil
 }
@@ -83,7 +124,13 @@ func (ctx *BackupContext) SetupBackupDirectory() error {
 			if err != nil {
 				return fmt.Errorf("failed to create backup folder: %w", err)
 			}
+			unlock, err := lockBackupFolder(ctx.BackupFolder)
+			if err != nil {
+				return err
+			}
+			ctx.unlock = unlock
 		}
+		removePartialVersions(ctx.BackupFolder)
 	} else {
 		_, err := ctx.FS.Stat(ctx.BackupFolder)
 		if err != nil {
@@ -95,15 +142,25 @@ func (ctx *BackupContext) SetupBackupDirectory() error {
 	if ctx.IsBackup && ctx.ZipBackup {
 		stagingDir, err := os.MkdirTemp("", "settingssentry-zip-")
 		if err != nil {
+			ctx.unlockBackupFolder()
 			return fmt.Errorf("failed to create temporary staging directory: %w", err)
 		}
 		ctx.StagingDir = stagingDir
-		ctx.Logger.Logf("Using staging directory for zip backup: %s", stagingDir)
+		ctx.Logger.Debugf("Using staging directory for zip backup: %s", stagingDir)
 	}
 
 	return nil
 }
 
+// unlockBackupFolder releases the lock of the backup folder, if the run holds
+// it.
+func (ctx *BackupContext) unlockBackupFolder() {
+	if ctx.unlock != nil {
+		ctx.unlock()
+		ctx.unlock = nil
+	}
+}
+
 // LoadConfigFiles loads configuration files from the config folder
 func (ctx *BackupContext) LoadConfigFiles() (iofs.FS, []iofs.DirEntry, error) {
 	_, err := ctx.FS.Stat(ctx.ConfigFolder)
@@ -111,7 +168,7 @@ func (ctx *BackupContext) LoadConfigFiles() (iofs.FS, []iofs.DirEntry, error) {
 		return nil, nil, fmt.Errorf("config folder '%s' not found or inaccessible: %w", ctx.ConfigFolder, err)
 	}
 
-	ctx.Logger.Logf("Using config folder: %s", ctx.ConfigFolder)
+	ctx.Logger.Debugf("Using config folder: %s", ctx.ConfigFolder)
 
 	currentFS := os.DirFS(ctx.ConfigFolder)
 	files, err := iofs.ReadDir(currentFS, ".")
@@ -126,6 +183,34 @@ func (ctx *BackupContext) LoadConfigFiles() (iofs.FS, []iofs.DirEntry, error) {
 	return currentFS, files, nil
 }
 
+// LoadConfigs parses the config files selected by the context's app names.
+// Files that cannot be parsed are logged and skipped; config names are
+// sanitized like during backup and restore.
+func (ctx *BackupContext) LoadConfigs() ([]config.Config, error) {
+	currentFS, files, err := ctx.LoadConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []config.Config
+	for _, file := range ctx.FilterConfigFiles(files) {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".cfg") {
+			continue
+		}
+		cfg, err := config.ParseConfig(currentFS, file.Name())
+		if err != nil {
+			AppLogger.Errorf("failed to parse config file '%s': %v", file.Name(), err)
+			continue
+		}
+		cfg.Name = sanitizeConfigName(cfg.Name)
+		configs = append(configs, cfg)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no .cfg files found to process in %s", ctx.ConfigFolder)
+	}
+	return configs, nil
+}
+
 // FilterConfigFiles filters config files based on app names
 func (ctx *BackupContext) FilterConfigFiles(files []iofs.DirEntry) []iofs.DirEntry {
 	if len(ctx.AppNames) == 0 {
@@ -149,21 +234,6 @@ func (ctx *BackupContext) FilterConfigFiles(files []iofs.DirEntry) []iofs.DirEnt
 	return filtered
 }
 
-// LoadZipFileMap loads the file map from a zip archive for restore operations
-func (ctx *BackupContext) LoadZipFileMap(zipPath string) (map[string]bool, *zip.ReadCloser, error) {
-	zipReader, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to open zip backup '%s': %w", zipPath, err)
-	}
-
-	zipFileMap := make(map[string]bool)
-	for _, f := range zipReader.File {
-		zipFileMap[f.Name] = true
-	}
-
-	return zipFileMap, zipReader, nil
-}
-
 // ResolveConfigFilePath resolves the config file path relative to home directory
 // and validates that the resolved path stays within the home directory to prevent
 // path traversal attacks (e.g., ~/../../etc/passwd)
@@ -190,7 +260,7 @@ func (ctx *BackupContext) ResolveConfigFilePath(configFile string) string {
 	if err != nil {
 		// If we can't get absolute path, log warning and return original
 		if AppLogger != nil {
-			AppLogger.Logf("Warning: Could not resolve absolute path for %s: %v", configFile, err)
+			AppLogger.Warnf("Could not resolve absolute path for %s: %v", configFile, err)
 		}
 		return resolved
 	}
@@ -199,7 +269,7 @@ func (ctx *BackupContext) ResolveConfigFilePath(configFile string) string {
 	if err != nil {
 		// If we can't get absolute home, log warning and return original
 		if AppLogger != nil {
-			AppLogger.Logf("Warning: Could not resolve absolute home directory: %v", err)
+			AppLogger.Warnf("Could not resolve absolute home directory: %v", err)
 		}
 		return resolved
 	}
@@ -210,7 +280,7 @@ func (ctx *BackupContext) ResolveConfigFilePath(configFile string) string {
 	if err != nil || strings.HasPrefix(relPath, "..") {
 		// Path escapes home directory - this is a path traversal attempt
 		if AppLogger != nil {
-			AppLogger.Logf("Warning: Path traversal attempt detected for %s (resolves outside home directory)", configFile)
+			AppLogger.Warnf("Path traversal attempt detected for %s (resolves outside home directory)", configFile)
 		}
 		// Return a safe path within home directory (just the basename)
 		return ctx.FS.Join(ctx.HomeDir, filepath.Base(configFile))
@@ -219,193 +289,548 @@ func (ctx *BackupContext) ResolveConfigFilePath(configFile string) string {
 	return resolved
 }
 
-// BackupFile backs up a single file
-func (ctx *BackupContext) BackupFile(configFile, targetPath string) error {
-	info, err := ctx.FS.Stat(configFile)
+// storedFile pairs a source file with the slash-separated path it is stored at,
+// relative to the version root, and the glob pattern that captured it, if any.
+type storedFile struct {
+	source  string
+	stored  string
+	info    os.FileInfo
+	pattern string
+}
+
+// versionRoot returns the directory the version being created is written to:
+// the staging directory for zip backups, the timestamped directory with the
+// partial suffix otherwise, until FinalizeBackup renames it.
+func (ctx *BackupContext) versionRoot() string {
+	if ctx.ZipBackup {
+		return ctx.StagingDir
+	}
+	return ctx.FS.Join(ctx.BackupFolder, ctx.Timestamp+partialSuffix)
+}
+
+// displayStoredPath returns the user-facing location of a stored path once the
+// version being created is complete.
+func (ctx *BackupContext) displayStoredPath(storedPath string) string {
+	if ctx.ZipBackup {
+		return ctx.FS.Join(ctx.BackupFolder, ctx.Timestamp+".zip", filepath.FromSlash(storedPath))
+	}
+	return ctx.FS.Join(ctx.BackupFolder, ctx.Timestamp, filepath.FromSlash(storedPath))
+}
+
+// storedDisplayPath returns where a file with the given logical stored path
+// ends up in the version being created, including the encryption suffix.
+func (ctx *BackupContext) storedDisplayPath(storedPath string) string {
+	if ctx.Password != "" {
+		return ctx.displayStoredPath(storedPath) + encryptedSuffix
+	}
+	return ctx.displayStoredPath(storedPath)
+}
+
+// BackupEntry backs up one resolved configuration path, file or directory, into
+// the version being created. Files are stored under the app folder at their
+// home-relative location and encrypted when a password is set.
+func (ctx *BackupContext) BackupEntry(appName, sourcePath string) error {
+	return ctx.backupEntry(appName, sourcePath, "")
+}
+
+// backupEntry backs up one path, recording the configuration pattern that
+// matched it in the manifest. A configured path that is a symlink is followed,
+// so its content is stored; symlinks found inside a directory are stored as
+// links.
+func (ctx *BackupContext) backupEntry(appName, sourcePath, pattern string) error {
+	info, err := ctx.FS.Stat(sourcePath)
 	if os.IsNotExist(err) {
 		if DryRun {
-			ctx.Printer.Print("Would skip backup of %s (doesn't exist)", configFile)
+			ctx.Printer.Print("Would skip backup of %s (doesn't exist)", sourcePath)
 		}
+		ctx.recordSkip(sourcePath, "does not exist")
 		return nil
 	} else if err != nil {
-		return fmt.Errorf("error accessing %s: %w", configFile, err)
+		return fmt.Errorf("error accessing %s: %w", sourcePath, err)
+	}
+	if info.Mode()&specialFileMode != 0 {
+		ctx.Printer.Print("Skipping %s (not a regular file)", sourcePath)
+		ctx.recordSkip(sourcePath, "not a regular file")
+		return nil
+	}
+
+	storedPath := path.Join(appName, ctx.StoredRelPath(sourcePath))
+	files := []storedFile{{source: sourcePath, stored: storedPath, info: info}}
+	if info.IsDir() {
+		files, err = ctx.collectDirectory(sourcePath, storedPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	displayPath := ctx.displayStoredPath(storedPath)
+	if ctx.Password != "" && !info.IsDir() {
+		displayPath += encryptedSuffix
 	}
 
 	if DryRun {
-		finalBackupPath := ctx.FS.Join(ctx.BackupFolder, ctx.Timestamp, ctx.FS.Base(targetPath))
-		if ctx.ZipBackup {
-			finalBackupPath = ctx.FS.Join(ctx.BackupFolder, ctx.Timestamp+".zip")
+		if ctx.Password != "" {
+			ctx.Printer.Print("Would encrypt %s to %s", sourcePath, displayPath)
+		} else {
+			ctx.Printer.Print("Would back up %s to %s", sourcePath, displayPath)
+		}
+		for _, f := range files {
+			ctx.recordCopy(f.source, ctx.storedDisplayPath(f.stored), f.info.Size(), time.Now())
 		}
-		ctx.Printer.Print("Would back up %s to %s", configFile, finalBackupPath)
 		return nil
 	}
 
-	if info.IsDir() {
-		err = copyDirectory(configFile, targetPath)
+	for _, f := range files {
+		started := time.Now()
+		f.pattern = pattern
+		if err := ctx.storeFile(appName, f); err != nil {
+			return err
+		}
+		ctx.recordCopy(f.source, ctx.storedDisplayPath(f.stored), f.info.Size(), started)
+	}
+
+	if ctx.Password != "" {
+		ctx.Printer.Print("Encrypted %s to %s", sourcePath, displayPath)
 	} else {
-		err = copyFile(configFile, targetPath)
+		ctx.Printer.Print("Backed up %s to %s", sourcePath, displayPath)
 	}
+	return nil
+}
 
+// collectDirectory lists every file below a source directory together with the
+// path it is stored at. Excluded paths, sockets and other special files are
+// left out.
+func (ctx *BackupContext) collectDirectory(sourceDir, storedDir string) ([]storedFile, error) {
+	entries, err := ctx.FS.ReadDir(sourceDir)
 	if err != nil {
-		return fmt.Errorf("error backing up %s: %w", configFile, err)
+		return nil, fmt.Errorf("failed to read source directory '%s': %w", sourceDir, err)
 	}
 
-	finalBackupPath := ctx.FS.Join(ctx.BackupFolder, ctx.Timestamp, ctx.FS.Base(targetPath))
-	if ctx.ZipBackup {
-		finalBackupPath = ctx.FS.Join(ctx.BackupFolder, ctx.Timestamp+".zip", ctx.FS.Base(targetPath))
+	var files []storedFile
+	for _, entry := range entries {
+		sourcePath := ctx.FS.Join(sourceDir, entry.Name())
+		storedPath := path.Join(storedDir, entry.Name())
+		if ctx.isExcluded(sourcePath) {
+			if DryRun {
+				ctx.Printer.Print("Would skip %s (excluded)", sourcePath)
+			}
+			ctx.recordSkip(sourcePath, "excluded")
+			continue
+		}
+		if entry.IsDir() {
+			children, err := ctx.collectDirectory(sourcePath, storedPath)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, children...)
+			continue
+		}
+		info, err := ctx.FS.Lstat(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("error accessing %s: %w", sourcePath, err)
+		}
+		if info.Mode()&specialFileMode != 0 {
+			ctx.recordSkip(sourcePath, "not a regular file")
+			continue
+		}
+		files = append(files, storedFile{source: sourcePath, stored: storedPath, info: info})
 	}
-	ctx.Printer.Print("Backed up %s to %s", configFile, finalBackupPath)
-	return nil
+	return files, nil
 }
 
-// RestoreFile restores a single file
-func (ctx *BackupContext) RestoreFile(versionedBackupPath, configFile, latestVersion string, latestIsZip bool) error {
-	if DryRun {
-		ctx.Printer.Print("Would restore %s to %s", versionedBackupPath, configFile)
-		return nil
-	}
-
-	err := ctx.FS.MkdirAll(ctx.FS.Dir(configFile), 0755)
+// storeFile writes a single source file into the version being created,
+// encrypting it when a password is set, and records it in the manifest.
+func (ctx *BackupContext) storeFile(appName string, f storedFile) error {
+	entry, err := ctx.writeStoredFile(ctx.versionRoot(), f)
 	if err != nil {
-		return fmt.Errorf("error creating destination directory '%s': %w", ctx.FS.Dir(configFile), err)
+		return err
+	}
+	if ctx.Manifest != nil {
+		ctx.Manifest.AddEntry(appName, entry)
 	}
+	return nil
+}
 
-	if latestIsZip {
-		err = extractFromZip(latestVersion, versionedBackupPath, configFile)
-	} else {
-		backupSourceInfo, statErr := ctx.FS.Stat(versionedBackupPath)
-		if statErr != nil {
-			return nil // Skip if source doesn't exist
+// writeStoredFile copies a source file or symlink to its stored path below
+// root, or to the object store for deduplicated backups, encrypted when a
+// password is set, and returns the manifest entry describing it.
+func (ctx *BackupContext) writeStoredFile(root string, f storedFile) (ManifestEntry, error) {
+	targetPath := ctx.FS.Join(root, filepath.FromSlash(f.stored))
+	entry := ManifestEntry{
+		Source:  f.source,
+		Stored:  f.stored,
+		Size:    f.info.Size(),
+		Mode:    f.info.Mode(),
+		ModTime: f.info.ModTime().UTC(),
+		Pattern: f.pattern,
+	}
+	if reused, ok := ctx.reuseUnchanged(root, f, entry); ok {
+		return reused, nil
+	}
+
+	if isSymlink(f.info.Mode()) {
+		if ctx.Password != "" || ctx.Dedup {
+			linkTarget, err := ctx.FS.Readlink(f.source)
+			if err != nil {
+				return entry, fmt.Errorf("failed to read symlink '%s': %w", f.source, err)
+			}
+			return ctx.writeStoredData(root, entry, []byte(linkTarget))
 		}
-		if backupSourceInfo.IsDir() {
-			err = copyDirectory(versionedBackupPath, configFile)
-		} else {
-			err = copyFile(versionedBackupPath, configFile)
+		linkTarget, err := storeSymlink(f.source, targetPath)
+		if err != nil {
+			return entry, err
 		}
+		entry.SHA256 = sha256Hex([]byte(linkTarget))
+		ctx.recordContent(entry.Stored, entry.SHA256)
+		return entry, nil
 	}
 
-	if err != nil {
-		return fmt.Errorf("error restoring %s: %w", versionedBackupPath, err)
+	if ctx.Password == "" && !ctx.Dedup {
+		checksum, err := copyFileWithChecksum(f.source, targetPath)
+		if err != nil {
+			return entry, err
+		}
+		entry.SHA256 = checksum
+		ctx.recordContent(entry.Stored, checksum)
+		return entry, nil
 	}
 
-	ctx.Printer.Print("Restored %s to %s", versionedBackupPath, configFile)
-	return nil
+	plaintext, err := ctx.FS.ReadFile(f.source)
+	if err != nil {
+		return entry, fmt.Errorf("error reading source file %s: %w", f.source, err)
+	}
+	return ctx.writeStoredData(root, entry, plaintext)
 }
 
-// EncryptFile encrypts a file for backup
-func (ctx *BackupContext) EncryptFile(configFile, targetPath string) error {
-	plaintext, err := ctx.FS.ReadFile(configFile)
-	if os.IsNotExist(err) {
-		if DryRun {
-			ctx.Printer.Print("Would skip encryption of %s (doesn't exist)", configFile)
+// writeStoredData writes data to the stored path of entry below root, or to the
+// object store for deduplicated backups, encrypted when a password is set, and
+// completes the entry with its checksum.
+func (ctx *BackupContext) writeStoredData(root string, entry ManifestEntry, data []byte) (ManifestEntry, error) {
+	targetPath := ctx.FS.Join(root, filepath.FromSlash(entry.Stored))
+	ctx.recordContent(entry.Stored, sha256Hex(data))
+	if ctx.Password != "" {
+		encryptedData, err := encrypt(data, ctx.Password)
+		if err != nil {
+			return entry, fmt.Errorf("error encrypting %s: %w", entry.Stored, err)
 		}
-		return nil
-	} else if err != nil {
-		return fmt.Errorf("error reading source file %s for encryption: %w", configFile, err)
+		data = encryptedData
+		targetPath += encryptedSuffix
+		entry.Stored += encryptedSuffix
+		entry.Encrypted = true
 	}
+	if ctx.Dedup {
+		return ctx.writeObject(entry, data)
+	}
+
+	if err := ctx.FS.MkdirAll(ctx.FS.Dir(targetPath), 0755); err != nil {
+		return entry, fmt.Errorf("failed to create target directory '%s': %w", ctx.FS.Dir(targetPath), err)
+	}
+	if err := ctx.FS.WriteFile(targetPath, data, 0644); err != nil {
+		return entry, fmt.Errorf("error writing file %s: %w", targetPath, err)
+	}
+	entry.SHA256 = sha256Hex(data)
+	return entry, nil
+}
 
-	encryptedData, err := encrypt(plaintext, ctx.Password)
+// RestoreEntry restores one resolved configuration path from an opened backup
+// version. It looks for the path-preserving layout first and falls back to the
+// legacy layout, so versions written by older releases stay restorable.
+func (ctx *BackupContext) RestoreEntry(version *backupVersion, appName, destPath string) error {
+	storedPath, targets, err := ctx.restoreTargets(version, appName, destPath)
 	if err != nil {
-		return fmt.Errorf("error encrypting %s: %w", configFile, err)
+		return err
+	}
+	if len(targets) == 0 {
+		ctx.recordSkip(destPath, "not in backup")
+		return nil
 	}
+	return ctx.applyRestore(version, appName, storedPath, ctx.RestoreDestination(destPath), targets)
+}
 
-	encryptedTargetPath := targetPath + ".encrypted"
+// applyRestore writes the targets of one stored path to disk, after saving
+// what they overwrite to the pre-restore snapshot.
+func (ctx *BackupContext) applyRestore(version *backupVersion, appName, storedPath, destPath string, targets []restoreTarget) error {
+	sourcePath := ctx.FS.Join(version.Path, filepath.FromSlash(storedPath))
+	encrypted := false
+	for _, t := range targets {
+		if t.file.Encrypted {
+			encrypted = true
+			break
+		}
+	}
+	if encrypted && ctx.Password == "" {
+		return fmt.Errorf("encrypted backup file found for '%s' but no password provided. Use -password flag", destPath)
+	}
 
 	if DryRun {
-		ctx.Printer.Print("Would encrypt %s to %s", configFile, encryptedTargetPath)
+		ctx.Printer.Print("Would restore %s to %s", sourcePath, destPath)
+		for _, t := range targets {
+			ctx.recordCopy(ctx.FS.Join(version.Path, filepath.FromSlash(t.file.Name)), t.path, 0, time.Now())
+		}
 		return nil
 	}
 
-	if err := ctx.FS.MkdirAll(ctx.FS.Dir(encryptedTargetPath), 0755); err != nil {
-		return fmt.Errorf("failed to create target directory '%s' for encrypted file: %w", ctx.FS.Dir(encryptedTargetPath), err)
+	// Save what is about to be overwritten before touching anything
+	if err := ctx.snapshotTargets(appName, targets); err != nil {
+		return err
 	}
 
-	if err := ctx.FS.WriteFile(encryptedTargetPath, encryptedData, 0644); err != nil {
-		return fmt.Errorf("error writing encrypted file %s: %w", encryptedTargetPath, err)
+	for _, t := range targets {
+		started := time.Now()
+		if err := ctx.restoreFile(version, t.file, t.path); err != nil {
+			return err
+		}
+		var size int64
+		if info, err := ctx.FS.Lstat(t.path); err == nil {
+			size = info.Size()
+		}
+		ctx.recordCopy(ctx.FS.Join(version.Path, filepath.FromSlash(t.file.Name)), t.path, size, started)
 	}
 
-	ctx.Printer.Print("Encrypted %s to %s", configFile, encryptedTargetPath)
+	if encrypted {
+		ctx.Printer.Print("Restored (decrypted) %s to %s", sourcePath, destPath)
+	} else {
+		ctx.Printer.Print("Restored %s to %s", sourcePath, destPath)
+	}
 	return nil
 }
 
-// DecryptFile decrypts a file for restore
-func (ctx *BackupContext) DecryptFile(encryptedSourcePath, configFile, appName string, latestIsZip bool, latestVersion string) error {
-	var encryptedData []byte
-	var err error
-
-	if latestIsZip {
-		encryptedSourcePathInZip := filepath.ToSlash(ctx.FS.Join(appName, ctx.FS.Base(configFile))) + ".encrypted"
-		tempFile, tempErr := os.CreateTemp("", "settingssentry-decrypt-")
-		if tempErr != nil {
-			return fmt.Errorf("failed to create temp file for zip extraction: %w", tempErr)
-		}
-		tempFilePath := tempFile.Name()
-		if closeErr := tempFile.Close(); closeErr != nil {
-			_ = os.Remove(tempFilePath)
-			return fmt.Errorf("failed to close temp file handle: %w", closeErr)
-		}
-		defer func() {
-			if removeErr := os.Remove(tempFilePath); removeErr != nil {
-				ctx.Logger.Logf("Warning: Failed to remove temp decryption file %s: %v", tempFilePath, removeErr)
-			}
-		}()
+// RestoreDestination returns where a resolved config path is written on
+// restore. Without a target directory that is the path itself; with one, paths
+// inside the home directory keep their home-relative location under the
+// target and other absolute paths are placed below it in full.
+func (ctx *BackupContext) RestoreDestination(resolvedPath string) string {
+	if ctx.TargetDir == "" {
+		return resolvedPath
+	}
+	cleaned := filepath.Clean(resolvedPath)
+	if rel, err := filepath.Rel(filepath.Clean(ctx.HomeDir), cleaned); err == nil &&
+		rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Join(ctx.TargetDir, rel)
+	}
+	return filepath.Join(ctx.TargetDir, cleaned)
+}
 
-		err = extractFromZip(latestVersion, encryptedSourcePathInZip, tempFilePath)
-		if err != nil {
-			return nil // Skip if not found in zip
+// restoreTarget is a stored file together with the path it is restored to.
+type restoreTarget struct {
+	file versionFile
+	path string
+}
+
+// restoreTargets finds the files stored in a version for a configured path and
+// the paths they are restored to. The returned stored path is the location in
+// the version that matched; it is empty when nothing was stored for destPath.
+func (ctx *BackupContext) restoreTargets(version *backupVersion, appName, destPath string) (string, []restoreTarget, error) {
+	candidates := ctx.storedPathCandidates(appName, destPath)
+	if version.Manifest != nil {
+		// Versions with a manifest always use the path-preserving layout
+		candidates = candidates[:1]
+	}
+
+	var storedPath string
+	var matches []versionFile
+	for _, candidate := range candidates {
+		if matches = matchStoredFiles(version.Files, candidate); len(matches) > 0 {
+			storedPath = candidate
+			break
 		}
-		encryptedData, err = os.ReadFile(tempFilePath)
-	} else {
-		encryptedData, err = ctx.FS.ReadFile(encryptedSourcePath)
 	}
+	if len(matches) == 0 {
+		return "", nil, nil
+	}
+
+	restoreRoot := ctx.RestoreDestination(destPath)
+	targets := make([]restoreTarget, 0, len(matches))
+	for _, f := range matches {
+		targetPath := filepath.Join(restoreRoot, filepath.FromSlash(strings.TrimPrefix(f.Path, storedPath)))
+
+		// Guard against stored names escaping the destination (Zip Slip)
+		if rel, err := filepath.Rel(restoreRoot, targetPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", nil, fmt.Errorf("invalid file path '%s': outside of destination '%s'", targetPath, restoreRoot)
+		}
+		targets = append(targets, restoreTarget{file: f, path: targetPath})
+	}
+	return storedPath, targets, nil
+}
 
+// readStoredFile returns the content of a stored file, decrypted when it was
+// stored encrypted.
+func readStoredFile(version *backupVersion, f versionFile, password string) ([]byte, error) {
+	reader, err := version.Open(f.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error opening backup file %s: %w", f.Name, err)
+	}
+	data, err := io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup file %s: %w", f.Name, err)
+	}
+	if !f.Encrypted {
+		return data, nil
+	}
+	if password == "" {
+		return nil, fmt.Errorf("backup file %s is encrypted but no password provided. Use -password flag", f.Name)
+	}
+	plaintext, err := decrypt(data, password)
 	if err != nil {
-		return fmt.Errorf("error reading encrypted file %s: %w", encryptedSourcePath, err)
+		return nil, fmt.Errorf("error decrypting %s (wrong password or corrupt data?): %w", f.Name, err)
 	}
+	return plaintext, nil
+}
 
-	plaintext, err := decrypt(encryptedData, ctx.Password)
+// restoreFile writes a single stored file to its destination, decrypting it
+// first when it was stored encrypted, and gives it back the mode and
+// modification time recorded in the manifest. Stored symlinks are recreated.
+func (ctx *BackupContext) restoreFile(version *backupVersion, f versionFile, targetPath string) error {
+	reader, err := version.Open(f.Name)
 	if err != nil {
-		return fmt.Errorf("error decrypting %s (wrong password or corrupt data?): %w", encryptedSourcePath, err)
+		return fmt.Errorf("error opening backup file %s: %w", f.Name, err)
 	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			ctx.Logger.Errorf("failed to close backup file %s: %v", f.Name, err)
+		}
+	}()
 
-	if DryRun {
-		ctx.Printer.Print("Would restore (decrypted) %s to %s", encryptedSourcePath, configFile)
-		return nil
+	if err := ctx.FS.MkdirAll(ctx.FS.Dir(targetPath), 0755); err != nil {
+		return fmt.Errorf("error creating destination directory '%s': %w", ctx.FS.Dir(targetPath), err)
 	}
 
-	if err := ctx.FS.MkdirAll(ctx.FS.Dir(configFile), 0755); err != nil {
-		return fmt.Errorf("error creating destination directory '%s': %w", ctx.FS.Dir(configFile), err)
+	if f.Encrypted || (f.Entry != nil && isSymlink(f.Entry.Mode)) {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("error reading backup file %s: %w", f.Name, err)
+		}
+		if f.Encrypted {
+			if data, err = decrypt(data, ctx.Password); err != nil {
+				return fmt.Errorf("error decrypting %s (wrong password or corrupt data?): %w", f.Name, err)
+			}
+		}
+		return writeRestoredData(targetPath, data, f.Entry)
 	}
 
-	if err := ctx.FS.WriteFile(configFile, plaintext, 0644); err != nil {
-		return fmt.Errorf("error writing decrypted file %s: %w", configFile, err)
+	perm := os.FileMode(0644)
+	if f.Entry != nil {
+		perm = f.Entry.Mode.Perm()
+	}
+	if err := prepareOverwrite(targetPath, perm); err != nil {
+		return err
+	}
+	dstFile, err := ctx.FS.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("error creating destination file '%s': %w", targetPath, err)
+	}
+	_, err = io.Copy(dstFile, reader)
+	if closeErr := dstFile.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error restoring %s to %s: %w", f.Name, targetPath, err)
 	}
 
-	ctx.Printer.Print("Restored (decrypted) %s to %s", encryptedSourcePath, configFile)
-	return nil
+	if f.Entry == nil {
+		return nil
+	}
+	return preserveMetadata(targetPath, f.Entry.Mode, f.Entry.ModTime)
 }
 
-// ExecuteCommands executes pre/post backup or restore commands
-func (ctx *BackupContext) ExecuteCommands(commands []string, commandType string) {
+// ExecuteCommands executes pre/post backup or restore commands in order. It
+// stops at the first failing command, since later commands usually depend on
+// earlier ones, and returns its error.
+func (ctx *BackupContext) ExecuteCommands(commands []string, commandType string) error {
 	for _, cmd := range commands {
 		if DryRun {
 			ctx.Printer.Print("Would execute %s command: %s", commandType, cmd)
 			continue
 		}
 		err := command.SafeExecute(commandType+" command execution", func() error {
-			if !command.ExecuteCommandLine(cmd) {
-				return fmt.Errorf("command execution failed")
+			commandLine, opts, err := config.ParseCommand(cmd)
+			if err != nil {
+				return err
 			}
-			return nil
+			return command.RunCommandLine(commandLine, opts)
 		})
 		if err != nil {
-			ctx.Logger.Logf("Failed to execute %s command: %v", commandType, err)
+			ctx.Logger.Errorf("failed to execute %s command: %v", commandType, err)
+			return fmt.Errorf("%s command '%s' failed: %w", commandType, cmd, err)
+		}
+	}
+	return nil
+}
+
+// WriteManifest stores the manifest of the version being created at its root.
+// Directory versions only get a manifest when at least one file was stored.
+func (ctx *BackupContext) WriteManifest() error {
+	if !ctx.IsBackup || ctx.Manifest == nil {
+		return nil
+	}
+
+	root := ctx.versionRoot()
+	if root == "" {
+		return nil
+	}
+	if _, err := ctx.FS.Stat(root); err != nil {
+		if os.IsNotExist(err) && !ctx.ZipBackup {
+			return nil
 		}
+		return fmt.Errorf("failed to access version directory '%s': %w", root, err)
+	}
+
+	if DryRun {
+		ctx.Logger.Logf("Would write manifest: %s", ctx.FS.Join(root, ManifestFileName))
+		return nil
 	}
+	return writeManifest(root, ctx.Manifest)
+}
+
+// DiscardVersion removes the version being created, after a run was aborted.
+// The staging directory of zip backups is removed when Process returns.
+func (ctx *BackupContext) DiscardVersion() {
+	if !ctx.IsBackup || ctx.ZipBackup {
+		return
+	}
+	versionDir := ctx.versionRoot()
+	if _, err := ctx.FS.Stat(versionDir); err != nil {
+		return
+	}
+	if err := ctx.FS.RemoveAll(versionDir); err != nil {
+		ctx.Logger.Errorf("failed to remove incomplete version %s: %v", versionDir, err)
+		return
+	}
+	ctx.Logger.Logf("Removed incomplete version %s", versionDir)
 }
 
 // FinalizeBackup finalizes the backup by creating zip archive and cleaning up old versions
 func (ctx *BackupContext) FinalizeBackup() error {
+	if err := ctx.writeVersion(); err != nil {
+		if ctx.Result != nil {
+			ctx.Result.NotWritten = true
+		}
+		return err
+	}
+
+	if ctx.IsBackup {
+		policy := ctx.Retention
+		if policy.IsZero() {
+			policy.Last = ctx.VersionsToKeep
+		}
+		if !policy.IsZero() {
+			if _, err := ApplyRetention(ctx.BackupFolder, policy); err != nil {
+				return fmt.Errorf("failed to cleanup old versions: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeVersion writes the manifest, then gives the version being created its
+// final name, as a zip archive or a directory.
+func (ctx *BackupContext) writeVersion() error {
+	if err := ctx.WriteManifest(); err != nil {
+		return err
+	}
+
 	if ctx.IsBackup && ctx.ZipBackup {
 		if ctx.StagingDir == "" {
 			return fmt.Errorf("staging directory path is empty, cannot create zip archive")
@@ -415,21 +840,18 @@ func (ctx *BackupContext) FinalizeBackup() error {
 		if err := createZipArchive(ctx.StagingDir, targetZipPath); err != nil {
 			return fmt.Errorf("failed to create zip archive: %w", err)
 		}
+		ctx.syncDir(ctx.BackupFolder)
 		ctx.Logger.Logf("Successfully created zip archive: %s", targetZipPath)
 
 		// Cleanup staging directory
-		ctx.Logger.Logf("Cleaning up staging directory: %s", ctx.StagingDir)
+		ctx.Logger.Debugf("Cleaning up staging directory: %s", ctx.StagingDir)
 		if err := ctx.FS.RemoveAll(ctx.StagingDir); err != nil {
-			ctx.Logger.Logf("Error removing staging directory %s: %v", ctx.StagingDir, err)
+			ctx.Logger.Errorf("failed to remove staging directory %s: %v", ctx.StagingDir, err)
 		}
-	}
-
-	if ctx.IsBackup && ctx.VersionsToKeep > 0 {
-		err := CleanupOldVersions(ctx.BackupFolder, ctx.VersionsToKeep)
-		if err != nil {
-			return fmt.Errorf("failed to cleanup old versions: %w", err)
+	} else if ctx.IsBackup {
+		if err := ctx.commitVersion(); err != nil {
+			return err
 		}
 	}
-
 	return nil
-}
\ No newline at end of file
+}
@@ -55,9 +55,9 @@ func TestCopyFile(t *testing.T) {
 
 	dstPath := filepath.Join(tempDir, "destination.txt")
 
-	err = copyFile(srcPath, dstPath)
+	_, err = copyFileWithChecksum(srcPath, dstPath)
 	if err != nil {
-		t.Errorf("copyFile() returned an error: %v", err)
+		t.Errorf("copyFileWithChecksum() returned an error: %v", err)
 	}
 
 	dstContent, err := os.ReadFile(dstPath)
@@ -69,58 +69,6 @@ func TestCopyFile(t *testing.T) {
 	}
 }
 
-func TestCopyDirectory(t *testing.T) {
-	setupBackupTestDependencies()
-
-	tempDir, err := os.MkdirTemp("", "settingssentry-test")
-	if err != nil {
-		t.Fatalf("Failed to create temp directory: %v", err)
-	}
-	defer func() {
-		if err := os.RemoveAll(tempDir); err != nil {
-			t.Errorf("Failed to remove temp dir %s: %v", tempDir, err)
-		}
-	}()
-
-	srcDir := filepath.Join(tempDir, "src")
-	err = os.MkdirAll(filepath.Join(srcDir, "subdir"), 0755)
-	if err != nil {
-		t.Fatalf("Failed to create source directory structure: %v", err)
-	}
-
-	files := map[string]string{
-		"file1.txt":        "content of file 1",
-		"subdir/file2.txt": "content of file 2",
-	}
-
-	for path, content := range files {
-		filePath := filepath.Join(srcDir, path)
-		err = os.WriteFile(filePath, []byte(content), 0644)
-		if err != nil {
-			t.Fatalf("Failed to write file %s: %v", path, err)
-		}
-	}
-
-	dstDir := filepath.Join(tempDir, "dst")
-
-	err = copyDirectory(srcDir, dstDir)
-	if err != nil {
-		t.Errorf("copyDirectory() returned an error: %v", err)
-	}
-
-	for path, expectedContent := range files {
-		filePath := filepath.Join(dstDir, path)
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			t.Errorf("Failed to read file %s: %v", path, err)
-			continue
-		}
-		if string(content) != expectedContent {
-			t.Errorf("File %s content does not match. Expected '%s', got '%s'", path, expectedContent, string(content))
-		}
-	}
-}
-
 func TestGetLatestVersionPath_Extended(t *testing.T) {
 	setupBackupTestDependencies()
 
@@ -385,7 +333,7 @@ func TestCopyFile_Errors(t *testing.T) {
 		src := filepath.Join(tempDir, "nonexistent.txt")
 		dst := filepath.Join(tempDir, "dest.txt")
 
-		err := copyFile(src, dst)
+		_, err := copyFileWithChecksum(src, dst)
 		if err == nil {
 			t.Error("Expected error for nonexistent source file")
 		}
@@ -400,29 +348,13 @@ func TestCopyFile_Errors(t *testing.T) {
 		_ = os.WriteFile(badDir, []byte("block"), 0644)
 		dst := filepath.Join(badDir, "dest.txt")
 
-		err := copyFile(src, dst)
+		_, err := copyFileWithChecksum(src, dst)
 		if err == nil {
 			t.Error("Expected error when destination directory is invalid")
 		}
 	})
 }
 
-func TestCopyDirectory_Errors(t *testing.T) {
-	setupBackupTestDependencies()
-
-	tempDir := t.TempDir()
-
-	t.Run("nonexistent source directory", func(t *testing.T) {
-		src := filepath.Join(tempDir, "nonexistent")
-		dst := filepath.Join(tempDir, "dest")
-
-		err := copyDirectory(src, dst)
-		if err == nil {
-			t.Error("Expected error for nonexistent source directory")
-		}
-	})
-}
-
 // TestProcessConfiguration_CommandExecution tests that commands are only executed
 // when the commands flag is true (security feature)
 func TestProcessConfiguration_CommandExecution(t *testing.T) {
@@ -516,7 +448,9 @@ echo "command_executed" > ` + successMarker + `
 		// Remove success marker if it exists
 		os.Remove(successMarker)
 
-		// Run backup with commands=true
+		// Run backup with commands=true, as from a terminal
+		TrustOnFirstUse = true
+		defer func() { TrustOnFirstUse = false }()
 		ProcessConfiguration(configDir, backupDir, nil, true, true, 1, false, "")
 
 		// Success marker SHOULD exist (command WAS executed)
@@ -663,6 +597,7 @@ name=` + tt.configName + `
 			if err := ctx.SetupBackupDirectory(); err != nil {
 				t.Fatalf("Failed to setup backup directory: %v", err)
 			}
+			defer ctx.unlockBackupFolder()
 
 			// Load config files
 			currentFS, files, err := ctx.LoadConfigFiles()
@@ -724,9 +659,7 @@ name=` + tt.configName + `
 
 // TestProcessConfiguration_PartialBackupFailure tests error aggregation
 func TestProcessConfiguration_PartialBackupFailure(t *testing.T) {
-	tempDir, backupDir := setup
-
-BackupTestDirs(t)
+	tempDir, backupDir := setupBackupTestDirs(t)
 	defer os.RemoveAll(tempDir)
 	defer os.RemoveAll(backupDir)
 
@@ -736,13 +669,14 @@ BackupTestDirs(t)
 		t.Fatal(err)
 	}
 
-	// Create a config that references both existing and non-accessible files
+	// Create a config that references both existing and non-accessible files.
+	// A path below a regular file cannot be accessed, whoever runs the test.
 	configContent := `[application]
 name = TestPartialFailure
 
 [configuration_files]
 good_file.txt
-/root/inaccessible.txt
+good_file.txt/inaccessible.txt
 another_good.txt
 `
 	configPath := filepath.Join(configDir, "partial.cfg")
@@ -757,7 +691,7 @@ another_good.txt
 	os.WriteFile(goodFile2, []byte("content2"), 0644)
 
 	// Run backup - should aggregate errors
-	err := ProcessConfiguration(configDir, backupDir, []string{}, true, false, 1, false, "")
+	result, err := ProcessConfiguration(configDir, backupDir, []string{}, true, false, 1, false, "")
 	
 	// Should return error indicating partial failure
 	if err == nil {
@@ -770,6 +704,10 @@ another_good.txt
 		t.Errorf("Error message should indicate failures, got: %s", errMsg)
 	}
 
+	if result == nil || result.Count(ItemSucceeded) != 2 || result.Count(ItemFailed) != 1 {
+		t.Fatalf("Expected 2 succeeded and 1 failed item, got %+v", result)
+	}
+
 	// Good files should still be backed up
 	timestamp := getLatestTimestamp(t, backupDir)
 	goodBackup1 := filepath.Join(backupDir, timestamp, "TestPartialFailure", "good_file.txt")
@@ -783,6 +721,26 @@ another_good.txt
 	}
 }
 
+// setupBackupTestDirs creates a temporary home directory, which is also used
+// as the test root, and a backup directory.
+func setupBackupTestDirs(t *testing.T) (tempDir, backupDir string) {
+	setupBackupTestDependencies()
+
+	tempDir = t.TempDir()
+	backupDir = filepath.Join(t.TempDir(), "backups")
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	originalGetHomeDir := config.GetHomeDirectory
+	config.GetHomeDirectory = func() (string, error) {
+		return tempDir, nil
+	}
+	t.Cleanup(func() { config.GetHomeDirectory = originalGetHomeDir })
+
+	return tempDir, backupDir
+}
+
 func getLatestTimestamp(t *testing.T, backupDir string) string {
 	entries, err := os.ReadDir(backupDir)
 	if err != nil || len(entries) == 0 {
@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"SettingsSentry/pkg/command"
+	"SettingsSentry/pkg/config"
+	"bytes"
+	"fmt"
+	"path"
+	"time"
+)
+
+// commandOutputPath returns the logical stored path of a command output.
+func commandOutputPath(appName, name string) string {
+	return path.Join(appName, commandOutputPrefix, name)
+}
+
+// outputItem returns the result item of a command output: from the command to
+// the stored output on backup, and back on restore.
+func (ctx *BackupContext) outputItem(appName string, output config.CommandOutput) ItemResult {
+	stored := ctx.storedDisplayPath(commandOutputPath(appName, output.Name))
+	if ctx.IsBackup {
+		return ItemResult{Source: "$ " + output.Command, Destination: stored}
+	}
+	return ItemResult{Source: stored, Destination: "$ " + output.RestoreCommand}
+}
+
+// BackupCommandOutput runs a [command_outputs] command and stores its standard
+// output in the version being created.
+func (ctx *BackupContext) BackupCommandOutput(appName string, output config.CommandOutput) error {
+	stored := commandOutputPath(appName, output.Name)
+	item := ctx.outputItem(appName, output)
+	item.Status = ItemSucceeded
+	if DryRun {
+		ctx.Printer.Print("Would store output of '%s' as %s", output.Command, ctx.displayStoredPath(stored))
+		ctx.record(item)
+		return nil
+	}
+
+	started := time.Now()
+	commandLine, opts, err := config.ParseCommand(output.Command)
+	if err != nil {
+		return err
+	}
+	data, err := command.CaptureCommandLine(commandLine, opts)
+	if err != nil {
+		return fmt.Errorf("command output %s: %w", output.Name, err)
+	}
+
+	entry, err := ctx.writeStoredData(ctx.versionRoot(), ManifestEntry{
+		Command: output.Command,
+		Stored:  stored,
+		Size:    int64(len(data)),
+		Mode:    0644,
+		ModTime: time.Now().UTC(),
+	}, data)
+	if err != nil {
+		return err
+	}
+	if ctx.Manifest != nil {
+		ctx.Manifest.AddEntry(appName, entry)
+	}
+	ctx.Printer.Print("Stored output of '%s' as %s", output.Command, ctx.displayStoredPath(entry.Stored))
+	item.Bytes, item.Duration = int64(len(data)), time.Since(started)
+	ctx.record(item)
+	return nil
+}
+
+// RestoreCommandOutput feeds a stored command output to its [command_inputs]
+// command on standard input. Outputs without a restore command are kept in the
+// version only.
+func (ctx *BackupContext) RestoreCommandOutput(version *backupVersion, appName string, output config.CommandOutput) error {
+	if output.RestoreCommand == "" {
+		return nil
+	}
+
+	stored := commandOutputPath(appName, output.Name)
+	item := ctx.outputItem(appName, output)
+	var file *versionFile
+	for i := range version.Files {
+		if version.Files[i].Path == stored {
+			file = &version.Files[i]
+			break
+		}
+	}
+	if file == nil {
+		ctx.Printer.Print("No stored output %s in this version, skipping '%s'", output.Name, output.RestoreCommand)
+		item.Status, item.Reason = ItemSkipped, "not in backup"
+		ctx.record(item)
+		return nil
+	}
+
+	if DryRun {
+		ctx.Printer.Print("Would run '%s' with the stored output %s", output.RestoreCommand, output.Name)
+		ctx.record(item)
+		return nil
+	}
+
+	started := time.Now()
+	data, err := readStoredFile(version, *file, ctx.Password)
+	if err != nil {
+		return err
+	}
+	commandLine, opts, err := config.ParseCommand(output.RestoreCommand)
+	if err != nil {
+		return err
+	}
+	opts.Stdin = bytes.NewReader(data)
+	if err := command.RunCommandLine(commandLine, opts); err != nil {
+		return fmt.Errorf("command input %s: %w", output.Name, err)
+	}
+	item.Bytes, item.Duration = int64(len(data)), time.Since(started)
+	ctx.record(item)
+	return nil
+}
@@ -0,0 +1,367 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DiffStatus describes how a live file relates to its copy in a backup version.
+type DiffStatus string
+
+const (
+	DiffUnchanged    DiffStatus = "unchanged"
+	DiffModified     DiffStatus = "modified"
+	DiffOnlyInBackup DiffStatus = "only in backup"
+	DiffOnlyOnDisk   DiffStatus = "only on disk"
+	DiffError        DiffStatus = "error"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change.
+const diffContextLines = 3
+
+// maxDiffCells bounds the size of the table used to compute line diffs, so
+// very large text files fall back to a size and checksum comparison.
+const maxDiffCells = 4_000_000
+
+// FileDiff is the comparison of one live file with the backup version.
+type FileDiff struct {
+	App    string
+	Path   string
+	Status DiffStatus
+	// Binary is set when either side is not text; no line diff is produced.
+	Binary bool
+	// Diff is the unified diff from the live file to the backed up file, set
+	// for modified text files.
+	Diff         string
+	LiveSize     int64
+	BackupSize   int64
+	LiveSHA256   string
+	BackupSHA256 string
+	Err          error
+}
+
+// CompareWithVersion compares the files of the selected applications on disk
+// with their copies in the backup version chosen by opts.Version and
+// opts.Before, showing what a restore would change.
+func CompareWithVersion(opts Options) ([]FileDiff, string, error) {
+	ctx, err := NewBackupContext(opts.ConfigFolder, opts.BackupFolder, opts.AppNames, false, false, 0, false, opts.Password)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating backup context: %w", err)
+	}
+	if ctx.TargetDir, err = resolveTargetDir(opts.Target); err != nil {
+		return nil, "", err
+	}
+
+	configs, err := ctx.LoadConfigs()
+	if err != nil {
+		return nil, "", err
+	}
+
+	selected, err := SelectVersion(ctx.BackupFolder, opts.Version, opts.Before)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to select version to compare: %w", err)
+	}
+	version, err := openBackupVersion(selected.Path, selected.IsZip)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open backup version '%s': %w", selected.Path, err)
+	}
+	defer func() {
+		if err := version.Close(); err != nil {
+			AppLogger.Errorf("failed to close backup version %s: %v", selected.Path, err)
+		}
+	}()
+
+	var diffs []FileDiff
+	for _, cfg := range configs {
+		ctx.Excludes = cfg.Excludes
+		for _, configFile := range cfg.Files {
+			destPath := ctx.ResolveConfigFilePath(configFile)
+			if hasGlobMeta(destPath) {
+				diffs = append(diffs, ctx.comparePattern(version, cfg.Name, configFile, destPath)...)
+			} else {
+				diffs = append(diffs, ctx.compareEntry(version, cfg.Name, destPath)...)
+			}
+		}
+	}
+	return diffs, selected.Name, nil
+}
+
+// compareEntry compares one configured path, file or directory, with the
+// files stored for it.
+func (ctx *BackupContext) compareEntry(version *backupVersion, appName, destPath string) []FileDiff {
+	_, targets, err := ctx.restoreTargets(version, appName, destPath)
+	if err != nil {
+		return []FileDiff{{App: appName, Path: destPath, Status: DiffError, Err: err}}
+	}
+
+	return ctx.compareTargets(version, appName, targets, []string{ctx.RestoreDestination(destPath)})
+}
+
+// comparePattern compares the files captured for a glob pattern with the
+// files the pattern matches on disk now.
+func (ctx *BackupContext) comparePattern(version *backupVersion, appName, configFile, resolvedPattern string) []FileDiff {
+	targets, err := ctx.patternTargets(version, appName, configFile, resolvedPattern)
+	if err != nil {
+		return []FileDiff{{App: appName, Path: resolvedPattern, Status: DiffError, Err: err}}
+	}
+	return ctx.compareTargets(version, appName, targets, ctx.ExpandGlob(ctx.RestoreDestination(resolvedPattern)))
+}
+
+// compareTargets compares stored files with the files they restore to, and
+// reports the files below livePaths that are not in the backup.
+func (ctx *BackupContext) compareTargets(version *backupVersion, appName string, targets []restoreTarget, livePaths []string) []FileDiff {
+	var diffs []FileDiff
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		seen[t.path] = true
+		diffs = append(diffs, compareFile(version, t, appName, ctx.Password))
+	}
+
+	// Files present on disk but not in the backup are left alone by restore
+	for _, livePath := range livePaths {
+		info, err := ctx.FS.Stat(livePath)
+		if err != nil {
+			continue
+		}
+		live := []storedFile{{source: livePath, info: info}}
+		if info.IsDir() {
+			if live, err = ctx.collectDirectory(livePath, ""); err != nil {
+				diffs = append(diffs, FileDiff{App: appName, Path: livePath, Status: DiffError, Err: err})
+				continue
+			}
+		}
+		for _, f := range live {
+			if !seen[f.source] {
+				diffs = append(diffs, FileDiff{App: appName, Path: f.source, Status: DiffOnlyOnDisk, LiveSize: f.info.Size()})
+			}
+		}
+	}
+	return diffs
+}
+
+// compareFile compares a single stored file with the file it restores to.
+func compareFile(version *backupVersion, t restoreTarget, appName, password string) FileDiff {
+	d := FileDiff{App: appName, Path: t.path}
+
+	backupData, err := readStoredFile(version, t.file, password)
+	if err != nil {
+		d.Status, d.Err = DiffError, err
+		return d
+	}
+	d.BackupSize = int64(len(backupData))
+	d.BackupSHA256 = sha256Hex(backupData)
+
+	liveData, err := readLiveFile(t.path, t.file.Entry != nil && isSymlink(t.file.Entry.Mode))
+	if os.IsNotExist(err) {
+		d.Status = DiffOnlyInBackup
+		return d
+	} else if err != nil {
+		d.Status, d.Err = DiffError, fmt.Errorf("error reading %s: %w", t.path, err)
+		return d
+	}
+	d.LiveSize = int64(len(liveData))
+	d.LiveSHA256 = sha256Hex(liveData)
+
+	if bytes.Equal(liveData, backupData) {
+		d.Status = DiffUnchanged
+		return d
+	}
+	d.Status = DiffModified
+
+	if isBinary(liveData) || isBinary(backupData) {
+		d.Binary = true
+		return d
+	}
+	d.Diff = unifiedDiff(t.path+" (current)", t.path+" (backup)", splitLines(string(liveData)), splitLines(string(backupData)))
+	return d
+}
+
+// PrintDiffs writes the differences found by CompareWithVersion.
+func PrintDiffs(diffs []FileDiff, versionName string) {
+	AppLogger.Outputf("Comparing current files with backup version %s", versionName)
+
+	counts := make(map[DiffStatus]int)
+	for _, d := range diffs {
+		counts[d.Status]++
+		switch d.Status {
+		case DiffModified:
+			switch {
+			case d.Diff != "":
+				logLines(d.Diff)
+			case d.Binary:
+				AppLogger.Outputf("Binary file %s differs: size %s -> %s, sha256 %s -> %s",
+					d.Path, formatSize(d.LiveSize), formatSize(d.BackupSize), shortHash(d.LiveSHA256), shortHash(d.BackupSHA256))
+			default:
+				AppLogger.Outputf("File %s differs (too large for a line diff): size %s -> %s, sha256 %s -> %s",
+					d.Path, formatSize(d.LiveSize), formatSize(d.BackupSize), shortHash(d.LiveSHA256), shortHash(d.BackupSHA256))
+			}
+		case DiffOnlyInBackup:
+			AppLogger.Outputf("Only in backup: %s (%s, restore would create it)", d.Path, formatSize(d.BackupSize))
+		case DiffOnlyOnDisk:
+			AppLogger.Outputf("Only on disk: %s (not in backup, restore leaves it untouched)", d.Path)
+		case DiffError:
+			AppLogger.Errorf("failed to compare %s: %v", d.Path, d.Err)
+		}
+	}
+
+	AppLogger.Outputf("%d modified, %d only in backup, %d only on disk, %d unchanged, %d error(s)",
+		counts[DiffModified], counts[DiffOnlyInBackup], counts[DiffOnlyOnDisk], counts[DiffUnchanged], counts[DiffError])
+}
+
+// isBinary reports whether data looks like binary rather than text content.
+func isBinary(data []byte) bool {
+	sample := data
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	return bytes.IndexByte(sample, 0) >= 0 || !utf8.Valid(data)
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// splitLines splits text into lines without their line terminators.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines computes a minimal line edit script turning a into b from the
+// longest common subsequence of both. It returns nil when the inputs are too
+// large to compare line by line.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if (n+1)*(m+1) > maxDiffCells {
+		return nil
+	}
+
+	// lcs[i*(m+1)+j] is the LCS length of a[i:] and b[j:]
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// unifiedDiff renders the differences between a and b in unified diff format.
+// It returns an empty string when the inputs are equal or too large.
+func unifiedDiff(fromName, toName string, a, b []string) string {
+	ops := diffLines(a, b)
+	if ops == nil {
+		return ""
+	}
+
+	// aPos[k] and bPos[k] count the lines of a and b consumed before ops[k]
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op.kind != '+' {
+			aPos[k+1]++
+		}
+		if op.kind != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	var out strings.Builder
+	for k := 0; k < len(ops); {
+		for k < len(ops) && ops[k].kind == ' ' {
+			k++
+		}
+		if k == len(ops) {
+			break
+		}
+
+		start := max(k-diffContextLines, 0)
+		end := k
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*diffContextLines {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := min(end+diffContextLines, len(ops))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[stop]-aPos[start]), hunkRange(bPos[start], bPos[stop]-bPos[start]))
+		for _, op := range ops[start:stop] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		k = stop
+	}
+	return out.String()
+}
+
+// hunkRange formats the line range of a hunk header, which starts at the line
+// before the hunk when the hunk holds no lines from that side.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
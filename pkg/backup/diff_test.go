@@ -0,0 +1,147 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+		{
+			name: "single change with context",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			b:    "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n",
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "new\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n",
+		},
+		{
+			name: "append line",
+			a:    "x\n",
+			b:    "x\ny\n",
+			want: "--- a\n+++ b\n@@ -1 +1,2 @@\n x\n+y\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("a", "b", splitLines(tt.a), splitLines(tt.b))
+			if got != tt.want {
+				t.Errorf("unifiedDiff mismatch\ngot:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if isBinary([]byte("plain text\n")) {
+		t.Error("Text should not be binary")
+	}
+	if !isBinary([]byte{'a', 0, 'b'}) {
+		t.Error("Data with NUL bytes should be binary")
+	}
+	if !isBinary([]byte{0xff, 0xfe, 0xfd}) {
+		t.Error("Invalid UTF-8 should be binary")
+	}
+}
+
+func TestCompareWithVersion(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	gitconfig := filepath.Join(homeDir, ".gitconfig")
+	binary := filepath.Join(homeDir, ".config", "git", "blob")
+	createDummyFile(t, gitconfig, "[user]\n\tname = old\n")
+	createDummyFile(t, filepath.Join(homeDir, ".config", "git", "ignore"), "*.swp\n")
+	createDummyFile(t, binary, "\x00\x01")
+	createDummyFile(t, filepath.Join(homeDir, ".config", "git", "deleted"), "gone soon\n")
+	createDummyFile(t, filepath.Join(configDir, "git.cfg"), `[application]
+name = Git
+[configuration_files]
+.gitconfig
+.config/git
+`)
+
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, true, "diff-password")
+
+	// Change the live files after the backup
+	createDummyFile(t, gitconfig, "[user]\n\tname = new\n")
+	createDummyFile(t, binary, "\x00\x02\x03")
+	createDummyFile(t, filepath.Join(homeDir, ".config", "git", "added"), "local only\n")
+	if err := os.Remove(filepath.Join(homeDir, ".config", "git", "deleted")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	diffs, versionName, err := CompareWithVersion(Options{ConfigFolder: configDir, BackupFolder: backupDir, Password: "diff-password"})
+	if err != nil {
+		t.Fatalf("CompareWithVersion failed: %v", err)
+	}
+	if versionName == "" {
+		t.Error("Expected the compared version name")
+	}
+
+	byPath := make(map[string]FileDiff)
+	for _, d := range diffs {
+		byPath[strings.TrimPrefix(d.Path, homeDir+string(filepath.Separator))] = d
+	}
+	expect := map[string]DiffStatus{
+		".gitconfig":          DiffModified,
+		".config/git/ignore":  DiffUnchanged,
+		".config/git/blob":    DiffModified,
+		".config/git/added":   DiffOnlyOnDisk,
+		".config/git/deleted": DiffOnlyInBackup,
+	}
+	if len(byPath) != len(expect) {
+		t.Errorf("Got %d diffs, want %d: %+v", len(byPath), len(expect), diffs)
+	}
+	for path, status := range expect {
+		if d := byPath[filepath.FromSlash(path)]; d.Status != status {
+			t.Errorf("%s: status = %q, want %q (err=%v)", path, d.Status, status, d.Err)
+		}
+	}
+
+	text := byPath[".gitconfig"]
+	if !strings.Contains(text.Diff, "-\tname = new\n+\tname = old\n") {
+		t.Errorf("Unexpected diff for .gitconfig:\n%s", text.Diff)
+	}
+	bin := byPath[filepath.Join(".config", "git", "blob")]
+	if !bin.Binary || bin.Diff != "" || bin.LiveSize != 3 || bin.BackupSize != 2 {
+		t.Errorf("Unexpected binary diff: %+v", bin)
+	}
+
+	// Without the password encrypted entries cannot be compared
+	diffs, _, err = CompareWithVersion(Options{ConfigFolder: configDir, BackupFolder: backupDir, AppNames: []string{"git"}})
+	if err != nil {
+		t.Fatalf("CompareWithVersion failed: %v", err)
+	}
+	errors := 0
+	for _, d := range diffs {
+		if d.Status == DiffError {
+			errors++
+		}
+	}
+	if errors != 4 {
+		t.Errorf("Expected 4 comparison errors without password, got %d", errors)
+	}
+}
@@ -0,0 +1,35 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// specialFileMode covers sockets, pipes and devices, which are never backed up.
+const specialFileMode = os.ModeSocket | os.ModeNamedPipe | os.ModeDevice | os.ModeCharDevice | os.ModeIrregular
+
+// isExcluded reports whether a path matches one of the exclude patterns of the
+// application being processed. Patterns without a slash match a file or folder
+// name at any depth; other patterns are resolved like configuration entries and
+// matched against the full path.
+func (ctx *BackupContext) isExcluded(sourcePath string) bool {
+	name := filepath.Base(sourcePath)
+	var segments []string
+	for _, pattern := range ctx.Excludes {
+		if !strings.Contains(pattern, "/") {
+			if matchSegment(pattern, name) {
+				return true
+			}
+			continue
+		}
+		if segments == nil {
+			segments = strings.Split(filepath.ToSlash(filepath.Clean(sourcePath)), "/")
+		}
+		resolved := filepath.ToSlash(ctx.ResolveConfigFilePath(pattern))
+		if matchSegments(strings.Split(resolved, "/"), segments) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsExcluded(t *testing.T) {
+	setupBackupTestDependencies()
+
+	ctx := &BackupContext{
+		HomeDir:  "/Users/test",
+		FS:       Fs,
+		Excludes: []string{"*.log", "Cache", ".config/nvim/plugged/**", "~/.config/app/*.lock"},
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/Users/test/.config/app/debug.log", true},
+		{"/Users/test/.config/app/Cache", true},
+		{"/Users/test/.config/app/settings.json", false},
+		{"/Users/test/.config/nvim/plugged/vim-go/plugin.vim", true},
+		{"/Users/test/.config/nvim/init.lua", false},
+		{"/Users/test/.config/app/state.lock", true},
+		{"/Users/test/.config/app/nested/state.lock", false},
+	}
+
+	for _, tt := range tests {
+		if got := ctx.isExcluded(tt.path); got != tt.want {
+			t.Errorf("isExcluded(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestProcess_ExcludedFilesAreSkipped(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	appDir := filepath.Join(homeDir, ".config", "app")
+	createDummyFile(t, filepath.Join(appDir, "settings.json"), "{}")
+	createDummyFile(t, filepath.Join(appDir, "debug.log"), "noise")
+	createDummyFile(t, filepath.Join(appDir, "Cache", "blob"), "cached")
+	createDummyFile(t, filepath.Join(appDir, "state", "session"), "session")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), `[application]
+name = App
+[configuration_files]
+.config/app
+[exclude]
+*.log
+Cache
+.config/app/state
+`)
+
+	listener, err := net.Listen("unix", filepath.Join(appDir, "app.sock"))
+	if err != nil {
+		t.Skipf("Unix sockets not available: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, "")
+
+	versionPath, _, err := GetLatestVersionPath(backupDir)
+	if err != nil {
+		t.Fatalf("GetLatestVersionPath failed: %v", err)
+	}
+	entries := readTestManifest(t, versionPath, false).Entries()
+	if len(entries) != 1 || entries[0].Source != filepath.Join(appDir, "settings.json") {
+		t.Errorf("Expected only settings.json to be backed up, got %+v", entries)
+	}
+	for _, rel := range []string{"debug.log", "Cache", "state", "app.sock"} {
+		if _, err := os.Stat(filepath.Join(versionPath, "App", ".config", "app", rel)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be stored, stat err = %v", rel, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package backup
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// globStarStar matches zero or more path segments in a configuration pattern.
+const globStarStar = "**"
+
+// hasGlobMeta reports whether a configuration path is a pattern. Only '*' and
+// '?' are special; brackets are matched literally since they are common in
+// file names.
+func hasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, "*?")
+}
+
+// matchSegment matches a single path segment against a pattern segment.
+func matchSegment(pattern, name string) bool {
+	escaped := strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(pattern)
+	ok, err := path.Match(escaped, name)
+	return err == nil && ok
+}
+
+// matchSegments matches slash-separated path segments against pattern
+// segments, where "**" matches any number of segments.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == globStarStar {
+		for k := 0; k <= len(segments); k++ {
+			if matchSegments(pattern[1:], segments[k:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 || !matchSegment(pattern[0], segments[0]) {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// ExpandGlob returns the existing files and directories matching a resolved,
+// absolute configuration pattern, sorted. Matches nested below another match
+// are dropped, since backing up a directory already includes its content.
+func (ctx *BackupContext) ExpandGlob(pattern string) []string {
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+
+	i := 0
+	for i < len(segments) && !hasGlobMeta(segments[i]) {
+		i++
+	}
+	root := strings.Join(segments[:i], "/")
+	if root == "" {
+		root = "/"
+	}
+
+	var matches []string
+	ctx.globWalk(filepath.FromSlash(root), segments[i:], &matches)
+	sort.Strings(matches)
+
+	var pruned []string
+	for _, m := range matches {
+		if n := len(pruned); n > 0 {
+			last := pruned[n-1]
+			if m == last || strings.HasPrefix(m, last+string(filepath.Separator)) {
+				continue
+			}
+		}
+		pruned = append(pruned, m)
+	}
+	return pruned
+}
+
+// globWalk collects the paths below dir matching the remaining pattern
+// segments. Unreadable directories are skipped, and "**" matches a symlink but
+// does not expand below it.
+func (ctx *BackupContext) globWalk(dir string, segments []string, matches *[]string) {
+	if len(segments) == 0 {
+		*matches = append(*matches, dir)
+		return
+	}
+
+	seg := segments[0]
+	if seg == globStarStar {
+		ctx.globWalk(dir, segments[1:], matches)
+		entries, err := ctx.FS.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			// "**" does not descend into symlinked directories, which could
+			// link back to an ancestor and recurse forever
+			if entry.Type()&fs.ModeSymlink != 0 {
+				ctx.globWalk(ctx.FS.Join(dir, entry.Name()), segments[1:], matches)
+				continue
+			}
+			ctx.globWalk(ctx.FS.Join(dir, entry.Name()), segments, matches)
+		}
+		return
+	}
+
+	if !hasGlobMeta(seg) {
+		next := ctx.FS.Join(dir, seg)
+		if _, err := ctx.FS.Stat(next); err == nil {
+			ctx.globWalk(next, segments[1:], matches)
+		}
+		return
+	}
+
+	entries, err := ctx.FS.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !matchSegment(seg, entry.Name()) {
+			continue
+		}
+		if len(segments) > 1 && !entry.IsDir() {
+			continue
+		}
+		ctx.globWalk(ctx.FS.Join(dir, entry.Name()), segments[1:], matches)
+	}
+}
+
+// BackupPattern backs up every path matching a configuration pattern. Each
+// stored file records the pattern in the manifest so restore recreates exactly
+// the files that were captured.
+func (ctx *BackupContext) BackupPattern(appName, configFile, resolvedPattern string) error {
+	matches := ctx.ExpandGlob(resolvedPattern)
+	if len(matches) == 0 {
+		ctx.Printer.Print("No files match pattern %s", configFile)
+		ctx.recordSkip(configFile, "no matching files")
+		return nil
+	}
+	for _, match := range matches {
+		if ctx.isExcluded(match) {
+			ctx.recordSkip(match, "excluded")
+			continue
+		}
+		if err := ctx.backupEntry(appName, match, configFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestorePattern restores the files captured for a configuration pattern. For
+// versions with a manifest these are exactly the files recorded under the
+// pattern; older versions are searched by matching the pattern against the
+// stored paths.
+func (ctx *BackupContext) RestorePattern(version *backupVersion, appName, configFile, resolvedPattern string) error {
+	targets, err := ctx.patternTargets(version, appName, configFile, resolvedPattern)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		ctx.Printer.Print("No backed up files match pattern %s", configFile)
+		ctx.recordSkip(configFile, "not in backup")
+		return nil
+	}
+	for _, t := range targets {
+		if err := ctx.applyRestore(version, appName, t.file.Path, t.path, []restoreTarget{t}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patternTargets selects the stored files belonging to a configuration
+// pattern and the paths they are restored to. The pattern recorded in the
+// manifest is not trusted on its own: every selected file must be the path of
+// a match, or lie below a matched directory.
+func (ctx *BackupContext) patternTargets(version *backupVersion, appName, configFile, resolvedPattern string) ([]restoreTarget, error) {
+	var selected []versionFile
+	for _, f := range version.Files {
+		if f.App == appName && f.Entry != nil && f.Entry.Pattern == configFile {
+			selected = append(selected, f)
+		}
+	}
+
+	if len(selected) == 0 {
+		storedPattern := strings.Split(path.Join(appName, ctx.StoredRelPath(resolvedPattern)), "/")
+		for _, f := range version.Files {
+			if f.App == appName && matchesOrIsBelow(storedPattern, strings.Split(f.Path, "/")) {
+				selected = append(selected, f)
+			}
+		}
+	}
+
+	pattern := strings.Split(filepath.ToSlash(filepath.Clean(resolvedPattern)), "/")
+	targets := make([]restoreTarget, 0, len(selected))
+	for _, f := range selected {
+		source, err := ctx.sourceFromStored(appName, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !matchesOrIsBelow(pattern, strings.Split(filepath.ToSlash(source), "/")) {
+			return nil, fmt.Errorf("stored path '%s' does not match pattern %s", f.Path, configFile)
+		}
+		targets = append(targets, restoreTarget{file: f, path: ctx.RestoreDestination(source)})
+	}
+	return targets, nil
+}
+
+// matchesOrIsBelow reports whether segments, or one of their parent
+// directories, match the pattern: a matched directory is stored with all its
+// content.
+func matchesOrIsBelow(pattern, segments []string) bool {
+	for n := len(segments); n > 1; n-- {
+		if matchSegments(pattern, segments[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceFromStored maps a logical stored path back to the absolute path it was
+// backed up from on this machine: the inverse of StoredRelPath.
+func (ctx *BackupContext) sourceFromStored(appName, storedPath string) (string, error) {
+	rel, ok := strings.CutPrefix(storedPath, appName+"/")
+	if !ok || rel == "" {
+		return "", fmt.Errorf("invalid stored path '%s' for %s", storedPath, appName)
+	}
+	for _, segment := range strings.Split(rel, "/") {
+		if segment == ".." || segment == "." || segment == "" {
+			return "", fmt.Errorf("invalid stored path '%s': contains relative segments", storedPath)
+		}
+	}
+	if abs, ok := strings.CutPrefix(rel, absolutePathPrefix+"/"); ok {
+		return filepath.FromSlash("/" + abs), nil
+	}
+	return filepath.Join(ctx.HomeDir, filepath.FromSlash(rel)), nil
+}
@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"a/*.json", "a/settings.json", true},
+		{"a/*.json", "a/b/settings.json", false},
+		{"a/?.txt", "a/x.txt", true},
+		{"a/**/*.json", "a/settings.json", true},
+		{"a/**/*.json", "a/b/c/settings.json", true},
+		{"a/**", "a/b/c", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"a/[x].json", "a/[x].json", true},
+		{"a/[x].json", "a/x.json", false},
+	}
+
+	for _, tt := range tests {
+		got := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.path, "/"))
+		if got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExpandGlob(t *testing.T) {
+	setupBackupTestDependencies()
+	home := t.TempDir()
+	for _, rel := range []string{
+		"Library/Preferences/com.foo.app.plist",
+		"Library/Preferences/com.foo.helper.plist",
+		"Library/Preferences/com.bar.plist",
+		"Code/User/settings.json",
+		"Code/User/snippets/go.json",
+		"Code/User/snippets/notes.txt",
+	} {
+		createDummyFile(t, filepath.Join(home, rel), rel)
+	}
+	ctx := &BackupContext{HomeDir: home, FS: Fs}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"Library/Preferences/com.foo.*.plist", []string{
+			"Library/Preferences/com.foo.app.plist",
+			"Library/Preferences/com.foo.helper.plist",
+		}},
+		{"Code/**/*.json", []string{"Code/User/settings.json", "Code/User/snippets/go.json"}},
+		{"Code/*", []string{"Code/User"}},
+		// Content of a matched directory is not listed separately
+		{"Code/**", []string{"Code"}},
+		{"Missing/*", nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, m := range ctx.ExpandGlob(filepath.Join(home, tt.pattern)) {
+			rel, _ := filepath.Rel(home, m)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandGlob(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestExpandGlob_SymlinkCycle(t *testing.T) {
+	setupBackupTestDependencies()
+	home := t.TempDir()
+	createDummyFile(t, filepath.Join(home, "Code/User/settings.json"), "{}")
+	if err := os.Symlink(filepath.Join(home, "Code"), filepath.Join(home, "Code/User/parent")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(home, "Code/User/settings.json"), filepath.Join(home, "Code/User/link.json")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	ctx := &BackupContext{HomeDir: home, FS: Fs}
+
+	var got []string
+	for _, m := range ctx.ExpandGlob(filepath.Join(home, "Code/**/*.json")) {
+		rel, _ := filepath.Rel(home, m)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"Code/User/link.json", "Code/User/settings.json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandGlob = %v, want %v", got, want)
+	}
+}
+
+func TestProcess_GlobRoundTrip(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	prefs := filepath.Join(homeDir, "Library", "Preferences")
+	createDummyFile(t, filepath.Join(prefs, "com.foo.app.plist"), "app")
+	createDummyFile(t, filepath.Join(prefs, "com.foo.helper.plist"), "helper")
+	createDummyFile(t, filepath.Join(prefs, "com.bar.plist"), "bar")
+	createDummyFile(t, filepath.Join(homeDir, ".config", "foo", "themes", "dark.json"), "dark")
+	createDummyFile(t, filepath.Join(configDir, "foo.cfg"), `[application]
+name = Foo
+[configuration_files]
+Library/Preferences/com.foo.*.plist
+.config/foo/**/*.json
+`)
+
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, "")
+
+	versionPath, _, err := GetLatestVersionPath(backupDir)
+	if err != nil {
+		t.Fatalf("GetLatestVersionPath failed: %v", err)
+	}
+	m := readTestManifest(t, versionPath, false)
+	entries := m.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 manifest entries, got %+v", entries)
+	}
+	for _, entry := range entries {
+		if entry.Pattern == "" || strings.Contains(entry.Source, "com.bar") {
+			t.Errorf("Unexpected manifest entry: %+v", entry)
+		}
+	}
+
+	if err := os.RemoveAll(prefs); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(homeDir, ".config")); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+	// A file matching the pattern only after the backup is left alone
+	createDummyFile(t, filepath.Join(prefs, "com.foo.new.plist"), "new")
+
+	ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, "")
+
+	assertFileContent(t, filepath.Join(prefs, "com.foo.app.plist"), "app")
+	assertFileContent(t, filepath.Join(prefs, "com.foo.helper.plist"), "helper")
+	assertFileContent(t, filepath.Join(homeDir, ".config", "foo", "themes", "dark.json"), "dark")
+	assertFileContent(t, filepath.Join(prefs, "com.foo.new.plist"), "new")
+	if _, err := os.Stat(filepath.Join(prefs, "com.bar.plist")); !os.IsNotExist(err) {
+		t.Errorf("com.bar.plist should not be restored, stat err = %v", err)
+	}
+}
+
+func TestSourceFromStored(t *testing.T) {
+	ctx := &BackupContext{HomeDir: "/Users/test"}
+
+	if got, err := ctx.sourceFromStored("App", "App/.config/app/a.json"); err != nil || got != "/Users/test/.config/app/a.json" {
+		t.Errorf("Home path: got %q, %v", got, err)
+	}
+	if got, err := ctx.sourceFromStored("App", "App/_root/etc/app.conf"); err != nil || got != "/etc/app.conf" {
+		t.Errorf("Absolute path: got %q, %v", got, err)
+	}
+	if _, err := ctx.sourceFromStored("App", "App/../../etc/passwd"); err == nil {
+		t.Error("Expected error for path escaping the home directory")
+	}
+}
+
+func TestPatternTargets_RejectsPathOutsidePattern(t *testing.T) {
+	ctx := &BackupContext{HomeDir: "/Users/test"}
+	pattern := "/Users/test/Library/Preferences/com.foo.*.plist"
+	configFile := "Library/Preferences/com.foo.*.plist"
+	entry := &ManifestEntry{Pattern: configFile}
+
+	version := &backupVersion{Files: []versionFile{
+		{App: "Foo", Path: "Foo/Library/Preferences/com.foo.app.plist", Entry: entry},
+	}}
+	targets, err := ctx.patternTargets(version, "Foo", configFile, pattern)
+	if err != nil || len(targets) != 1 || targets[0].path != "/Users/test/Library/Preferences/com.foo.app.plist" {
+		t.Fatalf("Expected the matching file to be selected, got %+v, %v", targets, err)
+	}
+
+	// A manifest edited to record another file under the pattern
+	version.Files = append(version.Files, versionFile{App: "Foo", Path: "Foo/.ssh/authorized_keys", Entry: entry})
+	if targets, err := ctx.patternTargets(version, "Foo", configFile, pattern); err == nil {
+		t.Errorf("Expected a stored path outside the pattern to be rejected, got %+v", targets)
+	}
+}
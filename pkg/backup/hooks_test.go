@@ -0,0 +1,193 @@
+package backup
+
+import (
+	"SettingsSentry/interfaces"
+	"SettingsSentry/pkg/command"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupHookTest runs commands through a real shell for the duration of a test.
+func setupHookTest(t *testing.T) {
+	originalExecutor := command.CmdExecutor
+	command.CmdExecutor = interfaces.NewOsCommandExecutor()
+	command.Printer = Printer
+	TrustOnFirstUse = true
+	t.Cleanup(func() {
+		command.CmdExecutor = originalExecutor
+		TrustOnFirstUse = false
+	})
+}
+
+func TestProcess_CommandPhasesRunAroundFiles(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	setupHookTest(t)
+
+	generated := filepath.Join(homeDir, ".generated")
+	order := filepath.Join(t.TempDir(), "order")
+	probe := func(phase string) string {
+		return fmt.Sprintf(`test -e %q && echo %s:present >> %q || echo %s:absent >> %q`, generated, phase, order, phase, order)
+	}
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), fmt.Sprintf(`[application]
+name = App
+[pre_backup_commands]
+echo generated > %q
+[post_backup_commands]
+rm %q
+[pre_restore_commands]
+%s
+[post_restore_commands]
+%s
+[configuration_files]
+.generated
+`, generated, generated, probe("pre"), probe("post")))
+
+	ProcessConfiguration(configDir, backupDir, nil, true, true, 1, false, "")
+
+	// The pre-backup output was captured before the post-backup cleanup
+	versionPath, _, err := GetLatestVersionPath(backupDir)
+	if err != nil {
+		t.Fatalf("GetLatestVersionPath failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(versionPath, "App", ".generated"), "generated\n")
+	if _, err := os.Stat(generated); !os.IsNotExist(err) {
+		t.Errorf("Post-backup command should have removed %s, stat err = %v", generated, err)
+	}
+
+	ProcessConfiguration(configDir, backupDir, nil, false, true, 1, false, "")
+	assertFileContent(t, order, "pre:absent\npost:present\n")
+}
+
+func TestProcess_FailedPreRestoreSkipsFiles(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	setupHookTest(t)
+
+	settings := filepath.Join(homeDir, ".settings")
+	marker := filepath.Join(t.TempDir(), "post-ran")
+	createDummyFile(t, settings, "backed up")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), fmt.Sprintf(`[application]
+name = App
+[pre_restore_commands]
+exit 1
+[post_restore_commands]
+touch %q
+[configuration_files]
+.settings
+`, marker))
+
+	ProcessConfiguration(configDir, backupDir, nil, true, true, 1, false, "")
+	createDummyFile(t, settings, "local")
+
+	ProcessConfiguration(configDir, backupDir, nil, false, true, 1, false, "")
+	assertFileContent(t, settings, "local")
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("Post-restore command should not run after a failed pre-restore phase, stat err = %v", err)
+	}
+}
+
+func TestProcess_CommandOutputRoundTrip(t *testing.T) {
+	for _, password := range []string{"", "output-password"} {
+		configDir, backupDir, _ := setupLayoutTest(t)
+		setupHookTest(t)
+
+		restored := filepath.Join(t.TempDir(), "restored")
+		createDummyFile(t, filepath.Join(configDir, "app.cfg"), fmt.Sprintf(`[application]
+name = App
+[command_outputs]
+extensions = printf 'go\nrust\n'
+[command_inputs]
+extensions = cat > %q
+`, restored))
+
+		// Without -allow-commands nothing runs
+		ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, password)
+		if versions, _ := ListVersions(backupDir); len(versions) != 0 {
+			t.Errorf("Expected no version without -allow-commands, got %v", versions)
+		}
+
+		ProcessConfiguration(configDir, backupDir, nil, true, true, 1, false, password)
+		versionPath, _, err := GetLatestVersionPath(backupDir)
+		if err != nil {
+			t.Fatalf("GetLatestVersionPath failed: %v", err)
+		}
+		entries := readTestManifest(t, versionPath, false).Entries()
+		if len(entries) != 1 || entries[0].Command != "printf 'go\\nrust\\n'" || entries[0].Encrypted != (password != "") {
+			t.Fatalf("Unexpected manifest entries: %+v", entries)
+		}
+		if password == "" {
+			assertFileContent(t, filepath.Join(versionPath, "App", commandOutputPrefix, "extensions"), "go\nrust\n")
+		}
+
+		ProcessConfiguration(configDir, backupDir, nil, false, true, 1, false, password)
+		assertFileContent(t, restored, "go\nrust\n")
+	}
+}
+
+func TestProcess_HookFailurePolicies(t *testing.T) {
+	tests := []struct {
+		policy       string
+		wantStored   []string
+		wantVersions int
+	}{
+		// The failing app is backed up anyway, the run goes on
+		{policy: "continue", wantStored: []string{"A/.a", "B/.b"}, wantVersions: 1},
+		{policy: "skip-app", wantStored: []string{"B/.b"}, wantVersions: 1},
+		// Nothing after the failing app runs and no version is kept
+		{policy: "abort-run", wantVersions: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			configDir, backupDir, homeDir := setupLayoutTest(t)
+			setupHookTest(t)
+
+			createDummyFile(t, filepath.Join(homeDir, ".a"), "a")
+			createDummyFile(t, filepath.Join(homeDir, ".b"), "b")
+			createDummyFile(t, filepath.Join(configDir, "a.cfg"), fmt.Sprintf(`[application]
+name = A
+on_hook_failure = %s
+[pre_backup_commands]
+exit 1
+[configuration_files]
+.a
+`, tt.policy))
+			createDummyFile(t, filepath.Join(configDir, "b.cfg"), "[application]\nname = B\n[configuration_files]\n.b\n")
+
+			_, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, Commands: true, VersionsToKeep: 1})
+			if err == nil {
+				t.Error("Expected Process to report the failed command")
+			}
+
+			versions, _ := ListVersions(backupDir)
+			if len(versions) != tt.wantVersions {
+				t.Fatalf("Got %d versions, want %d", len(versions), tt.wantVersions)
+			}
+			if tt.wantVersions == 0 {
+				return
+			}
+			var stored []string
+			for _, app := range readTestManifest(t, versions[0].Path, false).Apps {
+				for _, entry := range app.Files {
+					stored = append(stored, entry.Stored)
+				}
+			}
+			if fmt.Sprint(stored) != fmt.Sprint(tt.wantStored) {
+				t.Errorf("Stored %v, want %v", stored, tt.wantStored)
+			}
+		})
+	}
+
+	// The global policy applies to apps without their own
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	setupHookTest(t)
+	createDummyFile(t, filepath.Join(homeDir, ".a"), "a")
+	createDummyFile(t, filepath.Join(configDir, "a.cfg"), "[application]\nname = A\n[pre_backup_commands]\nexit 1\n[configuration_files]\n.a\n")
+	if _, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, Commands: true, OnHookFailure: "continue"}); err == nil {
+		t.Error("Expected Process to report the failed command")
+	}
+	if versions, _ := ListVersions(backupDir); len(versions) != 1 {
+		t.Errorf("Expected the version to be written with the continue policy, got %v", versions)
+	}
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// An incremental backup compares every file with its entry in the manifest of
+// the previous version. Files whose size and modification time are unchanged,
+// or whose content still has the same checksum, are not copied again: they are
+// hard-linked from the previous directory version, or reference the same
+// object in deduplicated backups. Each version stays complete on its own.
+
+// loadPreviousVersion opens the newest version in the backup folder as the base
+// of an incremental backup. Backups without a usable previous version, such as
+// a first run or a zip archive, copy every file.
+func (ctx *BackupContext) loadPreviousVersion() error {
+	versions, err := ListVersions(ctx.BackupFolder)
+	if err != nil || len(versions) == 0 {
+		ctx.Logger.Logf("No previous version found, backing up every file")
+		return nil
+	}
+	latest := versions[0]
+	if latest.IsZip || latest.Name == ctx.Timestamp {
+		ctx.Logger.Logf("Previous version %s cannot be reused, backing up every file", latest.Name)
+		return nil
+	}
+
+	previous, err := openBackupVersion(latest.Path, false)
+	if err != nil {
+		return fmt.Errorf("failed to open previous version '%s': %w", latest.Path, err)
+	}
+	if previous.Manifest == nil {
+		_ = previous.Close()
+		ctx.Logger.Logf("Previous version %s has no manifest, backing up every file", latest.Name)
+		return nil
+	}
+
+	ctx.Previous = previous
+	ctx.previousEntries = make(map[string]*ManifestEntry)
+	for _, f := range previous.Files {
+		ctx.previousEntries[f.Path] = f.Entry
+	}
+	ctx.Logger.Logf("Incremental backup based on version %s", latest.Name)
+	return nil
+}
+
+// reuseUnchanged stores f by reusing its copy in the previous version when it
+// did not change. It reports whether it did; the file is copied otherwise.
+func (ctx *BackupContext) reuseUnchanged(root string, f storedFile, entry ManifestEntry) (ManifestEntry, bool) {
+	if ctx.Previous == nil || isSymlink(f.info.Mode()) {
+		return entry, false
+	}
+	previous := ctx.previousEntries[entry.Stored]
+	if previous == nil || previous.Source != f.source || previous.Mode != entry.Mode ||
+		previous.Encrypted != (ctx.Password != "") {
+		return entry, false
+	}
+	checksum, ok := ctx.unchangedChecksum(f, entry, previous)
+	if !ok {
+		return entry, false
+	}
+
+	previousDedup := ctx.Previous.Manifest.Flags.Dedup
+	switch {
+	case ctx.Dedup && previousDedup:
+		if _, err := ctx.FS.Stat(objectPath(ctx.BackupFolder, previous.SHA256)); err != nil {
+			return entry, false
+		}
+		if err := ctx.FS.MkdirAll(ctx.versionRoot(), 0755); err != nil {
+			return entry, false
+		}
+	case !ctx.Dedup && !previousDedup:
+		source := ctx.FS.Join(ctx.Previous.Path, filepath.FromSlash(previous.Stored))
+		target := ctx.FS.Join(root, filepath.FromSlash(previous.Stored))
+		if err := ctx.FS.MkdirAll(ctx.FS.Dir(target), 0755); err != nil {
+			return entry, false
+		}
+		if err := ctx.FS.Link(source, target); err != nil {
+			ctx.Logger.Debugf("Cannot hard-link %s, copying it: %v", source, err)
+			return entry, false
+		}
+	default:
+		return entry, false
+	}
+
+	entry.Stored = previous.Stored
+	entry.SHA256 = previous.SHA256
+	entry.Encrypted = previous.Encrypted
+	ctx.recordContent(entry.Stored, checksum)
+	ctx.Logger.Debugf("Unchanged since the previous version: %s", f.source)
+	ctx.unchangedFiles++
+	return entry, true
+}
+
+// unchangedChecksum reports whether the source file still has the content
+// recorded in the previous manifest, and returns the checksum of that content.
+// Matching size and modification time are enough, like for rsync; otherwise a
+// plain file is compared by checksum. An encrypted copy is only reused if it
+// decrypts with the current password.
+func (ctx *BackupContext) unchangedChecksum(f storedFile, entry ManifestEntry, previous *ManifestEntry) (string, bool) {
+	if previous.Size != entry.Size {
+		return "", false
+	}
+	sameTime := previous.ModTime.Equal(entry.ModTime)
+
+	if previous.Encrypted {
+		if !sameTime {
+			return "", false
+		}
+		plaintext, err := readStoredFile(ctx.Previous, versionFile{Name: previous.Stored, Encrypted: true}, ctx.Password)
+		if err != nil {
+			return "", false
+		}
+		return sha256Hex(plaintext), true
+	}
+	if sameTime {
+		return previous.SHA256, true
+	}
+	checksum, err := fileChecksum(f.source)
+	return checksum, err == nil && checksum == previous.SHA256
+}
+
+// fileChecksum returns the hex encoded SHA-256 checksum of a file.
+func fileChecksum(path string) (string, error) {
+	file, err := Fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
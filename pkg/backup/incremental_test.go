@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// backupTwoIncrementalVersions backs up .same, .touched and .changing twice
+// with opts. In between, .touched gets a new modification time and .changing a
+// new content. It returns the name of the second version.
+func backupTwoIncrementalVersions(t *testing.T, opts Options, homeDir string) string {
+	t.Helper()
+	createDummyFile(t, filepath.Join(homeDir, ".same"), "same")
+	createDummyFile(t, filepath.Join(homeDir, ".touched"), "touched")
+	createDummyFile(t, filepath.Join(homeDir, ".changing"), "one")
+	createDummyFile(t, filepath.Join(opts.ConfigFolder, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.same\n.touched\n.changing\n")
+
+	result, err := Process(opts)
+	if err != nil {
+		t.Fatalf("First backup failed: %v", err)
+	}
+	// Give the first version an older timestamp so the second one gets its own
+	if err := os.Rename(filepath.Join(opts.BackupFolder, result.Version), filepath.Join(opts.BackupFolder, "20200101-000000")); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(homeDir, ".touched"), later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	createDummyFile(t, filepath.Join(homeDir, ".changing"), "two")
+	if result, err = Process(opts); err != nil {
+		t.Fatalf("Second backup failed: %v", err)
+	}
+	return result.Version
+}
+
+func TestIncremental_LinksUnchangedFiles(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, VersionsToKeep: 0, Incremental: true}
+	latest := backupTwoIncrementalVersions(t, opts, homeDir)
+
+	sameFile := func(name string) bool {
+		previous, err := os.Stat(filepath.Join(backupDir, "20200101-000000", "App", name))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		current, err := os.Stat(filepath.Join(backupDir, latest, "App", name))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		return os.SameFile(previous, current)
+	}
+	if !sameFile(".same") {
+		t.Error("Expected the unchanged file to be hard-linked from the previous version")
+	}
+	if !sameFile(".touched") {
+		t.Error("Expected the file with the same content to be hard-linked from the previous version")
+	}
+	if sameFile(".changing") {
+		t.Error("Expected the changed file to be copied")
+	}
+	assertFileContent(t, filepath.Join(backupDir, latest, "App", ".changing"), "two")
+
+	report, err := VerifyVersion(filepath.Join(backupDir, latest), false, "")
+	if err != nil {
+		t.Fatalf("VerifyVersion failed: %v", err)
+	}
+	if !report.OK() || report.Checked != 3 {
+		t.Errorf("Expected the incremental version to verify 3 files, got %+v", report)
+	}
+
+	manifest, err := readVersionManifest(filepath.Join(backupDir, latest))
+	if err != nil {
+		t.Fatalf("readVersionManifest failed: %v", err)
+	}
+	if !manifest.Flags.Incremental {
+		t.Error("Expected the manifest to record the incremental flag")
+	}
+	for _, entry := range manifest.Apps[0].Files {
+		if filepath.Base(entry.Source) == ".touched" && entry.ModTime.Before(time.Now()) {
+			t.Errorf("Expected the new modification time of .touched in the manifest, got %v", entry.ModTime)
+		}
+	}
+}
+
+func TestIncremental_ReusesEncryptedObjects(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, VersionsToKeep: 0, Dedup: true, Incremental: true, Password: "secret"}
+	latest := backupTwoIncrementalVersions(t, opts, homeDir)
+
+	// .touched is encrypted again: its copy is only reused when the
+	// modification time did not change either
+	if got := countObjects(t, backupDir); got != 5 {
+		t.Errorf("Expected 5 objects, with the object of .same shared by both versions, got %d", got)
+	}
+
+	report, err := VerifyVersion(filepath.Join(backupDir, latest), false, "secret")
+	if err != nil {
+		t.Fatalf("VerifyVersion failed: %v", err)
+	}
+	if !report.OK() || report.Checked != 3 {
+		t.Errorf("Expected the incremental version to verify 3 files, got %+v", report)
+	}
+}
+
+func TestIncremental_RequiresDirectoryBackup(t *testing.T) {
+	configDir, backupDir, _ := setupLayoutTest(t)
+	if _, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, ZipBackup: true, Incremental: true}); err == nil {
+		t.Error("Expected an error for an incremental zip backup")
+	}
+}
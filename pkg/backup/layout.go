@@ -0,0 +1,55 @@
+package backup
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// absolutePathPrefix is the folder, inside an app folder, that holds files whose
+// source lives outside the home directory. The absolute path is kept below it.
+const absolutePathPrefix = "_root"
+
+// commandOutputPrefix is the folder, inside an app folder, that holds the
+// captured output of the app's [command_outputs].
+const commandOutputPrefix = "_commands"
+
+// encryptedSuffix is appended to the stored name of every encrypted file.
+const encryptedSuffix = ".encrypted"
+
+// StoredRelPath returns the slash-separated path, relative to the app folder, at
+// which a resolved source path is kept inside a backup version. Paths inside the
+// home directory keep their home-relative location so entries sharing a basename
+// never overwrite each other; other absolute paths are kept under "_root".
+func (ctx *BackupContext) StoredRelPath(sourcePath string) string {
+	cleaned := filepath.Clean(sourcePath)
+	if rel, err := filepath.Rel(filepath.Clean(ctx.HomeDir), cleaned); err == nil &&
+		rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return path.Join(absolutePathPrefix, filepath.ToSlash(strings.TrimPrefix(cleaned, string(filepath.Separator))))
+}
+
+// storedPathCandidates returns the locations, relative to the version root, where
+// a configured path may have been stored for the given app: the path-preserving
+// layout first, then the legacy layout that flattened everything to the basename.
+func (ctx *BackupContext) storedPathCandidates(appName, destPath string) []string {
+	current := path.Join(appName, ctx.StoredRelPath(destPath))
+	legacy := path.Join(appName, filepath.Base(destPath))
+	if current == legacy {
+		return []string{current}
+	}
+	return []string{current, legacy}
+}
+
+// matchStoredFiles returns the files of a version that belong to the stored
+// path: the path itself, or any file below it when a directory was backed up.
+func matchStoredFiles(files []versionFile, storedPath string) []versionFile {
+	var matches []versionFile
+	for _, f := range files {
+		if f.Path == storedPath || strings.HasPrefix(f.Path, storedPath+"/") {
+			matches = append(matches, f)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,252 @@
+package backup
+
+import (
+	"SettingsSentry/pkg/config"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupLayoutTest creates config, backup and home directories and points the
+// home directory lookup at the temporary home.
+func setupLayoutTest(t *testing.T) (configDir, backupDir, homeDir string) {
+	setupBackupTestDependencies()
+
+	tempDir := t.TempDir()
+	configDir = filepath.Join(tempDir, "configs")
+	backupDir = filepath.Join(tempDir, "backups")
+	homeDir = filepath.Join(tempDir, "home")
+	for _, dir := range []string{configDir, backupDir, homeDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+	}
+
+	originalGetHomeDir := config.GetHomeDirectory
+	config.GetHomeDirectory = func() (string, error) {
+		return homeDir, nil
+	}
+	t.Cleanup(func() { config.GetHomeDirectory = originalGetHomeDir })
+
+	return configDir, backupDir, homeDir
+}
+
+func TestStoredRelPath(t *testing.T) {
+	setupBackupTestDependencies()
+
+	ctx := &BackupContext{HomeDir: "/Users/test", FS: Fs}
+
+	tests := []struct {
+		name       string
+		sourcePath string
+		expected   string
+	}{
+		{"file in home", "/Users/test/.gitconfig", ".gitconfig"},
+		{"nested file", "/Users/test/Library/Application Support/Code/User/settings.json", "Library/Application Support/Code/User/settings.json"},
+		{"xdg file", "/Users/test/.config/Code/User/settings.json", ".config/Code/User/settings.json"},
+		{"outside home", "/etc/hosts", "_root/etc/hosts"},
+		{"sibling with home prefix", "/Users/testing/file", "_root/Users/testing/file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ctx.StoredRelPath(tt.sourcePath); got != tt.expected {
+				t.Errorf("StoredRelPath(%q) = %q, want %q", tt.sourcePath, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessConfiguration_SameBasenameRoundTrip(t *testing.T) {
+	for _, zipBackup := range []bool{false, true} {
+		t.Run(fmt.Sprintf("zip=%v", zipBackup), func(t *testing.T) {
+			configDir, backupDir, homeDir := setupLayoutTest(t)
+
+			files := map[string]string{
+				"Library/Application Support/Code/User/settings.json": "mac settings",
+				".config/Code/User/settings.json":                     "xdg settings",
+				".config/Code/User/snippets/go.json":                  "go snippets",
+			}
+			for rel, content := range files {
+				createDummyFile(t, filepath.Join(homeDir, rel), content)
+			}
+
+			createDummyFile(t, filepath.Join(configDir, "vscode.cfg"), `[application]
+name = VSCode
+[configuration_files]
+Library/Application Support/Code/User/settings.json
+.config/Code/User/settings.json
+.config/Code/User/snippets
+`)
+
+			ProcessConfiguration(configDir, backupDir, nil, true, false, 1, zipBackup, "")
+
+			versionPath, isZip, err := GetLatestVersionPath(backupDir)
+			if err != nil {
+				t.Fatalf("GetLatestVersionPath failed: %v", err)
+			}
+			if isZip != zipBackup {
+				t.Fatalf("Expected isZip=%v, got %v", zipBackup, isZip)
+			}
+			if zipBackup {
+				expected := make(map[string]string)
+				for rel, content := range files {
+					expected["VSCode/"+rel] = content
+				}
+				verifyZipContent(t, versionPath, expected)
+			} else {
+				for rel, content := range files {
+					data, err := os.ReadFile(filepath.Join(versionPath, "VSCode", rel))
+					if err != nil || string(data) != content {
+						t.Errorf("Stored %s = %q (err %v), want %q", rel, string(data), err, content)
+					}
+				}
+			}
+
+			for rel := range files {
+				if err := os.Remove(filepath.Join(homeDir, rel)); err != nil {
+					t.Fatalf("Failed to remove source file: %v", err)
+				}
+			}
+
+			ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, "")
+
+			for rel, content := range files {
+				data, err := os.ReadFile(filepath.Join(homeDir, rel))
+				if err != nil || string(data) != content {
+					t.Errorf("Restored %s = %q (err %v), want %q", rel, string(data), err, content)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessConfiguration_RestoreLegacyFlatLayout(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), `[application]
+name = LegacyApp
+[configuration_files]
+Library/Preferences/app/settings.json
+.config/app
+`)
+
+	// Versions written before the path-preserving layout kept only the basename
+	versionPath := filepath.Join(backupDir, time.Now().Format("20060102-150405"))
+	createDummyFile(t, filepath.Join(versionPath, "LegacyApp", "settings.json"), "legacy settings")
+	createDummyFile(t, filepath.Join(versionPath, "LegacyApp", "app", "nested", "state.txt"), "legacy state")
+
+	ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, "")
+
+	expected := map[string]string{
+		"Library/Preferences/app/settings.json": "legacy settings",
+		".config/app/nested/state.txt":          "legacy state",
+	}
+	for rel, content := range expected {
+		data, err := os.ReadFile(filepath.Join(homeDir, rel))
+		if err != nil || string(data) != content {
+			t.Errorf("Restored %s = %q (err %v), want %q", rel, string(data), err, content)
+		}
+	}
+}
+
+func TestProcessConfiguration_EncryptedDirectoryRoundTrip(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(homeDir, ".ssh", "config"), "Host example")
+	createDummyFile(t, filepath.Join(homeDir, ".ssh", "keys", "id"), "secret key")
+	createDummyFile(t, filepath.Join(configDir, "ssh.cfg"), `[application]
+name = SSH
+[configuration_files]
+.ssh
+`)
+
+	password := "layout-password"
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, password)
+
+	versionPath, _, err := GetLatestVersionPath(backupDir)
+	if err != nil {
+		t.Fatalf("GetLatestVersionPath failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(versionPath, "SSH", ".ssh", "keys", "id.encrypted")); err != nil {
+		t.Errorf("Expected encrypted file inside backed up directory: %v", err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(homeDir, ".ssh")); err != nil {
+		t.Fatalf("Failed to remove source directory: %v", err)
+	}
+
+	ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, password)
+
+	data, err := os.ReadFile(filepath.Join(homeDir, ".ssh", "keys", "id"))
+	if err != nil || string(data) != "secret key" {
+		t.Errorf("Restored key = %q (err %v), want %q", string(data), err, "secret key")
+	}
+}
+
+func TestRestoreDestination(t *testing.T) {
+	ctx := &BackupContext{HomeDir: "/Users/test", TargetDir: "/tmp/target"}
+
+	tests := map[string]string{
+		"/Users/test/.gitconfig":            "/tmp/target/.gitconfig",
+		"/Users/test/Library/Prefs/a.plist": "/tmp/target/Library/Prefs/a.plist",
+		"/etc/hosts":                        "/tmp/target/etc/hosts",
+		"/Users/testing/file":               "/tmp/target/Users/testing/file",
+	}
+	for path, want := range tests {
+		if got := ctx.RestoreDestination(path); got != filepath.FromSlash(want) {
+			t.Errorf("RestoreDestination(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	ctx.TargetDir = ""
+	if got := ctx.RestoreDestination("/Users/test/.gitconfig"); got != "/Users/test/.gitconfig" {
+		t.Errorf("Without a target the path should be unchanged, got %q", got)
+	}
+}
+
+func TestProcess_RestoreIntoTarget(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	outsideDir := filepath.Join(filepath.Dir(homeDir), "outside")
+	outsideFile := filepath.Join(outsideDir, "app.conf")
+	createDummyFile(t, filepath.Join(homeDir, ".gitconfig"), "home content")
+	createDummyFile(t, filepath.Join(homeDir, ".config", "git", "ignore"), "*.swp")
+	createDummyFile(t, outsideFile, "outside content")
+	createDummyFile(t, filepath.Join(configDir, "git.cfg"), fmt.Sprintf(`[application]
+name = Git
+[configuration_files]
+.gitconfig
+.config/git
+%s
+`, outsideFile))
+
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, "")
+
+	// Change the live files: restoring into a target must leave them alone
+	createDummyFile(t, filepath.Join(homeDir, ".gitconfig"), "live content")
+	createDummyFile(t, outsideFile, "live outside")
+
+	targetDir := filepath.Join(t.TempDir(), "seed")
+	Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, Target: targetDir})
+
+	expected := map[string]string{
+		filepath.Join(targetDir, ".gitconfig"):               "home content",
+		filepath.Join(targetDir, ".config", "git", "ignore"): "*.swp",
+		filepath.Join(targetDir, outsideFile):                "outside content",
+		filepath.Join(homeDir, ".gitconfig"):                 "live content",
+		outsideFile:                                          "live outside",
+	}
+	for path, want := range expected {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("Expected %s to exist: %v", path, err)
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", path, data, want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// VersionSummary describes the content of one backup version for listings.
+type VersionSummary struct {
+	Version
+	// Size is the size in bytes of the version on disk: the sum of the stored
+	// files for a directory, the archive size for a zip. For a deduplicated
+	// version it is the size of the objects it references, which other
+	// versions may share.
+	Size      int64
+	Dedup     bool
+	Apps      int
+	Files     int
+	Encrypted int
+	// Err is set when the version could not be opened; the other counts are
+	// then zero.
+	Err error
+}
+
+// EncryptionState returns "yes", "no" or "partial" depending on how many of
+// the files in the version are encrypted.
+func (s VersionSummary) EncryptionState() string {
+	switch {
+	case s.Encrypted == 0:
+		return "no"
+	case s.Encrypted == s.Files:
+		return "yes"
+	default:
+		return "partial"
+	}
+}
+
+// SummarizeVersions returns a summary of every backup version in the backup
+// folder, newest first. Versions that cannot be opened are still listed with
+// their error set.
+func SummarizeVersions(baseBackupPath string) ([]VersionSummary, error) {
+	if _, err := Fs.Stat(baseBackupPath); err != nil {
+		return nil, fmt.Errorf("backup path does not exist: %w", err)
+	}
+	versions, err := ListVersions(baseBackupPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	summaries := make([]VersionSummary, 0, len(versions))
+	for _, v := range versions {
+		summaries = append(summaries, summarizeVersion(v))
+	}
+	return summaries, nil
+}
+
+func summarizeVersion(v Version) VersionSummary {
+	summary := VersionSummary{Version: v}
+
+	version, err := openBackupVersion(v.Path, v.IsZip)
+	if err != nil {
+		summary.Err = err
+		return summary
+	}
+	defer func() { _ = version.Close() }()
+
+	summary.Dedup = version.Manifest != nil && version.Manifest.Flags.Dedup
+	apps := make(map[string]bool)
+	for _, f := range version.Files {
+		apps[f.App] = true
+		summary.Files++
+		if f.Encrypted {
+			summary.Encrypted++
+		}
+	}
+	summary.Apps = len(apps)
+
+	if v.IsZip {
+		if info, err := Fs.Stat(v.Path); err == nil {
+			summary.Size = info.Size()
+		}
+		return summary
+	}
+	for _, name := range version.reader.Files() {
+		if size, err := version.reader.Size(name); err == nil {
+			summary.Size += size
+		}
+	}
+	return summary
+}
+
+// PrintVersionList writes a table of the backup versions in the backup folder.
+func PrintVersionList(baseBackupPath string) error {
+	summaries, err := SummarizeVersions(baseBackupPath)
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		AppLogger.Outputf("No backup versions found in %s", baseBackupPath)
+		return nil
+	}
+
+	AppLogger.Outputf("Backup versions in %s:", baseBackupPath)
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tDATE\tFORMAT\tSIZE\tAPPS\tFILES\tENCRYPTED")
+	for _, s := range summaries {
+		format := "dir"
+		if s.IsZip {
+			format = "zip"
+		} else if s.Dedup {
+			format = "dedup"
+		}
+		date := s.Time.Format("2006-01-02 15:04:05")
+		if s.Err != nil {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\t-\t(unreadable: %v)\n", s.Name, date, format, s.Err)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", s.Name, date, format, formatSize(s.Size), s.Apps, s.Files, s.EncryptionState())
+	}
+	_ = w.Flush()
+	logLines(b.String())
+	return nil
+}
+
+// PrintVersionContents writes the files stored per application in a backup
+// version. When appNames is not empty only those applications are shown.
+func PrintVersionContents(versionPath string, isZip bool, appNames []string) error {
+	version, err := openBackupVersion(versionPath, isZip)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = version.Close() }()
+
+	AppLogger.Outputf("Contents of %s:", versionPath)
+	if version.Manifest != nil {
+		AppLogger.Outputf("Created %s on %s by SettingsSentry %s",
+			version.Manifest.CreatedAt.Format("2006-01-02 15:04:05"), version.Manifest.Hostname, version.Manifest.ToolVersion)
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	shown := 0
+	currentApp := ""
+	for _, f := range version.Files {
+		if !appSelected(f.App, appNames) {
+			continue
+		}
+		if f.App != currentApp {
+			if currentApp != "" {
+				_, _ = fmt.Fprintln(w)
+			}
+			_, _ = fmt.Fprintf(w, "[%s]\n", f.App)
+			currentApp = f.App
+		}
+
+		size := "-"
+		if n, err := version.reader.Size(f.Name); err == nil {
+			size = formatSize(n)
+		}
+		encrypted := ""
+		if f.Encrypted {
+			encrypted = "encrypted"
+		}
+		source := ""
+		if f.Entry != nil {
+			source = f.Entry.Source
+			if f.Entry.Command != "" {
+				source = "$ " + f.Entry.Command
+			}
+		}
+		relPath := strings.TrimPrefix(f.Path, f.App+"/")
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", filepath.FromSlash(relPath), size, encrypted, source)
+		shown++
+	}
+	_ = w.Flush()
+
+	if shown == 0 {
+		AppLogger.Outputf("No files found for the selected applications")
+		return nil
+	}
+	logLines(b.String())
+	return nil
+}
+
+// appSelected reports whether an app folder matches the -app filter. Names
+// are compared case-insensitively, like the config file filter.
+func appSelected(app string, appNames []string) bool {
+	if len(appNames) == 0 {
+		return true
+	}
+	for _, name := range appNames {
+		if strings.EqualFold(app, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// logLines writes each line of text separately so tabular output stays
+// aligned. Like the rest of an action's result it is shown whatever the log
+// level.
+func logLines(text string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		AppLogger.Outputf("%s", line)
+	}
+}
+
+// formatSize returns a human readable representation of a size in bytes.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
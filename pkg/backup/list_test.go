@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSummarizeVersions(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(homeDir, ".gitconfig"), "[user]\n\tname = test\n")
+	createDummyFile(t, filepath.Join(homeDir, ".zshrc"), "export PATH\n")
+	createDummyFile(t, filepath.Join(configDir, "git.cfg"), "[application]\nname = Git\n[configuration_files]\n.gitconfig\n")
+	createDummyFile(t, filepath.Join(configDir, "zsh.cfg"), "[application]\nname = Zsh\n[configuration_files]\n.zshrc\n")
+
+	// An older, plain directory version next to the encrypted zip created below.
+	createDummyFile(t, filepath.Join(backupDir, "20200101-090000", "Git", ".gitconfig"), "old")
+	// A corrupt archive must not prevent listing the others.
+	createDummyFile(t, filepath.Join(backupDir, "20190101-090000.zip"), "not a zip")
+
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 0, true, "list-password")
+
+	summaries, err := SummarizeVersions(backupDir)
+	if err != nil {
+		t.Fatalf("SummarizeVersions failed: %v", err)
+	}
+	if len(summaries) != 3 {
+		t.Fatalf("Expected 3 versions, got %d", len(summaries))
+	}
+
+	latest := summaries[0]
+	if !latest.IsZip || latest.Apps != 2 || latest.Files != 2 || latest.EncryptionState() != "yes" || latest.Err != nil {
+		t.Errorf("Unexpected summary for latest version: %+v", latest)
+	}
+	info, err := os.Stat(latest.Path)
+	if err != nil || latest.Size != info.Size() {
+		t.Errorf("Zip size = %d, want archive size", latest.Size)
+	}
+
+	old := summaries[1]
+	if old.Name != "20200101-090000" || old.IsZip || old.Apps != 1 || old.Files != 1 || old.Size != 3 || old.EncryptionState() != "no" {
+		t.Errorf("Unexpected summary for directory version: %+v", old)
+	}
+
+	if summaries[2].Err == nil {
+		t.Error("Expected an error for the corrupt zip version")
+	}
+
+	if err := PrintVersionList(backupDir); err != nil {
+		t.Errorf("PrintVersionList failed: %v", err)
+	}
+	if err := PrintVersionContents(latest.Path, true, []string{"git"}); err != nil {
+		t.Errorf("PrintVersionContents failed: %v", err)
+	}
+}
+
+func TestVersionSummary_EncryptionState(t *testing.T) {
+	tests := []struct {
+		files, encrypted int
+		want             string
+	}{
+		{0, 0, "no"},
+		{3, 0, "no"},
+		{3, 3, "yes"},
+		{3, 1, "partial"},
+	}
+	for _, tt := range tests {
+		s := VersionSummary{Files: tt.files, Encrypted: tt.encrypted}
+		if got := s.EncryptionState(); got != tt.want {
+			t.Errorf("EncryptionState(%d/%d) = %s, want %s", tt.encrypted, tt.files, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KiB",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 30:         "3.0 GiB",
+	}
+	for size, want := range tests {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %s, want %s", size, got, want)
+		}
+	}
+}
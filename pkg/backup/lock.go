@@ -0,0 +1,43 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFileName is the file of the backup folder that backup and prune runs
+// hold an exclusive lock on while they write to or remove from the folder.
+const lockFileName = ".settingssentry.lock"
+
+// fder is implemented by the files of file systems backed by the operating
+// system, such as *os.File.
+type fder interface {
+	Fd() uintptr
+}
+
+// lockBackupFolder takes the exclusive lock of the backup folder, failing at
+// once when another run holds it. While a run holds the lock, the .partial
+// entries of the folder can only be left by an interrupted run, so removing
+// them cannot break a concurrent backup. The returned function releases the
+// lock; the operating system also releases it when the process exits.
+func lockBackupFolder(baseBackupPath string) (func(), error) {
+	path := Fs.Join(baseBackupPath, lockFileName)
+	file, err := Fs.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file '%s': %w", path, err)
+	}
+	if f, ok := file.(fder); ok {
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			_ = file.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, fmt.Errorf("backup folder '%s' is in use by another backup or prune run", baseBackupPath)
+			}
+			return nil, fmt.Errorf("failed to lock backup folder '%s': %w", baseBackupPath, err)
+		}
+	}
+	return func() {
+		_ = file.Close()
+	}, nil
+}
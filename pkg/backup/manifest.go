@@ -0,0 +1,170 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ManifestFileName is the name of the manifest stored at the root of every
+// backup version, directory or zip.
+const ManifestFileName = "manifest.json"
+
+// manifestFormatVersion is bumped whenever the manifest layout changes in a
+// way older readers cannot handle. Deduplicated versions use
+// dedupManifestFormatVersion, since older releases cannot find their files;
+// other versions keep the first format.
+const (
+	manifestFormatVersion      = 1
+	dedupManifestFormatVersion = 2
+)
+
+// ToolVersion is recorded in every manifest. It is set by the main package.
+var ToolVersion = "dev"
+
+// Manifest describes the content of one backup version.
+type Manifest struct {
+	FormatVersion int           `json:"format_version"`
+	CreatedAt     time.Time     `json:"created_at"`
+	Hostname      string        `json:"hostname"`
+	ToolVersion   string        `json:"tool_version"`
+	Flags         ManifestFlags `json:"flags"`
+	Apps          []ManifestApp `json:"apps"`
+}
+
+// ManifestFlags records the options the backup run was started with.
+type ManifestFlags struct {
+	Zip            bool     `json:"zip"`
+	Encrypted      bool     `json:"encrypted"`
+	Commands       bool     `json:"commands"`
+	Dedup          bool     `json:"dedup,omitempty"`
+	Incremental    bool     `json:"incremental,omitempty"`
+	VersionsToKeep int      `json:"versions_to_keep"`
+	AppNames       []string `json:"app_names,omitempty"`
+}
+
+// ManifestApp lists the files stored for one application.
+type ManifestApp struct {
+	Name  string          `json:"name"`
+	Files []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes one stored file. Size, Mode and ModTime describe the
+// source file; SHA256 is the checksum of the bytes stored in the version, which
+// for encrypted entries is the ciphertext. Pattern is the configuration entry,
+// as written in the .cfg file, when the file was captured by a glob pattern.
+// Command is set instead of Source for the captured output of a command.
+// Absent is only used in pre-restore snapshots, for files that did not exist
+// before the restore; nothing is stored for them.
+type ManifestEntry struct {
+	Source    string      `json:"source"`
+	Stored    string      `json:"stored"`
+	Size      int64       `json:"size"`
+	Mode      os.FileMode `json:"mode"`
+	ModTime   time.Time   `json:"mtime"`
+	SHA256    string      `json:"sha256"`
+	Encrypted bool        `json:"encrypted"`
+	Pattern   string      `json:"pattern,omitempty"`
+	Command   string      `json:"command,omitempty"`
+	Absent    bool        `json:"absent,omitempty"`
+}
+
+// newManifest creates an empty manifest for the backup run described by ctx.
+func newManifest(ctx *BackupContext) *Manifest {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	createdAt, err := time.ParseInLocation("20060102-150405", ctx.Timestamp, time.Local)
+	if err != nil {
+		createdAt = time.Now()
+	}
+
+	formatVersion := manifestFormatVersion
+	if ctx.Dedup {
+		formatVersion = dedupManifestFormatVersion
+	}
+
+	return &Manifest{
+		FormatVersion: formatVersion,
+		CreatedAt:     createdAt,
+		Hostname:      hostname,
+		ToolVersion:   ToolVersion,
+		Flags: ManifestFlags{
+			Zip:            ctx.ZipBackup,
+			Encrypted:      ctx.Password != "",
+			Commands:       ctx.Commands,
+			Dedup:          ctx.Dedup,
+			Incremental:    ctx.Incremental,
+			VersionsToKeep: ctx.VersionsToKeep,
+			AppNames:       ctx.AppNames,
+		},
+		Apps: []ManifestApp{},
+	}
+}
+
+// AddEntry records a stored file for the given application.
+func (m *Manifest) AddEntry(appName string, entry ManifestEntry) {
+	for i := range m.Apps {
+		if m.Apps[i].Name == appName {
+			m.Apps[i].Files = append(m.Apps[i].Files, entry)
+			return
+		}
+	}
+	m.Apps = append(m.Apps, ManifestApp{Name: appName, Files: []ManifestEntry{entry}})
+}
+
+// App returns the manifest section of an application, or nil if the version
+// holds no files for it.
+func (m *Manifest) App(appName string) *ManifestApp {
+	for i := range m.Apps {
+		if m.Apps[i].Name == appName {
+			return &m.Apps[i]
+		}
+	}
+	return nil
+}
+
+// Entries returns every file entry in the manifest, across all applications.
+func (m *Manifest) Entries() []ManifestEntry {
+	var entries []ManifestEntry
+	for _, app := range m.Apps {
+		entries = append(entries, app.Files...)
+	}
+	return entries
+}
+
+// writeManifest stores the manifest at the root of a version directory.
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	target := Fs.Join(dir, ManifestFileName)
+	if err := Fs.WriteFile(target, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest '%s': %w", target, err)
+	}
+	return nil
+}
+
+// readManifest decodes a manifest from r.
+func readManifest(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if m.FormatVersion > dedupManifestFormatVersion {
+		return nil, fmt.Errorf("manifest format version %d is newer than supported version %d", m.FormatVersion, dedupManifestFormatVersion)
+	}
+	return &m, nil
+}
+
+// sha256Hex returns the hex encoded SHA-256 checksum of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
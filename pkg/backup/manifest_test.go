@@ -0,0 +1,181 @@
+package backup
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readTestManifest(t *testing.T, versionPath string, isZip bool) *Manifest {
+	t.Helper()
+	version, err := openBackupVersion(versionPath, isZip)
+	if err != nil {
+		t.Fatalf("openBackupVersion failed: %v", err)
+	}
+	defer func() { _ = version.Close() }()
+	if version.Manifest == nil {
+		t.Fatalf("Expected a manifest in %s", versionPath)
+	}
+	return version.Manifest
+}
+
+func TestProcessConfiguration_WritesManifest(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(homeDir, ".gitconfig"), "[user]\n\tname = test\n")
+	createDummyFile(t, filepath.Join(homeDir, ".config", "git", "ignore"), "*.swp\n")
+	createDummyFile(t, filepath.Join(configDir, "git.cfg"), `[application]
+name = Git
+[configuration_files]
+.gitconfig
+.config/git
+`)
+
+	ToolVersion = "9.9.9"
+	defer func() { ToolVersion = "dev" }()
+
+	ProcessConfiguration(configDir, backupDir, []string{"git"}, true, false, 3, false, "")
+
+	versionPath, _, err := GetLatestVersionPath(backupDir)
+	if err != nil {
+		t.Fatalf("GetLatestVersionPath failed: %v", err)
+	}
+	m := readTestManifest(t, versionPath, false)
+
+	if m.ToolVersion != "9.9.9" {
+		t.Errorf("ToolVersion = %q, want 9.9.9", m.ToolVersion)
+	}
+	if m.Hostname == "" {
+		t.Error("Hostname should be recorded")
+	}
+	if m.Flags.VersionsToKeep != 3 || m.Flags.Zip || m.Flags.Encrypted {
+		t.Errorf("Unexpected flags: %+v", m.Flags)
+	}
+	if len(m.Flags.AppNames) != 1 || m.Flags.AppNames[0] != "git" {
+		t.Errorf("AppNames = %v, want [git]", m.Flags.AppNames)
+	}
+
+	app := m.App("Git")
+	if app == nil || len(app.Files) != 2 {
+		t.Fatalf("Expected 2 files for Git, got %+v", app)
+	}
+	for _, entry := range app.Files {
+		data, err := os.ReadFile(filepath.Join(versionPath, filepath.FromSlash(entry.Stored)))
+		if err != nil {
+			t.Fatalf("Stored file %s missing: %v", entry.Stored, err)
+		}
+		sum := sha256.Sum256(data)
+		if entry.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("Checksum mismatch for %s", entry.Stored)
+		}
+		if entry.Size != int64(len(data)) {
+			t.Errorf("Size = %d, want %d for %s", entry.Size, len(data), entry.Stored)
+		}
+		if entry.Mode.Perm() != 0644 {
+			t.Errorf("Mode = %v, want 0644 for %s", entry.Mode, entry.Stored)
+		}
+		if entry.ModTime.IsZero() || entry.Encrypted {
+			t.Errorf("Unexpected entry metadata: %+v", entry)
+		}
+		if !strings.HasPrefix(entry.Source, homeDir) {
+			t.Errorf("Source %s should be the absolute source path", entry.Source)
+		}
+	}
+}
+
+func TestProcessConfiguration_ZipManifestEncrypted(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(homeDir, ".secret"), "top secret")
+	createDummyFile(t, filepath.Join(configDir, "secret.cfg"), `[application]
+name = Secret
+[configuration_files]
+.secret
+`)
+
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, true, "manifest-password")
+
+	versionPath, isZip, err := GetLatestVersionPath(backupDir)
+	if err != nil || !isZip {
+		t.Fatalf("Expected a zip version, got %s (zip=%v, err=%v)", versionPath, isZip, err)
+	}
+	m := readTestManifest(t, versionPath, true)
+
+	if !m.Flags.Zip || !m.Flags.Encrypted {
+		t.Errorf("Unexpected flags: %+v", m.Flags)
+	}
+	entries := m.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if !entry.Encrypted || entry.Stored != "Secret/.secret.encrypted" || entry.Size != int64(len("top secret")) {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	r, err := zip.OpenReader(versionPath)
+	if err != nil {
+		t.Fatalf("Failed to open zip: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	for _, f := range r.File {
+		if f.Name != entry.Stored {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open entry: %v", err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read entry: %v", err)
+		}
+		if entry.SHA256 != sha256Hex(data) {
+			t.Errorf("Checksum of ciphertext does not match manifest")
+		}
+	}
+}
+
+func TestRestore_ConsultsManifest(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(configDir, "tool.cfg"), `[application]
+name = Tool
+[configuration_files]
+.tool/state.encrypted
+`)
+
+	// A plain file whose name merely ends in .encrypted must be restored as-is
+	versionPath := filepath.Join(backupDir, time.Now().Format("20060102-150405"))
+	createDummyFile(t, filepath.Join(versionPath, "Tool", ".tool", "state.encrypted"), "plain state")
+	m := &Manifest{FormatVersion: manifestFormatVersion}
+	m.AddEntry("Tool", ManifestEntry{
+		Source: filepath.Join(homeDir, ".tool", "state.encrypted"),
+		Stored: "Tool/.tool/state.encrypted",
+		SHA256: sha256Hex([]byte("plain state")),
+	})
+	if err := writeManifest(versionPath, m); err != nil {
+		t.Fatalf("writeManifest failed: %v", err)
+	}
+
+	ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, "")
+
+	data, err := os.ReadFile(filepath.Join(homeDir, ".tool", "state.encrypted"))
+	if err != nil || string(data) != "plain state" {
+		t.Errorf("Restored content = %q (err %v), want %q", string(data), err, "plain state")
+	}
+}
+
+func TestReadManifest_RejectsNewerFormat(t *testing.T) {
+	_, err := readManifest(strings.NewReader(`{"format_version": 99}`))
+	if err == nil {
+		t.Error("Expected error for unsupported manifest format version")
+	}
+}
@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Symbolic links inside backed up directories are stored as links rather than
+// followed: their content in a version is the link target, like in zip
+// archives. Configured paths that are links are followed instead, since what
+// they point to is the configuration. Directory versions hold an
+// actual link, zip versions a symlink entry and encrypted versions the
+// encrypted target. The manifest records the link with os.ModeSymlink in its
+// mode, which restore uses to recreate it.
+
+func isSymlink(mode os.FileMode) bool {
+	return mode&os.ModeSymlink != 0
+}
+
+// preserveMetadata gives path the permission bits and modification time of the
+// file it was copied from.
+func preserveMetadata(path string, mode os.FileMode, modTime time.Time) error {
+	if err := Fs.Chmod(path, mode.Perm()); err != nil {
+		return fmt.Errorf("failed to set mode of '%s': %w", path, err)
+	}
+	if modTime.IsZero() {
+		return nil
+	}
+	if err := Fs.Chtimes(path, modTime, modTime); err != nil {
+		return fmt.Errorf("failed to set modification time of '%s': %w", path, err)
+	}
+	return nil
+}
+
+// storeSymlink stores the link at source as a link at target, and returns the
+// link target.
+func storeSymlink(source, target string) (string, error) {
+	linkTarget, err := Fs.Readlink(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read symlink '%s': %w", source, err)
+	}
+	if err := Fs.MkdirAll(Fs.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create target directory '%s': %w", Fs.Dir(target), err)
+	}
+	if err := Fs.Symlink(linkTarget, target); err != nil {
+		return "", fmt.Errorf("failed to store symlink '%s': %w", target, err)
+	}
+	return linkTarget, nil
+}
+
+// prepareOverwrite makes path ready to be overwritten by a copy with the
+// permissions perm. An existing symlink to a file is kept and written through,
+// as configured paths that are links were followed on backup; a dangling link
+// is removed. An existing file gets perm before anything is written, so new
+// content is never readable by more users than intended, and is writable for
+// its owner until the exact mode is applied afterwards.
+func prepareOverwrite(path string, perm os.FileMode) error {
+	info, err := Fs.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error accessing %s: %w", path, err)
+	}
+	if isSymlink(info.Mode()) {
+		if info, err = Fs.Stat(path); err != nil {
+			return Fs.RemoveAll(path)
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		return fmt.Errorf("cannot overwrite directory '%s' with a file", path)
+	case info.Mode().Perm() != perm|0200:
+		return Fs.Chmod(path, perm|0200)
+	}
+	return nil
+}
+
+// restoreSymlink recreates a stored symlink at path, replacing the file or
+// link that is there.
+func restoreSymlink(path, linkTarget string) error {
+	info, err := Fs.Lstat(path)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("cannot restore symlink over directory '%s'", path)
+		}
+		if err := Fs.RemoveAll(path); err != nil {
+			return fmt.Errorf("error removing %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error accessing %s: %w", path, err)
+	}
+	if err := Fs.Symlink(linkTarget, path); err != nil {
+		return fmt.Errorf("error creating symlink %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeRestoredData writes the content of a stored file to path with the
+// metadata recorded in entry: a symlink is recreated, a file gets its mode and
+// modification time back. Without an entry, as in versions from older
+// releases, the file is written with default permissions.
+func writeRestoredData(path string, data []byte, entry *ManifestEntry) error {
+	if entry != nil && isSymlink(entry.Mode) {
+		return restoreSymlink(path, string(data))
+	}
+	perm := os.FileMode(0644)
+	if entry != nil {
+		perm = entry.Mode.Perm()
+	}
+	if err := prepareOverwrite(path, perm); err != nil {
+		return err
+	}
+	if err := Fs.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if entry == nil {
+		return nil
+	}
+	return preserveMetadata(path, entry.Mode, entry.ModTime)
+}
+
+// readLiveFile returns the content of a file on disk as it would be stored:
+// the link target when the stored file is a symlink, and the content of the
+// file a link points to otherwise.
+func readLiveFile(path string, link bool) ([]byte, error) {
+	if info, err := Fs.Lstat(path); err == nil && link && isSymlink(info.Mode()) {
+		linkTarget, err := Fs.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(linkTarget), nil
+	}
+	return Fs.ReadFile(path)
+}
+
+// openStoredLink returns a reader over the target of a symlink stored in a
+// directory version.
+func openStoredLink(path string) (io.ReadCloser, error) {
+	linkTarget, err := Fs.Readlink(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(linkTarget)), nil
+}
@@ -0,0 +1,220 @@
+package backup
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupMetadataTest creates an SSH config with restrictive permissions and an
+// old modification time, and a symlink next to it.
+func setupMetadataTest(t *testing.T) (configDir, backupDir, homeDir string, modTime time.Time) {
+	t.Helper()
+	configDir, backupDir, homeDir = setupLayoutTest(t)
+
+	sshConfig := filepath.Join(homeDir, ".ssh", "config")
+	createDummyFile(t, sshConfig, "Host *\n")
+	if err := os.Chmod(sshConfig, 0600); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	modTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(sshConfig, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if err := os.Symlink("config", filepath.Join(homeDir, ".ssh", "config.link")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+
+	createDummyFile(t, filepath.Join(configDir, "ssh.cfg"), "[application]\nname = SSH\n[configuration_files]\n.ssh\n")
+	return configDir, backupDir, homeDir, modTime
+}
+
+// assertRestoredMetadata removes the live files, restores them and checks
+// their mode, modification time and symlink.
+func assertRestoredMetadata(t *testing.T, opts Options, homeDir string, modTime time.Time) {
+	t.Helper()
+	sshConfig := filepath.Join(homeDir, ".ssh", "config")
+	link := filepath.Join(homeDir, ".ssh", "config.link")
+
+	// A loosened mode is tightened again, and the link replaces a plain file
+	if err := os.Chmod(sshConfig, 0644); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	if err := os.Remove(link); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	createDummyFile(t, link, "not a link")
+
+	opts.IsBackup = false
+	if _, err := Process(opts); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	info, err := os.Stat(sshConfig)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Restored mode = %v, want 0600", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("Restored mtime = %v, want %v", info.ModTime(), modTime)
+	}
+
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Fatalf("Expected %s to be restored as a symlink: %v", link, err)
+	}
+	if target != "config" {
+		t.Errorf("Restored symlink target = %q, want 'config'", target)
+	}
+}
+
+func TestMetadata_DirectoryBackup(t *testing.T) {
+	configDir, backupDir, homeDir, modTime := setupMetadataTest(t)
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, VersionsToKeep: 1}
+
+	result, err := Process(opts)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	storedDir := filepath.Join(backupDir, result.Version, "SSH", ".ssh")
+	info, err := os.Stat(filepath.Join(storedDir, "config"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 || !info.ModTime().Equal(modTime) {
+		t.Errorf("Stored copy has mode %v and mtime %v, want 0600 and %v", info.Mode().Perm(), info.ModTime(), modTime)
+	}
+	if target, err := os.Readlink(filepath.Join(storedDir, "config.link")); err != nil || target != "config" {
+		t.Errorf("Expected the symlink to be stored as a link to 'config', got %q (%v)", target, err)
+	}
+
+	report, err := VerifyVersion(filepath.Join(backupDir, result.Version), false, "")
+	if err != nil {
+		t.Fatalf("VerifyVersion failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected the version to verify, got %+v", report)
+	}
+
+	assertRestoredMetadata(t, opts, homeDir, modTime)
+}
+
+func TestMetadata_ZipBackup(t *testing.T) {
+	configDir, backupDir, homeDir, modTime := setupMetadataTest(t)
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, VersionsToKeep: 1, ZipBackup: true}
+
+	result, err := Process(opts)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	reader, err := zip.OpenReader(filepath.Join(backupDir, result.Version+".zip"))
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer reader.Close()
+	headers := make(map[string]*zip.File)
+	for _, f := range reader.File {
+		headers[f.Name] = f
+	}
+	if f := headers["SSH/.ssh/config"]; f == nil || f.Mode().Perm() != 0600 || !f.Modified.Equal(modTime) {
+		t.Errorf("Unexpected zip header for the SSH config: %+v", f)
+	}
+	if f := headers["SSH/.ssh/config.link"]; f == nil || f.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected a symlink zip entry for config.link, got %+v", f)
+	}
+
+	assertRestoredMetadata(t, opts, homeDir, modTime)
+}
+
+func TestMetadata_EncryptedBackup(t *testing.T) {
+	configDir, backupDir, homeDir, modTime := setupMetadataTest(t)
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, VersionsToKeep: 1, Password: "secret"}
+
+	if _, err := Process(opts); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	assertRestoredMetadata(t, opts, homeDir, modTime)
+}
+
+func TestMetadata_ConfiguredSymlinkIsFollowed(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	zshrc := filepath.Join(homeDir, "dotfiles", "zshrc")
+	createDummyFile(t, zshrc, "export A=1\n")
+	createDummyFile(t, filepath.Join(homeDir, "dotfiles", "nvim", "init.vim"), "set number\n")
+	if err := os.Symlink(filepath.Join("dotfiles", "zshrc"), filepath.Join(homeDir, ".zshrc")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(homeDir, ".config"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", "dotfiles", "nvim"), filepath.Join(homeDir, ".config", "nvim")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	createDummyFile(t, filepath.Join(configDir, "shell.cfg"), "[application]\nname = Shell\n[configuration_files]\n.zshrc\n.config/nvim\n")
+
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, VersionsToKeep: 1}
+	result, err := Process(opts)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	storedDir := filepath.Join(backupDir, result.Version, "Shell")
+	for _, stored := range []string{".zshrc", filepath.Join(".config", "nvim", "init.vim")} {
+		info, err := os.Lstat(filepath.Join(storedDir, stored))
+		if err != nil || !info.Mode().IsRegular() {
+			t.Errorf("Expected %s to be stored with its content, got %v (%v)", stored, info, err)
+		}
+	}
+	assertFileContent(t, filepath.Join(storedDir, ".zshrc"), "export A=1\n")
+
+	// Restore writes through the link, which keeps pointing into dotfiles
+	createDummyFile(t, zshrc, "local edits\n")
+	opts.IsBackup = false
+	if _, err := Process(opts); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(homeDir, ".zshrc")); err != nil || target != filepath.Join("dotfiles", "zshrc") {
+		t.Errorf("Expected .zshrc to stay a link to dotfiles/zshrc, got %q (%v)", target, err)
+	}
+	assertFileContent(t, zshrc, "export A=1\n")
+
+	if err := Undo(Options{ConfigFolder: configDir, BackupFolder: backupDir}); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	assertFileContent(t, zshrc, "local edits\n")
+}
+
+func TestPrepareOverwrite_AppliesPermissionsBeforeWriting(t *testing.T) {
+	setupBackupTestDependencies()
+	path := filepath.Join(t.TempDir(), "config")
+	createDummyFile(t, path, "old")
+
+	if err := prepareOverwrite(path, 0400); err != nil {
+		t.Fatalf("prepareOverwrite failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Mode before writing = %v, want 0600", info.Mode().Perm())
+	}
+
+	copied := filepath.Join(t.TempDir(), "copy")
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	if _, err := copyFileWithChecksum(path, copied); err != nil {
+		t.Fatalf("copyFileWithChecksum failed: %v", err)
+	}
+	if info, err := os.Stat(copied); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Copy has mode %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+}
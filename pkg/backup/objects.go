@@ -0,0 +1,210 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// objectsDirName is the object store of deduplicated backups, next to the
+// versions in the backup folder. Each stored content is kept once, named by
+// its SHA-256 checksum below a folder with the first two hex digits, and a
+// deduplicated version is a directory holding only its manifest.
+const objectsDirName = "objects"
+
+// objectPath returns the path of the object with the given checksum in the
+// object store of a backup folder.
+func objectPath(backupFolder, checksum string) string {
+	return Fs.Join(backupFolder, objectsDirName, checksum[:2], checksum)
+}
+
+// writeObject stores data in the object store unless an object with the same
+// content already exists, and completes entry with its checksum. The manifest
+// of the version is created in the version directory, so that directory is
+// created here as well.
+//
+// Like versions, an object is written with the partial suffix, synced and then
+// renamed, so an object that exists is always complete. The directories it is
+// added to are synced by commitVersion, before the version referencing it.
+func (ctx *BackupContext) writeObject(entry ManifestEntry, data []byte) (ManifestEntry, error) {
+	entry.SHA256 = sha256Hex(data)
+	target := objectPath(ctx.BackupFolder, entry.SHA256)
+
+	// An object cut short by a run from before objects were renamed into
+	// place is written again
+	if info, err := ctx.FS.Stat(target); err == nil && info.Size() == int64(len(data)) {
+		return entry, ctx.FS.MkdirAll(ctx.versionRoot(), 0755)
+	}
+
+	dir := ctx.FS.Dir(target)
+	if err := ctx.FS.MkdirAll(dir, 0755); err != nil {
+		return entry, fmt.Errorf("failed to create object directory '%s': %w", dir, err)
+	}
+	partial := target + partialSuffix
+	if err := ctx.writeSynced(partial, data, 0600); err != nil {
+		_ = ctx.FS.RemoveAll(partial)
+		return entry, fmt.Errorf("error writing object %s: %w", target, err)
+	}
+	if err := ctx.FS.Rename(partial, target); err != nil {
+		_ = ctx.FS.RemoveAll(partial)
+		return entry, fmt.Errorf("error writing object %s: %w", target, err)
+	}
+	if ctx.objectDirs == nil {
+		ctx.objectDirs = make(map[string]bool)
+	}
+	ctx.objectDirs[dir] = true
+	return entry, ctx.FS.MkdirAll(ctx.versionRoot(), 0755)
+}
+
+// objectVersionReader reads a deduplicated version: the files listed in its
+// manifest are read from the object store.
+type objectVersionReader struct {
+	root         string
+	backupFolder string
+	files        []string
+	objects      map[string]string
+}
+
+func newObjectVersionReader(root string, manifest *Manifest) *objectVersionReader {
+	r := &objectVersionReader{
+		root:         root,
+		backupFolder: Fs.Dir(root),
+		files:        []string{ManifestFileName},
+		objects:      make(map[string]string),
+	}
+	for _, entry := range manifest.Entries() {
+		if entry.Absent {
+			continue
+		}
+		r.files = append(r.files, entry.Stored)
+		r.objects[entry.Stored] = entry.SHA256
+	}
+	sort.Strings(r.files)
+	return r
+}
+
+func (r *objectVersionReader) Files() []string {
+	return r.files
+}
+
+func (r *objectVersionReader) path(name string) (string, error) {
+	if name == ManifestFileName {
+		return Fs.Join(r.root, name), nil
+	}
+	checksum, ok := r.objects[name]
+	if !ok || len(checksum) < 2 {
+		return "", fmt.Errorf("no object recorded for '%s'", name)
+	}
+	return objectPath(r.backupFolder, checksum), nil
+}
+
+func (r *objectVersionReader) Open(name string) (io.ReadCloser, error) {
+	objectFile, err := r.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return Fs.Open(objectFile)
+}
+
+func (r *objectVersionReader) Size(name string) (int64, error) {
+	objectFile, err := r.path(name)
+	if err != nil {
+		return 0, err
+	}
+	info, err := Fs.Stat(objectFile)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (r *objectVersionReader) Close() error {
+	return nil
+}
+
+// collectGarbage removes the objects no longer referenced by any deduplicated
+// version left in the backup folder. Nothing is removed when a version cannot
+// be read, since its objects would be lost.
+func collectGarbage(baseBackupPath string) error {
+	objectsDir := Fs.Join(baseBackupPath, objectsDirName)
+	if _, err := Fs.Stat(objectsDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	versions, err := ListVersions(baseBackupPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	references := make(map[string]int)
+	for _, v := range versions {
+		if v.IsZip {
+			continue
+		}
+		manifest, err := readVersionManifest(v.Path)
+		if err != nil {
+			return fmt.Errorf("not collecting unused objects, version %s is unreadable: %w", v.Name, err)
+		}
+		if manifest == nil || !manifest.Flags.Dedup {
+			continue
+		}
+		for _, entry := range manifest.Entries() {
+			references[entry.SHA256]++
+		}
+	}
+
+	prefixes, err := Fs.ReadDir(objectsDir)
+	if err != nil {
+		return fmt.Errorf("failed to read object store: %w", err)
+	}
+	removed := 0
+	for _, prefix := range prefixes {
+		if !prefix.IsDir() {
+			continue
+		}
+		prefixDir := Fs.Join(objectsDir, prefix.Name())
+		objects, err := Fs.ReadDir(prefixDir)
+		if err != nil {
+			return fmt.Errorf("failed to read object store: %w", err)
+		}
+		for _, object := range objects {
+			// Hidden files, such as iCloud placeholders, are not objects
+			if references[object.Name()] > 0 || strings.HasPrefix(object.Name(), ".") {
+				continue
+			}
+			removed++
+			if DryRun {
+				continue
+			}
+			if err := Fs.RemoveAll(Fs.Join(prefixDir, object.Name())); err != nil {
+				AppLogger.Errorf("failed to remove unused object %s: %v", object.Name(), err)
+			}
+		}
+	}
+
+	if removed == 0 {
+		return nil
+	}
+	if DryRun {
+		AppLogger.Logf("Would remove %d unused object(s) from %s", removed, objectsDir)
+	} else {
+		AppLogger.Logf("Removed %d unused object(s) from %s", removed, objectsDir)
+	}
+	return nil
+}
+
+// readVersionManifest reads the manifest of a directory version, or returns
+// nil when it has none.
+func readVersionManifest(versionPath string) (*Manifest, error) {
+	file, err := Fs.Open(Fs.Join(versionPath, ManifestFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return readManifest(file)
+}
@@ -0,0 +1,136 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// backupTwoDedupVersions backs up .same and .changing twice, changing the
+// content of .changing in between, and returns the name of the second version.
+func backupTwoDedupVersions(t *testing.T, configDir, backupDir, homeDir string, versionsToKeep int) string {
+	t.Helper()
+	createDummyFile(t, filepath.Join(homeDir, ".same"), "same")
+	createDummyFile(t, filepath.Join(homeDir, ".changing"), "one")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.same\n.changing\n")
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, VersionsToKeep: versionsToKeep, Dedup: true}
+
+	result, err := Process(opts)
+	if err != nil {
+		t.Fatalf("First backup failed: %v", err)
+	}
+	// Give the first version an older timestamp so the second one gets its own
+	if err := os.Rename(filepath.Join(backupDir, result.Version), filepath.Join(backupDir, "20200101-000000")); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	createDummyFile(t, filepath.Join(homeDir, ".changing"), "two")
+	if result, err = Process(opts); err != nil {
+		t.Fatalf("Second backup failed: %v", err)
+	}
+	return result.Version
+}
+
+func countObjects(t *testing.T, backupDir string) int {
+	t.Helper()
+	count := 0
+	err := filepath.Walk(filepath.Join(backupDir, objectsDirName), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Walking the object store failed: %v", err)
+	}
+	return count
+}
+
+func TestDedup_SharesObjectsAcrossVersions(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	latest := backupTwoDedupVersions(t, configDir, backupDir, homeDir, 0)
+
+	if got := countObjects(t, backupDir); got != 3 {
+		t.Errorf("Expected 3 objects for 4 stored files with 3 distinct contents, got %d", got)
+	}
+	for _, version := range []string{"20200101-000000", latest} {
+		entries, err := os.ReadDir(filepath.Join(backupDir, version))
+		if err != nil {
+			t.Fatalf("ReadDir failed: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Name() != ManifestFileName {
+			t.Errorf("Expected version %s to hold only its manifest, got %v", version, entries)
+		}
+
+		report, err := VerifyVersion(filepath.Join(backupDir, version), false, "")
+		if err != nil {
+			t.Fatalf("VerifyVersion failed: %v", err)
+		}
+		if !report.OK() || report.Checked != 2 {
+			t.Errorf("Expected version %s to verify 2 files, got %+v", version, report)
+		}
+	}
+
+	summaries, err := SummarizeVersions(backupDir)
+	if err != nil {
+		t.Fatalf("SummarizeVersions failed: %v", err)
+	}
+	if len(summaries) != 2 || !summaries[0].Dedup || summaries[0].Files != 2 {
+		t.Errorf("Unexpected version summaries: %+v", summaries)
+	}
+
+	if _, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, Version: "latest~1"}); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(homeDir, ".changing"), "one")
+	assertFileContent(t, filepath.Join(homeDir, ".same"), "same")
+}
+
+func TestDedup_CollectsUnreferencedObjects(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	backupTwoDedupVersions(t, configDir, backupDir, homeDir, 1)
+
+	if _, err := os.Stat(filepath.Join(backupDir, "20200101-000000")); !os.IsNotExist(err) {
+		t.Error("Expected the old version to be removed")
+	}
+	if got := countObjects(t, backupDir); got != 2 {
+		t.Errorf("Expected the object only referenced by the old version to be removed, %d objects left", got)
+	}
+	if _, err := os.Stat(objectPath(backupDir, sha256Hex([]byte("one")))); !os.IsNotExist(err) {
+		t.Error("Expected the object of the old content to be removed")
+	}
+	if _, err := os.Stat(objectPath(backupDir, sha256Hex([]byte("same")))); err != nil {
+		t.Errorf("Expected the shared object to be kept: %v", err)
+	}
+}
+
+func TestDedup_ReplacesIncompleteObjects(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	// Objects cut short by an interrupted run: one still being written, and
+	// one truncated in place by a run from before objects were renamed
+	same := objectPath(backupDir, sha256Hex([]byte("same")))
+	changing := objectPath(backupDir, sha256Hex([]byte("one")))
+	createDummyFile(t, same+partialSuffix, "sa")
+	createDummyFile(t, changing, "o")
+
+	backupTwoDedupVersions(t, configDir, backupDir, homeDir, 2)
+
+	assertFileContent(t, same, "same")
+	assertFileContent(t, changing, "one")
+	if _, err := os.Stat(same + partialSuffix); !os.IsNotExist(err) {
+		t.Error("Expected the incomplete object to be removed")
+	}
+	if got := countObjects(t, backupDir); got != 3 {
+		t.Errorf("Expected 3 complete objects, got %d", got)
+	}
+}
+
+func TestDedup_NotWithZip(t *testing.T) {
+	configDir, backupDir, _ := setupLayoutTest(t)
+
+	_, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, ZipBackup: true, Dedup: true})
+	if err == nil {
+		t.Error("Expected an error for a deduplicated zip backup")
+	}
+}
@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A version is written under its timestamp with the partialSuffix, synced to
+// disk, and only then renamed to its final name. A crash or a full disk thus
+// leaves a .partial entry that ListVersions ignores, never a half-written
+// version that restore could pick.
+const partialSuffix = ".partial"
+
+// commitVersion syncs the directory version being created and gives it its
+// final name.
+func (ctx *BackupContext) commitVersion() error {
+	partial := ctx.versionRoot()
+	if _, err := ctx.FS.Stat(partial); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to access version directory '%s': %w", partial, err)
+	}
+	if DryRun {
+		return nil
+	}
+
+	// Objects are synced when written; the directories holding the new ones
+	// must be too before a committed version references them
+	for dir := range ctx.objectDirs {
+		ctx.syncDir(dir)
+	}
+	if len(ctx.objectDirs) > 0 {
+		ctx.syncDir(ctx.FS.Join(ctx.BackupFolder, objectsDirName))
+	}
+	if err := ctx.syncTree(partial); err != nil {
+		return fmt.Errorf("failed to sync version '%s': %w", partial, err)
+	}
+	final := ctx.FS.Join(ctx.BackupFolder, ctx.Timestamp)
+
+	// A version created earlier in the same second is replaced. It is moved
+	// aside first, where it counts as incomplete until it is removed.
+	replaced := ""
+	if _, err := ctx.FS.Stat(final); err == nil {
+		replaced = final + ".replaced" + partialSuffix
+		if err := ctx.FS.Rename(final, replaced); err != nil {
+			return fmt.Errorf("failed to replace version '%s': %w", final, err)
+		}
+	}
+	if err := ctx.FS.Rename(partial, final); err != nil {
+		return fmt.Errorf("failed to rename '%s' to '%s': %w", partial, final, err)
+	}
+	ctx.syncDir(ctx.BackupFolder)
+	if replaced != "" {
+		if err := ctx.FS.RemoveAll(replaced); err != nil {
+			ctx.Logger.Errorf("failed to remove replaced version %s: %v", replaced, err)
+		}
+	}
+	return nil
+}
+
+// syncer is implemented by the files of file systems that can flush them to
+// disk, such as *os.File.
+type syncer interface {
+	Sync() error
+}
+
+// syncTree flushes every file below dir to disk, then the directories holding
+// them.
+func (ctx *BackupContext) syncTree(dir string) error {
+	entries, err := ctx.FS.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := ctx.FS.Join(dir, entry.Name())
+		switch {
+		case entry.IsDir():
+			if err := ctx.syncTree(path); err != nil {
+				return err
+			}
+		case entry.Type().IsRegular():
+			if err := ctx.syncFile(path); err != nil {
+				return err
+			}
+		}
+	}
+	ctx.syncDir(dir)
+	return nil
+}
+
+func (ctx *BackupContext) syncFile(path string) error {
+	file, err := ctx.FS.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if s, ok := file.(syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// writeSynced writes data to a new file at path and flushes it to disk.
+func (ctx *BackupContext) writeSynced(path string, data []byte, perm os.FileMode) error {
+	file, err := ctx.FS.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if s, ok := file.(syncer); ok && err == nil {
+		err = s.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir flushes the entries of a directory to disk. Errors are ignored:
+// some file systems cannot sync directories.
+func (ctx *BackupContext) syncDir(path string) {
+	_ = ctx.syncFile(path)
+}
+
+// removePartialVersions removes the versions that a previous run left
+// incomplete in the backup folder. Callers hold the lock of the folder, so no
+// concurrent run can still be writing them.
+func removePartialVersions(baseBackupPath string) {
+	entries, err := Fs.ReadDir(baseBackupPath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), partialSuffix) {
+			continue
+		}
+		path := Fs.Join(baseBackupPath, entry.Name())
+		if DryRun {
+			AppLogger.Logf("Would remove incomplete version: %s", path)
+			continue
+		}
+		AppLogger.Warnf("Removing incomplete version left by an interrupted backup: %s", path)
+		if err := Fs.RemoveAll(path); err != nil {
+			AppLogger.Errorf("failed to remove incomplete version %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcess_RenamesPartialVersion(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	createDummyFile(t, filepath.Join(homeDir, ".app"), "settings")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.app\n")
+
+	// An incomplete version left by an interrupted run, newer than any other
+	stale := filepath.Join(backupDir, "29990101-000000"+partialSuffix)
+	createDummyFile(t, filepath.Join(stale, "App", ".app"), "half")
+	createDummyFile(t, filepath.Join(backupDir, "29990101-000000.zip"+partialSuffix), "PK")
+
+	latest, _, err := GetLatestVersionPath(backupDir)
+	if err == nil {
+		t.Errorf("Expected incomplete versions to be ignored, got %s", latest)
+	}
+
+	for _, zipBackup := range []bool{false, true} {
+		result, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, ZipBackup: zipBackup})
+		if err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+		version := filepath.Join(backupDir, result.Version)
+		if zipBackup {
+			version += ".zip"
+		}
+		if report, err := VerifyVersion(version, zipBackup, ""); err != nil || !report.OK() {
+			t.Errorf("Expected a complete version at %s, got %+v, %v", version, report, err)
+		}
+	}
+
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == partialSuffix {
+			t.Errorf("Expected no incomplete version to be left, found %s", entry.Name())
+		}
+	}
+}
+
+func TestCreateZipArchive_RemovesPartialOnFailure(t *testing.T) {
+	tempDir := t.TempDir()
+	zipPath := filepath.Join(tempDir, "20260101-090000.zip")
+
+	if err := createZipArchive(filepath.Join(tempDir, "nonexistent"), zipPath); err == nil {
+		t.Fatal("Expected an error for a missing source directory")
+	}
+	for _, path := range []string{zipPath, zipPath + partialSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to exist", path)
+		}
+	}
+}
+
+func TestLockBackupFolder_KeepsConcurrentRunsOut(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	createDummyFile(t, filepath.Join(homeDir, ".app"), "settings")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.app\n")
+
+	// The version another run is still writing
+	inProgress := filepath.Join(backupDir, "29990101-000000"+partialSuffix)
+	createDummyFile(t, filepath.Join(inProgress, "App", ".app"), "half")
+
+	unlock, err := lockBackupFolder(backupDir)
+	if err != nil {
+		t.Fatalf("lockBackupFolder failed: %v", err)
+	}
+	if _, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true}); err == nil {
+		t.Error("Expected a backup to fail while another run holds the lock")
+	}
+	if err := Prune(backupDir, RetentionPolicy{Last: 1}); err == nil {
+		t.Error("Expected prune to fail while another run holds the lock")
+	}
+	if _, err := os.Stat(inProgress); err != nil {
+		t.Errorf("Expected the version in progress to be kept: %v", err)
+	}
+	unlock()
+
+	if _, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true}); err != nil {
+		t.Fatalf("Process failed once the lock was released: %v", err)
+	}
+	if _, err := os.Stat(inProgress); !os.IsNotExist(err) {
+		t.Error("Expected the incomplete version to be removed once the lock was released")
+	}
+	if err := Prune(backupDir, RetentionPolicy{Last: 1}); err != nil {
+		t.Errorf("Prune failed after the backup released the lock: %v", err)
+	}
+}
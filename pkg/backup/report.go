@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Outcomes of a run, as reported by RunStatus.
+const (
+	RunSucceeded = "succeeded"
+	RunPartial   = "partial"
+	RunFailed    = "failed"
+)
+
+// RunStatus classifies the outcome of Process. A run is partial when some
+// items failed but others made it; it failed when it could not start, was
+// aborted, did not write its version, or nothing succeeded.
+func RunStatus(result *Result, err error) string {
+	switch {
+	case err == nil:
+		return RunSucceeded
+	case result != nil && !result.Aborted && !result.NotWritten && result.Count(ItemSucceeded) > 0:
+		return RunPartial
+	default:
+		return RunFailed
+	}
+}
+
+// Report is the JSON document describing a backup or restore run, written by
+// -report and -output=json.
+type Report struct {
+	Action     string       `json:"action"`
+	Version    string       `json:"version,omitempty"`
+	Status     string       `json:"status"`
+	Error      string       `json:"error,omitempty"`
+	Unchanged  bool         `json:"unchanged,omitempty"`
+	Started    time.Time    `json:"started"`
+	DurationMS float64      `json:"duration_ms"`
+	Succeeded  int          `json:"succeeded"`
+	Skipped    int          `json:"skipped"`
+	Failed     int          `json:"failed"`
+	Items      []ReportItem `json:"items"`
+}
+
+// ReportItem is one file, command phase or command output of a Report.
+type ReportItem struct {
+	App         string  `json:"app"`
+	Action      string  `json:"action"`
+	Status      string  `json:"status"`
+	Source      string  `json:"source,omitempty"`
+	Destination string  `json:"destination,omitempty"`
+	Bytes       int64   `json:"bytes"`
+	DurationMS  float64 `json:"duration_ms"`
+	Reason      string  `json:"reason,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// NewReport builds the report of a run from what Process returned. The result
+// may be nil when the run could not be started.
+func NewReport(isBackup bool, result *Result, err error) Report {
+	report := Report{
+		Action: "restore",
+		Status: RunStatus(result, err),
+		Items:  []ReportItem{},
+	}
+	if isBackup {
+		report.Action = "backup"
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if result == nil {
+		return report
+	}
+
+	report.Version = result.Version
+	report.Unchanged = result.Unchanged
+	report.Started = result.Started
+	report.DurationMS = milliseconds(result.Finished.Sub(result.Started))
+	report.Succeeded = result.Count(ItemSucceeded)
+	report.Skipped = result.Count(ItemSkipped)
+	report.Failed = result.Count(ItemFailed)
+	for _, app := range result.Apps {
+		for _, item := range app.Items {
+			reportItem := ReportItem{
+				App:         app.Name,
+				Action:      item.Action,
+				Status:      item.Status.String(),
+				Source:      item.Source,
+				Destination: item.Destination,
+				Bytes:       item.Bytes,
+				DurationMS:  milliseconds(item.Duration),
+				Reason:      item.Reason,
+			}
+			if item.Err != nil {
+				reportItem.Error = item.Err.Error()
+			}
+			report.Items = append(report.Items, reportItem)
+		}
+	}
+	return report
+}
+
+// JSON returns the indented JSON encoding of the report.
+func (r Report) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunStatus(t *testing.T) {
+	succeeded := &Result{Apps: []*AppResult{{Name: "App", Items: []ItemResult{{Status: ItemSucceeded}}}}}
+	failed := &Result{Apps: []*AppResult{{Name: "App", Items: []ItemResult{{Status: ItemFailed}}}}}
+	aborted := &Result{Aborted: true, Apps: succeeded.Apps}
+	notWritten := &Result{NotWritten: true, Apps: succeeded.Apps}
+	err := errors.New("boom")
+
+	tests := []struct {
+		name   string
+		result *Result
+		err    error
+		want   string
+	}{
+		{"success", succeeded, nil, RunSucceeded},
+		{"partial", succeeded, err, RunPartial},
+		{"nothing succeeded", failed, err, RunFailed},
+		{"aborted", aborted, err, RunFailed},
+		{"version not written", notWritten, err, RunFailed},
+		{"not started", nil, err, RunFailed},
+	}
+	for _, tt := range tests {
+		if got := RunStatus(tt.result, tt.err); got != tt.want {
+			t.Errorf("%s: RunStatus = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewReport_Backup(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	good := filepath.Join(homeDir, ".good")
+	createDummyFile(t, good, "12345")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), `[application]
+name = App
+[configuration_files]
+.good
+.missing
+.good/child
+`)
+
+	result, err := ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, "")
+	data, jsonErr := NewReport(true, result, err).JSON()
+	if jsonErr != nil {
+		t.Fatalf("JSON failed: %v", jsonErr)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Report is not valid JSON: %v\n%s", err, data)
+	}
+	if report.Action != "backup" || report.Status != RunPartial || report.Version != result.Version || report.Error == "" {
+		t.Errorf("Unexpected report header: %+v", report)
+	}
+	if report.Succeeded != 1 || report.Skipped != 1 || report.Failed != 1 || len(report.Items) != 3 {
+		t.Fatalf("Unexpected report counts: %+v", report)
+	}
+
+	backedUp := report.Items[0]
+	wantDestination := filepath.Join(backupDir, result.Version, "App", ".good")
+	if backedUp.App != "App" || backedUp.Action != "backup" || backedUp.Status != "succeeded" ||
+		backedUp.Source != good || backedUp.Destination != wantDestination || backedUp.Bytes != 5 {
+		t.Errorf("Unexpected backed up item: %+v", backedUp)
+	}
+	if skipped := report.Items[1]; skipped.Status != "skipped" || skipped.Reason != "does not exist" {
+		t.Errorf("Unexpected skipped item: %+v", skipped)
+	}
+	if failed := report.Items[2]; failed.Status != "failed" || failed.Error == "" {
+		t.Errorf("Unexpected failed item: %+v", failed)
+	}
+}
+
+func TestNewReport_NotStarted(t *testing.T) {
+	report := NewReport(false, nil, errors.New("config folder not found"))
+	if report.Action != "restore" || report.Status != RunFailed || report.Error != "config folder not found" || report.Items == nil {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestFinalizeBackup_FailureIsNotPartial(t *testing.T) {
+	result := &Result{IsBackup: true, Apps: []*AppResult{{Name: "App", Items: []ItemResult{{Status: ItemSucceeded}}}}}
+	ctx := &BackupContext{IsBackup: true, ZipBackup: true, BackupFolder: t.TempDir(), Logger: AppLogger, FS: Fs, Result: result}
+
+	err := ctx.FinalizeBackup()
+	if err == nil {
+		t.Fatal("Expected an error for a zip backup without staging directory")
+	}
+	if !result.NotWritten {
+		t.Error("Expected the result to record that no version was written")
+	}
+	if got := RunStatus(result, err); got != RunFailed {
+		t.Errorf("RunStatus = %q, want %q", got, RunFailed)
+	}
+}
@@ -0,0 +1,196 @@
+package backup
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ItemStatus is the outcome of one file, command phase or command output of a
+// backup or restore.
+type ItemStatus int
+
+const (
+	ItemSucceeded ItemStatus = iota
+	ItemSkipped
+	ItemFailed
+)
+
+// String returns the lower-case name of the status.
+func (s ItemStatus) String() string {
+	switch s {
+	case ItemSucceeded:
+		return "succeeded"
+	case ItemSkipped:
+		return "skipped"
+	case ItemFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("ItemStatus(%d)", int(s))
+}
+
+// ItemResult is the outcome of one item of an application: a file, a command
+// phase or a command output.
+type ItemResult struct {
+	// Action is "backup" or "restore" for files and command outputs, and the
+	// phase (e.g. "pre-backup") for commands.
+	Action string
+	// Source and Destination are where the item was copied from and to. On
+	// backup the destination is the stored path in the version, on restore
+	// the source is. Items that were not copied only have the live path.
+	Source      string
+	Destination string
+	Status      ItemStatus
+	Bytes       int64
+	Duration    time.Duration
+	// Reason tells why a skipped item was skipped.
+	Reason string
+	// Err tells why a failed item failed.
+	Err error
+}
+
+// Name returns the path that identifies the item, or its action for command
+// phases.
+func (item ItemResult) Name() string {
+	if item.Source != "" {
+		return item.Source
+	}
+	if item.Destination != "" {
+		return item.Destination
+	}
+	return item.Action
+}
+
+// AppResult holds the outcome of every item of one application.
+type AppResult struct {
+	Name  string
+	Items []ItemResult
+}
+
+// Result is the outcome of a backup or restore run, per application and item.
+type Result struct {
+	IsBackup bool
+	// Version is the backup version written or restored from.
+	Version string
+	// Aborted is set when a failed command stopped the run (abort-run).
+	Aborted bool
+	// NotWritten is set when a backup failed to write, commit or discard its
+	// new version, so none of the items it backed up can be relied on.
+	NotWritten bool
+	// Unchanged is set when a backup found nothing changed since Version and
+	// did not write a new version.
+	Unchanged bool
+	Started   time.Time
+	Finished  time.Time
+	Apps      []*AppResult
+}
+
+// Count returns the number of items with the given status.
+func (r *Result) Count(status ItemStatus) int {
+	n := 0
+	for _, app := range r.Apps {
+		for _, item := range app.Items {
+			if item.Status == status {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Failures returns the failed items, prefixed with their application name.
+func (r *Result) Failures() []string {
+	var failures []string
+	for _, app := range r.Apps {
+		for _, item := range app.Items {
+			if item.Status == ItemFailed {
+				failures = append(failures, fmt.Sprintf("%s: %s: %v", app.Name, item.Name(), item.Err))
+			}
+		}
+	}
+	return failures
+}
+
+// Summary returns a one-line count of succeeded, skipped and failed items.
+func (r *Result) Summary() string {
+	operation := "Restore"
+	if r.IsBackup {
+		operation = "Backup"
+	}
+	parts := []string{
+		fmt.Sprintf("%d succeeded", r.Count(ItemSucceeded)),
+		fmt.Sprintf("%d skipped", r.Count(ItemSkipped)),
+		fmt.Sprintf("%d failed", r.Count(ItemFailed)),
+	}
+	summary := fmt.Sprintf("%s of %d application(s): %s", operation, len(r.Apps), strings.Join(parts, ", "))
+	if r.Aborted {
+		summary += " (aborted)"
+	}
+	if r.Unchanged {
+		summary += fmt.Sprintf(" (unchanged since %s)", r.Version)
+	}
+	return summary
+}
+
+// startApp adds an application to the result of the run; the items recorded
+// until the next call belong to it.
+func (ctx *BackupContext) startApp(name string) {
+	if ctx.Result == nil {
+		return
+	}
+	ctx.currentApp = &AppResult{Name: name}
+	ctx.Result.Apps = append(ctx.Result.Apps, ctx.currentApp)
+}
+
+// record adds an item to the application being processed. Contexts that do
+// not collect a result, such as diff, ignore it.
+func (ctx *BackupContext) record(item ItemResult) {
+	if ctx.currentApp == nil {
+		return
+	}
+	if item.Action == "" {
+		item.Action = ctx.action()
+	}
+	ctx.currentApp.Items = append(ctx.currentApp.Items, item)
+}
+
+// action returns the action of the files of this run.
+func (ctx *BackupContext) action() string {
+	if ctx.IsBackup {
+		return "backup"
+	}
+	return "restore"
+}
+
+// recordCopy records a file or command output copied from source to
+// destination in the time since started.
+func (ctx *BackupContext) recordCopy(source, destination string, bytes int64, started time.Time) {
+	ctx.record(ItemResult{
+		Source:      source,
+		Destination: destination,
+		Status:      ItemSucceeded,
+		Bytes:       bytes,
+		Duration:    time.Since(started),
+	})
+}
+
+// livePath returns an item for a path on disk: the source of a backup or the
+// destination of a restore.
+func (ctx *BackupContext) livePath(path string) ItemResult {
+	if ctx.IsBackup {
+		return ItemResult{Source: path}
+	}
+	return ItemResult{Destination: path}
+}
+
+func (ctx *BackupContext) recordSkip(path, reason string) {
+	item := ctx.livePath(path)
+	item.Status, item.Reason = ItemSkipped, reason
+	ctx.record(item)
+}
+
+func (ctx *BackupContext) recordFailure(path string, err error) {
+	item := ctx.livePath(path)
+	item.Status, item.Err = ItemFailed, err
+	ctx.record(item)
+}
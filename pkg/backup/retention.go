@@ -0,0 +1,271 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// RetentionPolicy decides which backup versions are kept, in the style of a
+// grandfather-father-son rotation. A version is kept when any rule keeps it
+// and removed otherwise. The zero policy keeps every version.
+type RetentionPolicy struct {
+	// Last keeps the newest versions (-versions).
+	Last int
+	// Daily, Weekly and Monthly keep the newest version of each of the most
+	// recent days, ISO weeks and months that have a version.
+	Daily   int
+	Weekly  int
+	Monthly int
+	// Within keeps every version created less than this long ago.
+	Within time.Duration
+}
+
+// IsZero reports whether the policy has no rule, and so keeps every version.
+func (p RetentionPolicy) IsZero() bool {
+	return p == RetentionPolicy{}
+}
+
+// String describes the rules of the policy, e.g. "last 1, daily 7, within 30d".
+func (p RetentionPolicy) String() string {
+	var rules []string
+	for _, rule := range []struct {
+		name  string
+		count int
+	}{{"last", p.Last}, {"daily", p.Daily}, {"weekly", p.Weekly}, {"monthly", p.Monthly}} {
+		if rule.count > 0 {
+			rules = append(rules, fmt.Sprintf("%s %d", rule.name, rule.count))
+		}
+	}
+	if p.Within > 0 {
+		rules = append(rules, "within "+formatRetentionDuration(p.Within))
+	}
+	if len(rules) == 0 {
+		return "keep all"
+	}
+	return strings.Join(rules, ", ")
+}
+
+// ParseRetentionDuration parses the -keep-within duration: a number of days
+// ("30d") or weeks ("4w"), or a Go duration such as "36h".
+func ParseRetentionDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var d time.Duration
+	var err error
+	if n, ok := strings.CutSuffix(value, "d"); ok {
+		d, err = durationOfDays(n, 1)
+	} else if n, ok := strings.CutSuffix(value, "w"); ok {
+		d, err = durationOfDays(n, 7)
+	} else {
+		d, err = time.ParseDuration(value)
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration '%s': expected e.g. 30d, 4w or 36h", value)
+	}
+	return d, nil
+}
+
+func durationOfDays(n string, days int) (time.Duration, error) {
+	count, err := strconv.Atoi(n)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(count*days) * 24 * time.Hour, nil
+}
+
+func formatRetentionDuration(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// RetentionDecision tells whether a policy keeps a version, and why.
+type RetentionDecision struct {
+	Version
+	Keep bool
+	// Reasons lists the rules keeping the version, or why it is removed.
+	Reasons []string
+}
+
+// PlanRetention applies the policy to versions, sorted newest first as
+// returned by ListVersions, at the time now.
+func PlanRetention(versions []Version, policy RetentionPolicy, now time.Time) []RetentionDecision {
+	decisions := make([]RetentionDecision, len(versions))
+	for i, v := range versions {
+		decisions[i].Version = v
+	}
+	keep := func(i int, reason string) {
+		decisions[i].Keep = true
+		decisions[i].Reasons = append(decisions[i].Reasons, reason)
+	}
+
+	if policy.IsZero() {
+		for i := range decisions {
+			keep(i, "keep all")
+		}
+		return decisions
+	}
+
+	for i := 0; i < policy.Last && i < len(versions); i++ {
+		keep(i, fmt.Sprintf("last %d", policy.Last))
+	}
+	keepNewestPerPeriod := func(count int, name string, period func(time.Time) string) {
+		kept, previous := 0, ""
+		for i, v := range versions {
+			if kept == count {
+				return
+			}
+			if p := period(v.Time); p != previous {
+				keep(i, fmt.Sprintf("%s %s", name, p))
+				kept, previous = kept+1, p
+			}
+		}
+	}
+	keepNewestPerPeriod(policy.Daily, "daily", func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestPerPeriod(policy.Weekly, "weekly", func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	keepNewestPerPeriod(policy.Monthly, "monthly", func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+	if policy.Within > 0 {
+		cutoff := now.Add(-policy.Within)
+		for i, v := range versions {
+			if v.Time.After(cutoff) {
+				keep(i, "within "+formatRetentionDuration(policy.Within))
+			}
+		}
+	}
+
+	for i := range decisions {
+		if !decisions[i].Keep {
+			decisions[i].Reasons = []string{"not kept by " + policy.String()}
+		}
+	}
+	return decisions
+}
+
+// ApplyRetention removes the versions of the backup folder that the policy
+// does not keep, then the objects of deduplicated versions that no version
+// references anymore. In a dry run it only lists what would be removed. It
+// returns the decision made for every version.
+func ApplyRetention(baseBackupPath string, policy RetentionPolicy) ([]RetentionDecision, error) {
+	_, err := Fs.Stat(baseBackupPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, AppLogger.LogErrorf("failed to stat backup path for cleanup: %w", err)
+	}
+
+	versions, err := ListVersions(baseBackupPath)
+	if err != nil {
+		return nil, AppLogger.LogErrorf("failed to read backup directory for cleanup: %w", err)
+	}
+
+	decisions := PlanRetention(versions, policy, time.Now())
+	for _, d := range decisions {
+		if d.Keep {
+			continue
+		}
+		_, statErr := Fs.Stat(d.Path)
+		if statErr != nil {
+			if os.IsNotExist(statErr) {
+				AppLogger.Logf("Skipping version that no longer exists: %s", d.Path)
+				continue
+			}
+			AppLogger.Errorf("failed to stat old version %s: %v", d.Path, statErr)
+			continue
+		}
+		if DryRun {
+			AppLogger.Logf("Would remove old version: %s", d.Path)
+		} else {
+			AppLogger.Logf("Removing old version: %s", d.Path)
+			err := Fs.RemoveAll(d.Path)
+			if err != nil {
+				AppLogger.Errorf("failed to remove old version %s: %v", d.Path, err)
+			}
+		}
+	}
+
+	// In a dry run the old versions are still there, so objects only they
+	// reference are not reported
+	return decisions, collectGarbage(baseBackupPath)
+}
+
+// Prune applies the policy to the backup folder, independently of a backup
+// run, and writes a table of every version with whether it is kept and why.
+// Pre-restore snapshots beyond the newest snapshotsToKeep are removed too.
+func Prune(baseBackupPath string, policy RetentionPolicy) error {
+	if policy.IsZero() {
+		return fmt.Errorf("no retention rule given: use -versions, -keep-daily, -keep-weekly, -keep-monthly or -keep-within")
+	}
+	if _, err := Fs.Stat(baseBackupPath); err != nil {
+		return fmt.Errorf("backup path does not exist: %w", err)
+	}
+
+	if !DryRun {
+		unlock, err := lockBackupFolder(baseBackupPath)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	AppLogger.Outputf("Pruning %s with retention %s", baseBackupPath, policy)
+	removePartialVersions(baseBackupPath)
+	decisions, err := ApplyRetention(baseBackupPath, policy)
+	if err != nil {
+		return err
+	}
+	snapshots, err := pruneSnapshots(baseBackupPath)
+	if err != nil {
+		return err
+	}
+	if len(decisions) == 0 && len(snapshots) == 0 {
+		AppLogger.Outputf("No backup versions found in %s", baseBackupPath)
+		return nil
+	}
+
+	removeAction := "remove"
+	if DryRun {
+		removeAction = "would remove"
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tDATE\tACTION\tREASON")
+	writeRows := func(decisions []RetentionDecision) int {
+		removed := 0
+		for _, d := range decisions {
+			action := "keep"
+			if !d.Keep {
+				action = removeAction
+				removed++
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Name, d.Time.Format("2006-01-02 15:04:05"), action, strings.Join(d.Reasons, ", "))
+		}
+		return removed
+	}
+	removed := writeRows(decisions)
+	removedSnapshots := writeRows(snapshots)
+	_ = w.Flush()
+	logLines(b.String())
+
+	summary := fmt.Sprintf("%d of %d version(s)", removed, len(decisions))
+	if len(snapshots) > 0 {
+		summary += fmt.Sprintf(" and %d of %d pre-restore snapshot(s)", removedSnapshots, len(snapshots))
+	}
+	if DryRun {
+		AppLogger.Outputf("Would remove %s", summary)
+	} else {
+		AppLogger.Outputf("Removed %s", summary)
+	}
+	return nil
+}
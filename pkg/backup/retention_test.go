@@ -0,0 +1,157 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// versionsAt returns versions created at the given times, newest first.
+func versionsAt(times ...time.Time) []Version {
+	versions := make([]Version, len(times))
+	for i, t := range times {
+		versions[i] = Version{Name: t.Format(versionTimestampFormat), Time: t}
+	}
+	return versions
+}
+
+func keptNames(decisions []RetentionDecision) []string {
+	var kept []string
+	for _, d := range decisions {
+		if d.Keep {
+			kept = append(kept, d.Name)
+		}
+	}
+	return kept
+}
+
+func TestPlanRetention(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.Local)
+	day := 24 * time.Hour
+	// Two versions a day for the last 60 days, newest first
+	var times []time.Time
+	for i := 0; i < 60; i++ {
+		times = append(times, now.Add(-time.Duration(i)*day), now.Add(-time.Duration(i)*day-time.Hour))
+	}
+	versions := versionsAt(times...)
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   int
+	}{
+		{"keep all", RetentionPolicy{}, 120},
+		{"last", RetentionPolicy{Last: 3}, 3},
+		{"daily", RetentionPolicy{Daily: 7}, 7},
+		{"weekly", RetentionPolicy{Weekly: 4}, 4},
+		{"monthly", RetentionPolicy{Monthly: 12}, 3},
+		{"within", RetentionPolicy{Within: 2 * day}, 4},
+		// The newest versions of the last two weeks are also daily ones
+		{"combined", RetentionPolicy{Last: 1, Daily: 7, Weekly: 4}, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions := PlanRetention(versions, tt.policy, now)
+			if got := keptNames(decisions); len(got) != tt.want {
+				t.Errorf("Expected %d kept versions, got %d: %v", tt.want, len(got), got)
+			}
+			for _, d := range decisions {
+				if len(d.Reasons) == 0 {
+					t.Errorf("Expected a reason for version %s", d.Name)
+				}
+			}
+		})
+	}
+
+	decisions := PlanRetention(versions, RetentionPolicy{Daily: 2}, now)
+	if !decisions[0].Keep || decisions[1].Keep || !decisions[2].Keep || decisions[3].Keep {
+		t.Errorf("Expected the newest version of each day to be kept, got %v", keptNames(decisions))
+	}
+	if got := decisions[3].Reasons; len(got) != 1 || got[0] != "not kept by daily 2" {
+		t.Errorf("Unexpected reasons for a removed version: %v", got)
+	}
+}
+
+func TestParseRetentionDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"4w", 28 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"month", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRetentionDuration(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRetentionDuration(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	_, backupDir, _ := setupLayoutTest(t)
+	names := []string{"20260101-090000", "20260101-180000", "20260102-090000", "20260210-090000"}
+	for _, name := range names {
+		createDummyFile(t, filepath.Join(backupDir, name, "App", ".app"), name)
+		createDummyFile(t, filepath.Join(backupDir, snapshotPrefix+name, "App", ".app"), name)
+	}
+	policy := RetentionPolicy{Daily: 2, Monthly: 2}
+
+	DryRun = true
+	err := Prune(backupDir, policy)
+	DryRun = false
+	if err != nil {
+		t.Fatalf("Prune dry run failed: %v", err)
+	}
+	if versions, _ := ListVersions(backupDir); len(versions) != len(names) {
+		t.Fatalf("Expected the dry run to keep every version, got %d", len(versions))
+	}
+
+	if err := Prune(backupDir, policy); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	// The newest version of January is from the 2nd, so the 1st is not kept
+	// as a monthly version either
+	for _, name := range names[:2] {
+		if _, err := os.Stat(filepath.Join(backupDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected version %s to be removed", name)
+		}
+	}
+	if versions, _ := ListVersions(backupDir); len(versions) != 2 {
+		t.Errorf("Expected 2 versions to be kept, got %+v", versions)
+	}
+	if snapshots, _ := ListSnapshots(backupDir); len(snapshots) != snapshotsToKeep || snapshots[snapshotsToKeep-1].Name != snapshotPrefix+names[1] {
+		t.Errorf("Expected the %d newest snapshots to be kept, got %+v", snapshotsToKeep, snapshots)
+	}
+
+	if err := Prune(backupDir, RetentionPolicy{}); err == nil {
+		t.Error("Expected an error for a policy without rules")
+	}
+}
+
+func TestProcess_RetentionReplacesVersionsToKeep(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	createDummyFile(t, filepath.Join(homeDir, ".app"), "settings")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.app\n")
+	names := []string{"20200101-090000", "20200102-090000", "20200102-180000"}
+	for _, name := range names {
+		createDummyFile(t, filepath.Join(backupDir, name, "App", ".app"), name)
+	}
+
+	// -versions=0 -keep-daily=2: the new version and the newest of 2 January
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, VersionsToKeep: 0, Retention: RetentionPolicy{Daily: 2}}
+	result, err := Process(opts)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	versions, _ := ListVersions(backupDir)
+	if len(versions) != 2 || versions[0].Name != result.Version || versions[1].Name != names[2] {
+		t.Errorf("Expected %s and %s to be kept, got %+v", result.Version, names[2], versions)
+	}
+}
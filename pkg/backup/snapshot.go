@@ -0,0 +1,296 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotPrefix names the pre-restore snapshots kept in the backup folder. They
+// are followed by the timestamp of the restore and are never mistaken for
+// backup versions, so they are not restored from or cleaned up as such.
+const snapshotPrefix = "pre-restore-"
+
+// snapshotsToKeep is the number of pre-restore snapshots kept in the backup
+// folder. Undo reverts the newest one and then deletes it, so the last few
+// restores can still be undone one after the other.
+const snapshotsToKeep = 3
+
+// snapshotDir returns the directory of the snapshot taken by this restore.
+func (ctx *BackupContext) snapshotDir() string {
+	return ctx.FS.Join(ctx.BackupFolder, snapshotPrefix+ctx.Timestamp)
+}
+
+// snapshotTargets saves the current content of the files a restore is about to
+// overwrite into the pre-restore snapshot, so the restore can be undone. Files
+// that do not exist yet are recorded as absent, so undo removes them again.
+// The snapshot manifest is rewritten after every entry so an interrupted
+// restore still leaves a usable snapshot.
+func (ctx *BackupContext) snapshotTargets(appName string, targets []restoreTarget) error {
+	if ctx.Snapshot == nil {
+		ctx.Snapshot = newManifest(ctx)
+		ctx.snapshotted = make(map[string]bool)
+	}
+
+	added := false
+	for _, t := range targets {
+		// A file restored twice keeps the content it had before the first write
+		if ctx.snapshotted[t.path] {
+			continue
+		}
+
+		info, err := ctx.FS.Lstat(t.path)
+		if err == nil && isSymlink(info.Mode()) && (t.file.Entry == nil || !isSymlink(t.file.Entry.Mode)) {
+			// Restoring a file writes through a link to a file, so the
+			// snapshot keeps the content the link points to
+			if target, statErr := ctx.FS.Stat(t.path); statErr == nil && !target.IsDir() {
+				info = target
+			}
+		}
+		if os.IsNotExist(err) {
+			ctx.Snapshot.AddEntry(appName, ManifestEntry{Source: t.path, Absent: true})
+			ctx.snapshotted[t.path] = true
+			added = true
+			continue
+		} else if err != nil {
+			return fmt.Errorf("error accessing %s for pre-restore snapshot: %w", t.path, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("cannot restore file over directory '%s'", t.path)
+		}
+
+		stored := path.Join(appName, ctx.StoredRelPath(t.path))
+		entry, err := ctx.writeStoredFile(ctx.snapshotDir(), storedFile{source: t.path, stored: stored, info: info})
+		if err != nil {
+			return fmt.Errorf("failed to save %s to pre-restore snapshot: %w", t.path, err)
+		}
+		ctx.Snapshot.AddEntry(appName, entry)
+		ctx.snapshotted[t.path] = true
+		added = true
+	}
+
+	if !added {
+		return nil
+	}
+	if err := ctx.FS.MkdirAll(ctx.snapshotDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create pre-restore snapshot directory: %w", err)
+	}
+	return writeManifest(ctx.snapshotDir(), ctx.Snapshot)
+}
+
+// ListSnapshots returns the pre-restore snapshots in the backup folder, newest
+// first.
+func ListSnapshots(baseBackupPath string) ([]Version, error) {
+	entries, err := Fs.ReadDir(baseBackupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Version
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), snapshotPrefix)
+		if !ok || !entry.IsDir() {
+			continue
+		}
+		t, err := time.ParseInLocation(versionTimestampFormat, name, time.Local)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Version{
+			Name: entry.Name(),
+			Path: Fs.Join(baseBackupPath, entry.Name()),
+			Time: t,
+		})
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.After(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// pruneSnapshots removes the pre-restore snapshots older than the newest
+// snapshotsToKeep. In a dry run it only lists what would be removed. It returns
+// the decision made for every snapshot.
+func pruneSnapshots(baseBackupPath string) ([]RetentionDecision, error) {
+	snapshots, err := ListSnapshots(baseBackupPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	decisions := make([]RetentionDecision, len(snapshots))
+	for i, snapshot := range snapshots {
+		decisions[i].Version = snapshot
+		if i < snapshotsToKeep {
+			decisions[i].Keep = true
+			decisions[i].Reasons = []string{fmt.Sprintf("last %d snapshots", snapshotsToKeep)}
+			continue
+		}
+		decisions[i].Reasons = []string{fmt.Sprintf("not one of the last %d snapshots", snapshotsToKeep)}
+		if DryRun {
+			AppLogger.Logf("Would remove old snapshot: %s", snapshot.Path)
+			continue
+		}
+		AppLogger.Logf("Removing old snapshot: %s", snapshot.Path)
+		if err := Fs.RemoveAll(snapshot.Path); err != nil {
+			AppLogger.Errorf("failed to remove old snapshot %s: %v", snapshot.Path, err)
+		}
+	}
+	return decisions, nil
+}
+
+// Undo reverts the most recent restore by putting back the files saved in its
+// pre-restore snapshot and removing the files the restore created. The
+// snapshot is deleted once every file has been reverted. It uses the
+// BackupFolder, ConfigFolder and Password of opts.
+//
+// The snapshot manifest lives in the backup folder, which may be synced from
+// elsewhere, so only the paths a restore could have written are reverted: see
+// undoScope.
+func Undo(opts Options) error {
+	ctx, err := NewBackupContext(opts.ConfigFolder, opts.BackupFolder, nil, false, false, 0, false, opts.Password)
+	if err != nil {
+		return fmt.Errorf("error creating backup context: %w", err)
+	}
+	backupFolder, password := ctx.BackupFolder, ctx.Password
+
+	snapshots, err := ListSnapshots(backupFolder)
+	if err != nil {
+		return fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no pre-restore snapshot found in %s", backupFolder)
+	}
+	snapshot := snapshots[0]
+
+	version, err := openBackupVersion(snapshot.Path, false)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot '%s': %w", snapshot.Path, err)
+	}
+	defer func() {
+		if err := version.Close(); err != nil {
+			AppLogger.Errorf("failed to close snapshot %s: %v", snapshot.Path, err)
+		}
+	}()
+	if version.Manifest == nil {
+		return fmt.Errorf("snapshot '%s' has no %s", snapshot.Path, ManifestFileName)
+	}
+	scope := ctx.undoScope()
+
+	AppLogger.Logf("Undoing restore from snapshot %s", snapshot.Path)
+	failed := 0
+	for _, app := range version.Manifest.Apps {
+		Printer.Reset()
+		Printer.SetAppName(app.Name)
+		for _, entry := range app.Files {
+			if !scope.allows(app.Name, entry.Source) {
+				AppLogger.Errorf("refusing to revert %s: not a path a restore of %s could have written", entry.Source, app.Name)
+				failed++
+				continue
+			}
+			if err := undoEntry(version, entry, password); err != nil {
+				AppLogger.Errorf("failed to revert %s: %v", entry.Source, err)
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) could not be reverted; snapshot kept at %s", failed, snapshot.Path)
+	}
+	if DryRun {
+		AppLogger.Logf("Would remove snapshot: %s", snapshot.Path)
+		return nil
+	}
+	if err := Fs.RemoveAll(snapshot.Path); err != nil {
+		return fmt.Errorf("restore undone but failed to remove snapshot %s: %w", snapshot.Path, err)
+	}
+	AppLogger.Logf("Removed snapshot: %s", snapshot.Path)
+	return nil
+}
+
+// undoEntry reverts one file recorded in a pre-restore snapshot.
+func undoEntry(version *backupVersion, entry ManifestEntry, password string) error {
+	if entry.Absent {
+		if _, err := Fs.Lstat(entry.Source); os.IsNotExist(err) {
+			return nil
+		}
+		if DryRun {
+			Printer.Print("Would remove %s (did not exist before restore)", entry.Source)
+			return nil
+		}
+		if err := Fs.Remove(entry.Source); err != nil {
+			return err
+		}
+		Printer.Print("Removed %s (did not exist before restore)", entry.Source)
+		return nil
+	}
+
+	data, err := readStoredFile(version, versionFile{Name: entry.Stored, Encrypted: entry.Encrypted}, password)
+	if err != nil {
+		return err
+	}
+	if DryRun {
+		Printer.Print("Would revert %s", entry.Source)
+		return nil
+	}
+	if err := Fs.MkdirAll(Fs.Dir(entry.Source), 0755); err != nil {
+		return fmt.Errorf("error creating directory '%s': %w", Fs.Dir(entry.Source), err)
+	}
+	if err := writeRestoredData(entry.Source, data, &entry); err != nil {
+		return err
+	}
+	Printer.Print("Reverted %s", entry.Source)
+	return nil
+}
+
+// undoScope holds the paths a restore could have written, which are the only
+// paths undo reverts: files in the home directory, and the configured paths
+// outside it of each application, with the files below them.
+type undoScope struct {
+	home  string
+	paths map[string][][]string
+}
+
+// undoScope builds the scope from the configuration folder. When no
+// configuration can be loaded, only files in the home directory are reverted.
+func (ctx *BackupContext) undoScope() undoScope {
+	scope := undoScope{home: filepath.Clean(ctx.HomeDir), paths: make(map[string][][]string)}
+	configs, err := ctx.LoadConfigs()
+	if err != nil {
+		AppLogger.Warnf("%v; only files in the home directory can be reverted", err)
+		return scope
+	}
+	for _, cfg := range configs {
+		for _, configFile := range cfg.Files {
+			resolved := filepath.ToSlash(filepath.Clean(ctx.ResolveConfigFilePath(configFile)))
+			scope.paths[cfg.Name] = append(scope.paths[cfg.Name], strings.Split(resolved, "/"))
+		}
+	}
+	return scope
+}
+
+// allows reports whether a restore of appName could have written source.
+func (s undoScope) allows(appName, source string) bool {
+	if !filepath.IsAbs(source) || filepath.Clean(source) != source {
+		return false
+	}
+	if rel, err := filepath.Rel(s.home, source); err == nil &&
+		rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return true
+	}
+	segments := strings.Split(filepath.ToSlash(source), "/")
+	for _, pattern := range s.paths[appName] {
+		if matchesOrIsBelow(pattern, segments) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreSnapshotAndUndo(t *testing.T) {
+	for _, password := range []string{"", "snapshot-password"} {
+		configDir, backupDir, homeDir := setupLayoutTest(t)
+
+		gitconfig := filepath.Join(homeDir, ".gitconfig")
+		ignore := filepath.Join(homeDir, ".config", "git", "ignore")
+		createDummyFile(t, gitconfig, "backed up")
+		createDummyFile(t, ignore, "*.swp")
+		createDummyFile(t, filepath.Join(configDir, "git.cfg"), `[application]
+name = Git
+[configuration_files]
+.gitconfig
+.config/git
+`)
+
+		ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, password)
+
+		// Local edits made after the backup, and a file the backup will recreate
+		createDummyFile(t, gitconfig, "local edits")
+		if err := os.Remove(ignore); err != nil {
+			t.Fatalf("Failed to remove file: %v", err)
+		}
+
+		ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, password)
+		assertFileContent(t, gitconfig, "backed up")
+		assertFileContent(t, ignore, "*.swp")
+
+		snapshots, err := ListSnapshots(backupDir)
+		if err != nil || len(snapshots) != 1 {
+			t.Fatalf("Expected one snapshot, got %v (err=%v)", snapshots, err)
+		}
+		m := readTestManifest(t, snapshots[0].Path, false)
+		entries := m.Entries()
+		if len(entries) != 2 {
+			t.Fatalf("Expected 2 snapshot entries, got %+v", entries)
+		}
+		for _, entry := range entries {
+			if entry.Source == ignore && !entry.Absent {
+				t.Errorf("Recreated file should be recorded as absent: %+v", entry)
+			}
+			if entry.Source == gitconfig && (entry.Absent || entry.Encrypted != (password != "")) {
+				t.Errorf("Unexpected snapshot entry for .gitconfig: %+v", entry)
+			}
+		}
+
+		// Snapshots are not backup versions
+		if latest, _, _ := GetLatestVersionPath(backupDir); strings.Contains(latest, snapshotPrefix) {
+			t.Errorf("Snapshot returned as latest version: %s", latest)
+		}
+
+		if err := Undo(Options{ConfigFolder: configDir, BackupFolder: backupDir, Password: password}); err != nil {
+			t.Fatalf("Undo failed: %v", err)
+		}
+		assertFileContent(t, gitconfig, "local edits")
+		if _, err := os.Stat(ignore); !os.IsNotExist(err) {
+			t.Errorf("File created by restore should be removed by undo, stat err = %v", err)
+		}
+		if snapshots, _ := ListSnapshots(backupDir); len(snapshots) != 0 {
+			t.Errorf("Snapshot should be deleted after undo, found %v", snapshots)
+		}
+
+		if err := Undo(Options{ConfigFolder: configDir, BackupFolder: backupDir, Password: password}); err == nil {
+			t.Error("Expected error when there is nothing to undo")
+		}
+	}
+}
+
+func TestUndo_EncryptedSnapshotNeedsPassword(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	settings := filepath.Join(homeDir, ".settings")
+	createDummyFile(t, settings, "v1")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.settings\n")
+
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, "secret")
+	createDummyFile(t, settings, "v2")
+	ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, "secret")
+
+	if err := Undo(Options{ConfigFolder: configDir, BackupFolder: backupDir}); err == nil {
+		t.Fatal("Expected undo to fail without the password")
+	}
+	assertFileContent(t, settings, "v1")
+	if snapshots, _ := ListSnapshots(backupDir); len(snapshots) != 1 {
+		t.Errorf("Snapshot must be kept when undo fails, found %v", snapshots)
+	}
+
+	if err := Undo(Options{ConfigFolder: configDir, BackupFolder: backupDir, Password: "secret"}); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	assertFileContent(t, settings, "v2")
+}
+
+func TestRestore_DryRunTakesNoSnapshot(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(homeDir, ".settings"), "v1")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.settings\n")
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, "")
+
+	DryRun = true
+	defer func() { DryRun = false }()
+	ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, "")
+
+	if snapshots, _ := ListSnapshots(backupDir); len(snapshots) != 0 {
+		t.Errorf("Dry run must not create a snapshot, found %v", snapshots)
+	}
+}
+
+func TestRestore_KeepsOnlyRecentSnapshots(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(homeDir, ".settings"), "v1")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.settings\n")
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, "")
+
+	old := []string{"20200101-100000", "20200102-100000", "20200103-100000", "20200104-100000"}
+	for _, ts := range old {
+		if err := os.MkdirAll(filepath.Join(backupDir, snapshotPrefix+ts), 0755); err != nil {
+			t.Fatalf("Failed to create snapshot: %v", err)
+		}
+	}
+
+	ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, "")
+
+	snapshots, err := ListSnapshots(backupDir)
+	if err != nil || len(snapshots) != snapshotsToKeep {
+		t.Fatalf("Expected %d snapshots, got %v (err=%v)", snapshotsToKeep, snapshots, err)
+	}
+	if want := snapshotPrefix + old[3]; snapshots[1].Name != want {
+		t.Errorf("Second snapshot = %s, want %s", snapshots[1].Name, want)
+	}
+	for _, ts := range old[:2] {
+		if _, err := os.Stat(filepath.Join(backupDir, snapshotPrefix+ts)); !os.IsNotExist(err) {
+			t.Errorf("Old snapshot %s should be removed, stat err = %v", ts, err)
+		}
+	}
+}
+
+func assertFileContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("Failed to read %s: %v", path, err)
+		return
+	}
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", path, data, want)
+	}
+}
+
+func TestUndo_RefusesPathsOutsideRestoreScope(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	settings := filepath.Join(homeDir, ".settings")
+	createDummyFile(t, settings, "v1")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.settings\n")
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, false, "")
+	createDummyFile(t, settings, "v2")
+	ProcessConfiguration(configDir, backupDir, nil, false, false, 1, false, "")
+
+	// A snapshot manifest edited to remove or overwrite files no restore wrote
+	victimDir := filepath.Join(filepath.Dir(homeDir), "outside")
+	victim := filepath.Join(victimDir, "victim")
+	createDummyFile(t, victim, "keep me")
+	snapshots, err := ListSnapshots(backupDir)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("Expected one snapshot, got %v (err=%v)", snapshots, err)
+	}
+	m := readTestManifest(t, snapshots[0].Path, false)
+	m.Apps[0].Files[0].Source = victim
+	m.AddEntry("App", ManifestEntry{Source: victimDir, Absent: true})
+	m.AddEntry("App", ManifestEntry{Source: filepath.Join(homeDir, "..", "outside", "victim"), Absent: true})
+	if err := writeManifest(snapshots[0].Path, m); err != nil {
+		t.Fatalf("writeManifest failed: %v", err)
+	}
+
+	if err := Undo(Options{ConfigFolder: configDir, BackupFolder: backupDir}); err == nil {
+		t.Fatal("Expected undo to refuse paths outside the restore scope")
+	}
+	assertFileContent(t, victim, "keep me")
+	if snapshots, _ := ListSnapshots(backupDir); len(snapshots) != 1 {
+		t.Errorf("Snapshot must be kept when undo fails, found %v", snapshots)
+	}
+}
@@ -0,0 +1,328 @@
+package backup
+
+import (
+	"SettingsSentry/pkg/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trustStoreFile is the location of the trust store relative to the home
+// directory. It is kept out of the config and backup folders on purpose: those
+// are often synced, and whoever can change them must not be able to approve
+// their own commands.
+const trustStoreFile = ".settingssentry/trusted_commands.json"
+
+// TrustOnFirstUse approves and records the commands of a config file seen for
+// the first time instead of refusing them. The CLI sets it for runs started
+// from a terminal, where -allow-commands is the user's approval; unattended
+// runs, such as cron jobs, only run commands approved with the trust action.
+var TrustOnFirstUse bool
+
+// TrustedApp records the commands of a config file the user approved.
+type TrustedApp struct {
+	// App is the application name of the config file when it was approved.
+	App      string    `json:"app,omitempty"`
+	SHA256   string    `json:"sha256"`
+	Commands []string  `json:"commands"`
+	Approved time.Time `json:"approved"`
+}
+
+// TrustStore maps the absolute paths of config files to their approved
+// commands. Approval is tied to the file, so neither renaming the application
+// of an approved config nor adding a config file carries it over.
+type TrustStore struct {
+	Apps map[string]TrustedApp `json:"apps"`
+
+	path string
+}
+
+// Trust states of an application's commands, as reported by TrustStore.Check.
+const (
+	TrustNone    = "no commands"
+	TrustOK      = "trusted"
+	TrustNew     = "new"
+	TrustChanged = "changed"
+)
+
+// AppTrust is the trust state of the commands of one application.
+type AppTrust struct {
+	Name string
+	// Config is the absolute path of the config file of the application.
+	Config   string
+	Status   string
+	Commands []string
+	// Previous holds the approved commands of an app whose commands changed.
+	Previous []string
+}
+
+// commandList returns every command an application can run, one line per
+// command prefixed with its section, in a stable order. This is what gets
+// approved: adding, removing or editing any command changes its hash.
+func commandList(cfg config.Config) []string {
+	var lines []string
+	add := func(section string, commands []string) {
+		for _, c := range commands {
+			lines = append(lines, section+": "+c)
+		}
+	}
+	add("pre_backup_commands", cfg.PreBackupCommands)
+	add("post_backup_commands", cfg.PostBackupCommands)
+	add("pre_restore_commands", cfg.PreRestoreCommands)
+	add("post_restore_commands", cfg.PostRestoreCommands)
+	for _, output := range cfg.CommandOutputs {
+		lines = append(lines, "command_outputs: "+output.Name+" = "+output.Command)
+		if output.RestoreCommand != "" {
+			lines = append(lines, "command_inputs: "+output.Name+" = "+output.RestoreCommand)
+		}
+	}
+	return lines
+}
+
+// commandListHash returns the SHA-256 checksum of a command list.
+func commandListHash(commands []string) string {
+	return sha256Hex([]byte(strings.Join(commands, "\n")))
+}
+
+// trustStorePath returns the trust store of the user with the given home
+// directory.
+func trustStorePath(homeDir string) string {
+	return Fs.Join(homeDir, trustStoreFile)
+}
+
+// LoadTrustStore reads the trust store at path. A missing store is empty.
+func LoadTrustStore(path string) (*TrustStore, error) {
+	store := &TrustStore{Apps: make(map[string]TrustedApp), path: path}
+	data, err := Fs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read trust store '%s': %w", path, err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to decode trust store '%s': %w", path, err)
+	}
+	if store.Apps == nil {
+		store.Apps = make(map[string]TrustedApp)
+	}
+	return store, nil
+}
+
+// Save writes the trust store back to its file, readable by the user only.
+func (s *TrustStore) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode trust store: %w", err)
+	}
+	if err := Fs.MkdirAll(Fs.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create trust store directory: %w", err)
+	}
+	if err := Fs.WriteFile(s.path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write trust store '%s': %w", s.path, err)
+	}
+	return nil
+}
+
+// Check returns the trust state of the commands of cfg, read from the config
+// file at configPath.
+func (s *TrustStore) Check(configPath string, cfg config.Config) AppTrust {
+	commands := commandList(cfg)
+	result := AppTrust{Name: cfg.Name, Config: configPath, Commands: commands}
+	approved, ok := s.Apps[configPath]
+	switch {
+	case len(commands) == 0:
+		result.Status = TrustNone
+	case !ok:
+		result.Status = TrustNew
+	case approved.SHA256 != commandListHash(commands):
+		result.Status = TrustChanged
+		result.Previous = approved.Commands
+	default:
+		result.Status = TrustOK
+	}
+	return result
+}
+
+// Approve records the current commands of cfg, read from the config file at
+// configPath, as trusted.
+func (s *TrustStore) Approve(configPath string, cfg config.Config) {
+	commands := commandList(cfg)
+	s.Apps[configPath] = TrustedApp{
+		App:      cfg.Name,
+		SHA256:   commandListHash(commands),
+		Commands: commands,
+		Approved: time.Now().UTC(),
+	}
+}
+
+// trustCommands decides whether the commands of cfg, read from the config
+// file at configPath, may run, and returns why not when they may not. With
+// TrustOnFirstUse, commands seen for the first time are trusted: passing
+// -allow-commands is the approval, and they are recorded in the trust store.
+// Otherwise, and for commands that changed since they were approved, they are
+// refused until approved with the trust action.
+func (ctx *BackupContext) trustCommands(configPath string, cfg config.Config) error {
+	if ctx.Trust == nil {
+		return nil
+	}
+
+	var refused error
+	trust := ctx.Trust.Check(configPath, cfg)
+	switch trust.Status {
+	case TrustNone, TrustOK:
+		return nil
+	case TrustNew:
+		if !TrustOnFirstUse {
+			AppLogger.Warnf("Not running commands of %s: %s was not approved yet. Review it with 'settingssentry trust -app=%s'", cfg.Name, configPath, cfg.Name)
+			refused = errors.New("commands not approved yet")
+			break
+		}
+		if DryRun {
+			ctx.Printer.Print("Would trust the commands of %s on first use", cfg.Name)
+			return nil
+		}
+		ctx.Trust.Approve(configPath, cfg)
+		if err := ctx.Trust.Save(); err != nil {
+			AppLogger.Warnf("Not running commands of %s: %v", cfg.Name, err)
+			refused = err
+			break
+		}
+		AppLogger.Logf("Trusting the commands of %s on first use (recorded in %s)", cfg.Name, ctx.Trust.path)
+		return nil
+	default:
+		AppLogger.Warnf("Not running commands of %s: they changed since they were approved. Review them with 'settingssentry trust -app=%s'", cfg.Name, cfg.Name)
+		refused = errors.New("commands changed since they were approved")
+	}
+	ctx.record(ItemResult{Action: "trust", Status: ItemFailed, Err: refused})
+	return refused
+}
+
+// ReviewTrust shows the trust state of the commands of every selected
+// application and, with approve, records the new and changed ones as trusted.
+func ReviewTrust(opts Options, approve bool) ([]AppTrust, error) {
+	ctx, err := NewBackupContext(opts.ConfigFolder, opts.BackupFolder, opts.AppNames, false, true, 0, false, "")
+	if err != nil {
+		return nil, fmt.Errorf("error creating backup context: %w", err)
+	}
+	store, err := LoadTrustStore(trustStorePath(ctx.HomeDir))
+	if err != nil {
+		return nil, err
+	}
+
+	currentFS, files, err := ctx.LoadConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []AppTrust
+	changed := false
+	for _, file := range ctx.FilterConfigFiles(files) {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".cfg") {
+			continue
+		}
+		cfg, err := config.ParseConfig(currentFS, file.Name())
+		if err != nil {
+			AppLogger.Errorf("failed to parse config file '%s': %v", file.Name(), err)
+			continue
+		}
+		cfg.Name = sanitizeConfigName(cfg.Name)
+
+		configPath := ctx.configFilePath(file.Name())
+		trust := store.Check(configPath, cfg)
+		if trust.Status == TrustNone {
+			continue
+		}
+		if approve && (trust.Status == TrustNew || trust.Status == TrustChanged) {
+			store.Approve(configPath, cfg)
+			changed = true
+		}
+		results = append(results, trust)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Name != results[j].Name {
+			return results[i].Name < results[j].Name
+		}
+		return results[i].Config < results[j].Config
+	})
+
+	if changed && !DryRun {
+		if err := store.Save(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// PrintTrust prints the result of ReviewTrust. The commands of apps that are
+// not trusted yet are shown in full; changed ones as a list of removed (-) and
+// added (+) commands.
+func PrintTrust(results []AppTrust, approved bool) {
+	if len(results) == 0 {
+		Printer.Print("No application has commands")
+		return
+	}
+	for _, r := range results {
+		switch r.Status {
+		case TrustOK:
+			Printer.Print("%s (%s): trusted", r.Name, r.Config)
+			continue
+		case TrustNew:
+			Printer.Print("%s (%s): new commands", r.Name, r.Config)
+			for _, c := range r.Commands {
+				Printer.Print("    %s", c)
+			}
+		case TrustChanged:
+			Printer.Print("%s (%s): commands changed since they were approved", r.Name, r.Config)
+			lines := commandListChanges(r.Previous, r.Commands)
+			if len(lines) == 0 {
+				Printer.Print("  same commands in a different order")
+			}
+			for _, line := range lines {
+				Printer.Print("  %s", line)
+			}
+		}
+		if approved {
+			Printer.Print("  approved")
+		}
+	}
+}
+
+// commandListChanges lists the commands only in previous (prefixed with "- ")
+// and only in current (prefixed with "+ ").
+func commandListChanges(previous, current []string) []string {
+	inPrevious := make(map[string]bool, len(previous))
+	for _, c := range previous {
+		inPrevious[c] = true
+	}
+	inCurrent := make(map[string]bool, len(current))
+	for _, c := range current {
+		inCurrent[c] = true
+	}
+
+	var lines []string
+	for _, c := range previous {
+		if !inCurrent[c] {
+			lines = append(lines, "- "+c)
+		}
+	}
+	for _, c := range current {
+		if !inPrevious[c] {
+			lines = append(lines, "+ "+c)
+		}
+	}
+	return lines
+}
+
+// configFilePath returns the absolute path of a config file of the config
+// folder, under which its commands are approved.
+func (ctx *BackupContext) configFilePath(name string) string {
+	path := ctx.FS.Join(ctx.ConfigFolder, name)
+	if abs, err := ctx.FS.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
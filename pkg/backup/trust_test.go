@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcess_TrustOnFirstUse(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	setupHookTest(t)
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	createDummyFile(t, filepath.Join(homeDir, ".app"), "settings")
+	cfgPath := filepath.Join(configDir, "app.cfg")
+	writeConfig := func(command string) {
+		createDummyFile(t, cfgPath, fmt.Sprintf("[application]\nname = App\n[pre_backup_commands]\n%s\n[configuration_files]\n.app\n", command))
+	}
+	run := func() error {
+		_, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, Commands: true, VersionsToKeep: 1})
+		return err
+	}
+
+	// First use: the commands run and are recorded
+	writeConfig(fmt.Sprintf("echo first >> %q", marker))
+	if err := run(); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	assertFileContent(t, marker, "first\n")
+	store, err := LoadTrustStore(trustStorePath(homeDir))
+	if err != nil {
+		t.Fatalf("LoadTrustStore failed: %v", err)
+	}
+	if _, ok := store.Apps[cfgPath]; !ok {
+		t.Fatalf("Expected App in the trust store, got %+v", store.Apps)
+	}
+
+	// Changed commands are refused until approved
+	writeConfig(fmt.Sprintf("echo tampered >> %q", marker))
+	if err := run(); err == nil {
+		t.Error("Expected Process to report the untrusted commands")
+	}
+	assertFileContent(t, marker, "first\n")
+
+	results, err := ReviewTrust(Options{ConfigFolder: configDir, BackupFolder: backupDir}, false)
+	if err != nil {
+		t.Fatalf("ReviewTrust failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != TrustChanged {
+		t.Fatalf("Expected App to be reported as changed, got %+v", results)
+	}
+	if lines := commandListChanges(results[0].Previous, results[0].Commands); len(lines) != 2 {
+		t.Errorf("Expected one removed and one added command, got %v", lines)
+	}
+
+	if _, err := ReviewTrust(Options{ConfigFolder: configDir, BackupFolder: backupDir}, true); err != nil {
+		t.Fatalf("ReviewTrust with approve failed: %v", err)
+	}
+	if err := run(); err != nil {
+		t.Fatalf("Process failed after approval: %v", err)
+	}
+	assertFileContent(t, marker, "first\ntampered\n")
+
+	info, err := os.Stat(trustStorePath(homeDir))
+	if err != nil {
+		t.Fatalf("Stat trust store failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Trust store mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestProcess_UnattendedRunRefusesUnapprovedCommands(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	setupHookTest(t)
+	TrustOnFirstUse = false
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	createDummyFile(t, filepath.Join(homeDir, ".app"), "settings")
+	writeConfig := func(file, name string) {
+		createDummyFile(t, filepath.Join(configDir, file), fmt.Sprintf("[application]\nname = %s\n[pre_backup_commands]\necho %s >> %q\n[configuration_files]\n.app\n", name, file, marker))
+	}
+	run := func() error {
+		_, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, Commands: true, VersionsToKeep: 1})
+		return err
+	}
+
+	// Commands never approved are not trusted on first use
+	writeConfig("app.cfg", "App")
+	if err := run(); err == nil {
+		t.Error("Expected Process to report the unapproved commands")
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("Expected unapproved commands not to run")
+	}
+	if store, err := LoadTrustStore(trustStorePath(homeDir)); err != nil || len(store.Apps) != 0 {
+		t.Fatalf("Expected nothing to be approved, got %+v (%v)", store, err)
+	}
+
+	if _, err := ReviewTrust(Options{ConfigFolder: configDir, BackupFolder: backupDir}, true); err != nil {
+		t.Fatalf("ReviewTrust with approve failed: %v", err)
+	}
+	if err := run(); err != nil {
+		t.Fatalf("Process failed after approval: %v", err)
+	}
+	assertFileContent(t, marker, "app.cfg\n")
+
+	// Approval belongs to the config file, not to the application name: a
+	// new config file claiming the approved name is refused
+	writeConfig("other.cfg", "App")
+	if err := run(); err == nil {
+		t.Error("Expected Process to report the commands of the new config file")
+	}
+	assertFileContent(t, marker, "app.cfg\napp.cfg\n")
+}
+
+func TestProcess_UntrustedCommandOutputsAreRecordedAsSkipped(t *testing.T) {
+	configDir, backupDir, _ := setupLayoutTest(t)
+	setupHookTest(t)
+	TrustOnFirstUse = false
+
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), "[application]\nname = App\n[command_outputs]\nextensions = printf 'go\\n'\n")
+
+	result, err := Process(Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true, Commands: true, VersionsToKeep: 1})
+	if err == nil {
+		t.Error("Expected Process to report the unapproved commands")
+	}
+	if result == nil || len(result.Apps) != 1 {
+		t.Fatalf("Expected a result for App, got %+v", result)
+	}
+	var skipped []ItemResult
+	for _, item := range result.Apps[0].Items {
+		if item.Status == ItemSkipped {
+			skipped = append(skipped, item)
+		}
+	}
+	if len(skipped) != 1 || skipped[0].Source != "$ printf 'go\\n'" || skipped[0].Reason != "commands not approved yet" {
+		t.Errorf("Expected the command output to be skipped as not approved, got %+v", skipped)
+	}
+}
@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scheduled backups often find nothing new. With SkipUnchanged such a run does
+// not keep its version, so -versions retention is not used up by copies of
+// the latest version.
+
+// recordContent remembers the checksum of the plaintext content of a stored
+// file of the version being created.
+func (ctx *BackupContext) recordContent(stored, checksum string) {
+	if !ctx.SkipUnchanged {
+		return
+	}
+	if ctx.contents == nil {
+		ctx.contents = make(map[string]string)
+	}
+	ctx.contents[stored] = checksum
+}
+
+// unchangedSinceLatest compares the version being created with the latest
+// version in the backup folder. It returns the name of the latest version when
+// both hold the same files, with the same content and mode, in the same format.
+// Modification times alone do not make a difference.
+func (ctx *BackupContext) unchangedSinceLatest() (string, bool) {
+	if !ctx.SkipUnchanged || ctx.Manifest == nil || DryRun {
+		return "", false
+	}
+	if ctx.Result != nil && ctx.Result.Count(ItemFailed) > 0 {
+		return "", false
+	}
+
+	versions, err := ListVersions(ctx.BackupFolder)
+	if err != nil {
+		return "", false
+	}
+	var latest *Version
+	for i := range versions {
+		if versions[i].Name != ctx.Timestamp {
+			latest = &versions[i]
+			break
+		}
+	}
+	if latest == nil {
+		return "", false
+	}
+
+	version, err := openBackupVersion(latest.Path, latest.IsZip)
+	if err != nil {
+		ctx.Logger.Debugf("Cannot compare with version %s: %v", latest.Name, err)
+		return "", false
+	}
+	defer func() {
+		_ = version.Close()
+	}()
+	if version.Manifest == nil || version.Manifest.Flags.Zip != ctx.ZipBackup ||
+		version.Manifest.Flags.Dedup != ctx.Dedup || version.Manifest.Flags.Encrypted != (ctx.Password != "") {
+		return "", false
+	}
+
+	previous := make(map[string]versionFile)
+	for _, f := range version.Files {
+		previous[f.Path] = f
+	}
+	count := 0
+	for _, app := range ctx.Manifest.Apps {
+		for _, entry := range app.Files {
+			count++
+			stored := storedPathWithoutSuffix(entry)
+			f, ok := previous[stored]
+			if !ok || f.Entry.Source != entry.Source || f.Entry.Command != entry.Command || f.Entry.Mode != entry.Mode {
+				return "", false
+			}
+			checksum, ok := ctx.contents[stored]
+			if !ok || checksum != ctx.storedChecksum(version, f) {
+				return "", false
+			}
+		}
+	}
+	if count != len(previous) {
+		return "", false
+	}
+	return latest.Name, true
+}
+
+// discardUnchanged removes the version being created, which holds the same
+// files as the latest version. Objects it added to the object store, such as
+// freshly encrypted copies, are collected again. The staging directory of zip
+// backups is removed when Process returns.
+func (ctx *BackupContext) discardUnchanged(latest string) error {
+	if !ctx.ZipBackup {
+		versionDir := ctx.versionRoot()
+		if err := ctx.FS.RemoveAll(versionDir); err != nil {
+			return fmt.Errorf("failed to remove unchanged version %s: %w", versionDir, err)
+		}
+	}
+	if ctx.Dedup {
+		if err := collectGarbage(ctx.BackupFolder); err != nil {
+			return err
+		}
+	}
+	ctx.Logger.Logf("Nothing changed since version %s; no new version was written", latest)
+	return nil
+}
+
+// storedChecksum returns the checksum of the plaintext content of a stored
+// file, or an empty string when it cannot be read.
+func (ctx *BackupContext) storedChecksum(version *backupVersion, f versionFile) string {
+	if !f.Encrypted {
+		return f.Entry.SHA256
+	}
+	plaintext, err := readStoredFile(version, f, ctx.Password)
+	if err != nil {
+		return ""
+	}
+	return sha256Hex(plaintext)
+}
+
+// storedPathWithoutSuffix returns the stored path of an entry without the
+// encryption suffix.
+func storedPathWithoutSuffix(entry ManifestEntry) string {
+	if entry.Encrypted {
+		return strings.TrimSuffix(entry.Stored, encryptedSuffix)
+	}
+	return entry.Stored
+}
@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// backupUnchangedTwice backs up .app twice with opts, touching it in between,
+// and returns the result of the second run.
+func backupUnchangedTwice(t *testing.T, opts Options, homeDir string) *Result {
+	t.Helper()
+	createDummyFile(t, filepath.Join(homeDir, ".app"), "settings")
+	createDummyFile(t, filepath.Join(opts.ConfigFolder, "app.cfg"), "[application]\nname = App\n[configuration_files]\n.app\n")
+
+	result, err := Process(opts)
+	if err != nil {
+		t.Fatalf("First backup failed: %v", err)
+	}
+	// Give the first version an older timestamp so the second one gets its own
+	first := filepath.Join(opts.BackupFolder, result.Version)
+	renamed := filepath.Join(opts.BackupFolder, "20200101-000000")
+	if opts.ZipBackup {
+		first, renamed = first+".zip", renamed+".zip"
+	}
+	if err := os.Rename(first, renamed); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(homeDir, ".app"), later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if result, err = Process(opts); err != nil {
+		t.Fatalf("Second backup failed: %v", err)
+	}
+	return result
+}
+
+func assertVersionCount(t *testing.T, backupDir string, want int) {
+	t.Helper()
+	versions, err := ListVersions(backupDir)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != want {
+		t.Errorf("Expected %d version(s), got %+v", want, versions)
+	}
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"directory", Options{}},
+		{"encrypted zip", Options{ZipBackup: true, Password: "secret"}},
+		{"encrypted dedup", Options{Dedup: true, Password: "secret"}},
+		{"incremental", Options{Incremental: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configDir, backupDir, homeDir := setupLayoutTest(t)
+			opts := tt.opts
+			opts.ConfigFolder, opts.BackupFolder, opts.IsBackup, opts.SkipUnchanged = configDir, backupDir, true, true
+
+			result := backupUnchangedTwice(t, opts, homeDir)
+			if !result.Unchanged || result.Version != "20200101-000000" {
+				t.Errorf("Expected the run to find version 20200101-000000 unchanged, got %+v", result)
+			}
+			assertVersionCount(t, backupDir, 1)
+			if opts.Dedup {
+				if got := countObjects(t, backupDir); got != 1 {
+					t.Errorf("Expected the object of the discarded version to be collected, got %d objects", got)
+				}
+			}
+
+			createDummyFile(t, filepath.Join(homeDir, ".app"), "changed")
+			if result, err := Process(opts); err != nil || result.Unchanged {
+				t.Fatalf("Expected a new version for the changed file, got %+v, %v", result, err)
+			}
+			assertVersionCount(t, backupDir, 2)
+		})
+	}
+}
+
+func TestSkipUnchanged_Disabled(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+	opts := Options{ConfigFolder: configDir, BackupFolder: backupDir, IsBackup: true}
+
+	if result := backupUnchangedTwice(t, opts, homeDir); result.Unchanged {
+		t.Errorf("Expected a new version without SkipUnchanged, got %+v", result)
+	}
+	assertVersionCount(t, backupDir, 2)
+}
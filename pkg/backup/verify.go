@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// VerifyIssue describes one problem found while verifying a backup version.
+type VerifyIssue struct {
+	Path    string
+	Problem string
+}
+
+// VerifyReport is the outcome of verifying one backup version.
+type VerifyReport struct {
+	VersionPath string
+	HasManifest bool
+	Checked     int
+	// NotDecrypted counts encrypted entries whose checksum was verified but
+	// which could not be test-decrypted because no password was given.
+	NotDecrypted int
+	Missing      []VerifyIssue
+	Corrupt      []VerifyIssue
+	Extra        []VerifyIssue
+}
+
+// OK reports whether the version passed verification.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0 && len(r.Extra) == 0
+}
+
+// VerifyVersion checks a backup version end to end: every manifest entry must
+// be present with a matching checksum, encrypted entries must decrypt with the
+// password when one is given, and no unlisted files may be present. Versions
+// created before manifests existed can only be checked for readability.
+func VerifyVersion(versionPath string, isZip bool, password string) (*VerifyReport, error) {
+	version, err := openBackupVersion(versionPath, isZip)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := version.Close(); err != nil {
+			AppLogger.Errorf("failed to close backup version %s: %v", versionPath, err)
+		}
+	}()
+
+	report := &VerifyReport{VersionPath: versionPath, HasManifest: version.Manifest != nil}
+
+	present := make(map[string]bool)
+	for _, name := range version.reader.Files() {
+		present[name] = true
+	}
+
+	listed := map[string]bool{ManifestFileName: true}
+	for _, f := range version.Files {
+		listed[f.Name] = true
+		if !present[f.Name] {
+			report.Missing = append(report.Missing, VerifyIssue{Path: f.Name, Problem: "file listed in manifest is missing"})
+			continue
+		}
+		report.Checked++
+		if problem := verifyFile(version, f, password, report); problem != "" {
+			report.Corrupt = append(report.Corrupt, VerifyIssue{Path: f.Name, Problem: problem})
+		}
+	}
+
+	if version.Manifest != nil {
+		for _, name := range version.reader.Files() {
+			if !listed[name] {
+				report.Extra = append(report.Extra, VerifyIssue{Path: name, Problem: "file is not listed in manifest"})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// verifyFile reads one stored file and returns a description of what is wrong
+// with it, or an empty string when it is intact.
+func verifyFile(version *backupVersion, f versionFile, password string, report *VerifyReport) string {
+	reader, err := version.Open(f.Name)
+	if err != nil {
+		return fmt.Sprintf("cannot open: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil {
+		return fmt.Sprintf("cannot read: %v", err)
+	}
+
+	if f.Entry != nil && f.Entry.SHA256 != "" && sha256Hex(data) != f.Entry.SHA256 {
+		return "checksum mismatch"
+	}
+
+	if !f.Encrypted {
+		if f.Entry != nil && int64(len(data)) != f.Entry.Size {
+			return fmt.Sprintf("size mismatch: expected %d bytes, found %d", f.Entry.Size, len(data))
+		}
+		return ""
+	}
+
+	if password == "" {
+		report.NotDecrypted++
+		return ""
+	}
+	plaintext, err := decrypt(data, password)
+	if err != nil {
+		return "cannot decrypt (wrong password or corrupt data)"
+	}
+	if f.Entry != nil && int64(len(plaintext)) != f.Entry.Size {
+		return fmt.Sprintf("decrypted size mismatch: expected %d bytes, found %d", f.Entry.Size, len(plaintext))
+	}
+	return ""
+}
+
+// PrintVerifyReport writes a human readable summary of a verification report.
+func PrintVerifyReport(report *VerifyReport) {
+	AppLogger.Outputf("Verifying %s", report.VersionPath)
+	if !report.HasManifest {
+		AppLogger.Outputf("  Warning: version has no %s (created by an older release); checksums cannot be verified", ManifestFileName)
+	}
+	for _, issue := range report.Missing {
+		AppLogger.Outputf("  MISSING  %s: %s", filepath.FromSlash(issue.Path), issue.Problem)
+	}
+	for _, issue := range report.Corrupt {
+		AppLogger.Outputf("  CORRUPT  %s: %s", filepath.FromSlash(issue.Path), issue.Problem)
+	}
+	for _, issue := range report.Extra {
+		AppLogger.Outputf("  EXTRA    %s: %s", filepath.FromSlash(issue.Path), issue.Problem)
+	}
+	if report.NotDecrypted > 0 {
+		AppLogger.Outputf("  %d encrypted file(s) not test-decrypted: no password provided", report.NotDecrypted)
+	}
+	AppLogger.Outputf("Checked %d file(s): %d missing, %d corrupt, %d extra", report.Checked, len(report.Missing), len(report.Corrupt), len(report.Extra))
+	if report.OK() {
+		AppLogger.Outputf("Backup version is intact")
+	}
+}
@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"SettingsSentry/logger"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func createVerifyTestBackup(t *testing.T, zipBackup bool, password string) (backupDir, versionPath string, isZip bool) {
+	t.Helper()
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	createDummyFile(t, filepath.Join(homeDir, ".gitconfig"), "[user]\n\tname = test\n")
+	createDummyFile(t, filepath.Join(homeDir, ".config", "git", "ignore"), "*.swp\n")
+	createDummyFile(t, filepath.Join(configDir, "git.cfg"), `[application]
+name = Git
+[configuration_files]
+.gitconfig
+.config/git
+`)
+
+	ProcessConfiguration(configDir, backupDir, nil, true, false, 1, zipBackup, password)
+
+	versionPath, isZip, err := GetLatestVersionPath(backupDir)
+	if err != nil {
+		t.Fatalf("GetLatestVersionPath failed: %v", err)
+	}
+	return backupDir, versionPath, isZip
+}
+
+func TestVerifyVersion_Intact(t *testing.T) {
+	for _, zipBackup := range []bool{false, true} {
+		_, versionPath, isZip := createVerifyTestBackup(t, zipBackup, "verify-password")
+
+		report, err := VerifyVersion(versionPath, isZip, "verify-password")
+		if err != nil {
+			t.Fatalf("VerifyVersion failed: %v", err)
+		}
+		if !report.OK() || report.Checked != 2 || report.NotDecrypted != 0 {
+			t.Errorf("zip=%v: unexpected report %+v", zipBackup, report)
+		}
+	}
+}
+
+func TestVerifyVersion_DetectsProblems(t *testing.T) {
+	_, versionPath, _ := createVerifyTestBackup(t, false, "")
+
+	if err := os.WriteFile(filepath.Join(versionPath, "Git", ".gitconfig"), []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(versionPath, "Git", ".config", "git", "ignore")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	createDummyFile(t, filepath.Join(versionPath, "Git", "stray"), "not in manifest")
+
+	report, err := VerifyVersion(versionPath, false, "")
+	if err != nil {
+		t.Fatalf("VerifyVersion failed: %v", err)
+	}
+	if report.OK() {
+		t.Fatal("Expected verification to fail")
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0].Path != "Git/.gitconfig" {
+		t.Errorf("Corrupt = %+v, want Git/.gitconfig", report.Corrupt)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].Path != "Git/.config/git/ignore" {
+		t.Errorf("Missing = %+v, want Git/.config/git/ignore", report.Missing)
+	}
+	if len(report.Extra) != 1 || report.Extra[0].Path != "Git/stray" {
+		t.Errorf("Extra = %+v, want Git/stray", report.Extra)
+	}
+
+	// The report is the result of the action, so -quiet does not hide it
+	var output bytes.Buffer
+	original := AppLogger.GetCliLoggerWriter()
+	AppLogger.SetCliLoggerOutput(&output)
+	AppLogger.SetLevel(logger.LevelWarn)
+	defer func() {
+		AppLogger.SetCliLoggerOutput(original)
+		AppLogger.SetLevel(logger.LevelInfo)
+	}()
+	PrintVerifyReport(report)
+	for _, want := range []string{"MISSING", "CORRUPT", "EXTRA"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Quiet verify output lacks %s: %q", want, output.String())
+		}
+	}
+}
+
+func TestVerifyVersion_Encrypted(t *testing.T) {
+	_, versionPath, isZip := createVerifyTestBackup(t, true, "right-password")
+
+	report, err := VerifyVersion(versionPath, isZip, "wrong-password")
+	if err != nil {
+		t.Fatalf("VerifyVersion failed: %v", err)
+	}
+	if len(report.Corrupt) != 2 {
+		t.Errorf("Expected both files to fail decryption, got %+v", report.Corrupt)
+	}
+
+	report, err = VerifyVersion(versionPath, isZip, "")
+	if err != nil {
+		t.Fatalf("VerifyVersion failed: %v", err)
+	}
+	if !report.OK() || report.NotDecrypted != 2 {
+		t.Errorf("Without a password checksums should pass and decryption be skipped, got %+v", report)
+	}
+}
@@ -0,0 +1,243 @@
+package backup
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// versionReader gives uniform read access to the files stored in a backup
+// version, whether it is a timestamped directory or a zip archive.
+type versionReader interface {
+	// Files returns the slash-separated paths of all files in the version,
+	// relative to the version root and sorted.
+	Files() []string
+	// Open opens a file by the path returned from Files. Stored symlinks
+	// read as their target.
+	Open(name string) (io.ReadCloser, error)
+	// Size returns the size in bytes of a file as stored in the version.
+	Size(name string) (int64, error)
+	Close() error
+}
+
+// openVersion opens the backup version at versionPath for reading.
+func openVersion(versionPath string, isZip bool) (versionReader, error) {
+	if isZip {
+		return openZipVersion(versionPath)
+	}
+	return openDirVersion(versionPath)
+}
+
+type dirVersionReader struct {
+	root  string
+	files []string
+}
+
+func openDirVersion(root string) (*dirVersionReader, error) {
+	r := &dirVersionReader{root: root}
+	if err := r.walk(""); err != nil {
+		return nil, err
+	}
+	sort.Strings(r.files)
+	return r, nil
+}
+
+func (r *dirVersionReader) walk(rel string) error {
+	entries, err := Fs.ReadDir(Fs.Join(r.root, rel))
+	if err != nil {
+		return fmt.Errorf("failed to read backup version directory '%s': %w", Fs.Join(r.root, rel), err)
+	}
+	for _, entry := range entries {
+		entryRel := path.Join(rel, entry.Name())
+		if entry.IsDir() {
+			if err := r.walk(entryRel); err != nil {
+				return err
+			}
+			continue
+		}
+		r.files = append(r.files, entryRel)
+	}
+	return nil
+}
+
+func (r *dirVersionReader) Files() []string {
+	return r.files
+}
+
+func (r *dirVersionReader) Open(name string) (io.ReadCloser, error) {
+	storedPath := Fs.Join(r.root, name)
+	if info, err := Fs.Lstat(storedPath); err == nil && isSymlink(info.Mode()) {
+		return openStoredLink(storedPath)
+	}
+	return Fs.Open(storedPath)
+}
+
+func (r *dirVersionReader) Size(name string) (int64, error) {
+	info, err := Fs.Lstat(Fs.Join(r.root, name))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (r *dirVersionReader) Close() error {
+	return nil
+}
+
+type zipVersionReader struct {
+	zipReader *zip.ReadCloser
+	entries   map[string]*zip.File
+	files     []string
+}
+
+func openZipVersion(zipPath string) (*zipVersionReader, error) {
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip backup '%s': %w", zipPath, err)
+	}
+
+	r := &zipVersionReader{zipReader: zipReader, entries: make(map[string]*zip.File)}
+	for _, f := range zipReader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		r.entries[f.Name] = f
+		r.files = append(r.files, f.Name)
+	}
+	sort.Strings(r.files)
+	return r, nil
+}
+
+func (r *zipVersionReader) Files() []string {
+	return r.files
+}
+
+func (r *zipVersionReader) Open(name string) (io.ReadCloser, error) {
+	f, ok := r.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("entry '%s' not found in zip archive", name)
+	}
+	return f.Open()
+}
+
+func (r *zipVersionReader) Size(name string) (int64, error) {
+	f, ok := r.entries[name]
+	if !ok {
+		return 0, fmt.Errorf("entry '%s' not found in zip archive", name)
+	}
+	return int64(f.UncompressedSize64), nil
+}
+
+func (r *zipVersionReader) Close() error {
+	return r.zipReader.Close()
+}
+
+// versionFile is one file stored in a backup version.
+type versionFile struct {
+	// Name is the path of the stored file relative to the version root.
+	Name string
+	// Path is the logical path of the file: Name without the encryption suffix.
+	Path string
+	// App is the application folder the file belongs to.
+	App       string
+	Encrypted bool
+	// Entry is the manifest entry of the file, nil for versions without a manifest.
+	Entry *ManifestEntry
+}
+
+// backupVersion is an opened backup version together with the index of the
+// files it holds. The index comes from the manifest when the version has one;
+// versions created by older releases are indexed from their file names.
+type backupVersion struct {
+	Path     string
+	IsZip    bool
+	Manifest *Manifest
+	Files    []versionFile
+	reader   versionReader
+}
+
+// openBackupVersion opens the backup version at versionPath and indexes it.
+func openBackupVersion(versionPath string, isZip bool) (*backupVersion, error) {
+	reader, err := openVersion(versionPath, isZip)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &backupVersion{Path: versionPath, IsZip: isZip, reader: reader}
+	if err := v.index(); err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *backupVersion) index() error {
+	for _, name := range v.reader.Files() {
+		if name != ManifestFileName {
+			continue
+		}
+		rc, err := v.reader.Open(name)
+		if err != nil {
+			return fmt.Errorf("failed to open manifest of '%s': %w", v.Path, err)
+		}
+		v.Manifest, err = readManifest(rc)
+		_ = rc.Close()
+		if err != nil {
+			return fmt.Errorf("invalid manifest in '%s': %w", v.Path, err)
+		}
+	}
+
+	if v.Manifest != nil {
+		if v.Manifest.Flags.Dedup && !v.IsZip {
+			v.reader = newObjectVersionReader(v.Path, v.Manifest)
+		}
+		for _, app := range v.Manifest.Apps {
+			for i := range app.Files {
+				entry := &app.Files[i]
+				if entry.Absent {
+					continue
+				}
+				logicalPath := entry.Stored
+				if entry.Encrypted {
+					logicalPath = strings.TrimSuffix(logicalPath, encryptedSuffix)
+				}
+				v.Files = append(v.Files, versionFile{
+					Name:      entry.Stored,
+					Path:      logicalPath,
+					App:       app.Name,
+					Encrypted: entry.Encrypted,
+					Entry:     entry,
+				})
+			}
+		}
+		return nil
+	}
+
+	for _, name := range v.reader.Files() {
+		if name == ManifestFileName {
+			continue
+		}
+		app, _, _ := strings.Cut(name, "/")
+		encrypted := strings.HasSuffix(name, encryptedSuffix)
+		v.Files = append(v.Files, versionFile{
+			Name:      name,
+			Path:      strings.TrimSuffix(name, encryptedSuffix),
+			App:       app,
+			Encrypted: encrypted,
+		})
+	}
+	return nil
+}
+
+// Open opens a stored file by its name within the version.
+func (v *backupVersion) Open(name string) (io.ReadCloser, error) {
+	return v.reader.Open(name)
+}
+
+// Close releases the resources held by the version.
+func (v *backupVersion) Close() error {
+	return v.reader.Close()
+}
@@ -197,6 +197,11 @@ func (m *mockVersionFileSystem) Create(name string) (interfaces.File, error) {
 	return nil, nil
 }
 
+func (m *mockVersionFileSystem) OpenFile(name string, flag int, perm os.FileMode) (interfaces.File, error) {
+	// Not needed for these tests
+	return nil, nil
+}
+
 func (m *mockVersionFileSystem) Join(elem ...string) string {
 	return filepath.Join(elem...)
 }
@@ -217,6 +222,44 @@ func (m *mockVersionFileSystem) EvalSymlinks(path string) (string, error) {
 	return path, nil
 }
 
+func (m *mockVersionFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return m.Stat(name)
+}
+
+func (m *mockVersionFileSystem) Readlink(name string) (string, error) {
+	return "", os.ErrInvalid
+}
+
+func (m *mockVersionFileSystem) Symlink(oldname, newname string) error {
+	// Not needed for these tests
+	return nil
+}
+
+func (m *mockVersionFileSystem) Link(oldname, newname string) error {
+	// Not needed for these tests
+	return nil
+}
+
+func (m *mockVersionFileSystem) Rename(oldpath, newpath string) error {
+	// Not needed for these tests
+	return nil
+}
+
+func (m *mockVersionFileSystem) Remove(name string) error {
+	// Not needed for these tests
+	return nil
+}
+
+func (m *mockVersionFileSystem) Chmod(name string, mode os.FileMode) error {
+	// Not needed for these tests
+	return nil
+}
+
+func (m *mockVersionFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	// Not needed for these tests
+	return nil
+}
+
 func TestGetLatestVersionPath(t *testing.T) {
 	setupVersioningTestDependencies()
 
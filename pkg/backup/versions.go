@@ -0,0 +1,158 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionTimestampFormat is the layout of the timestamp naming every backup
+// version, as a directory or as a zip archive with the .zip extension.
+const versionTimestampFormat = "20060102-150405"
+
+// beforeDateFormat is the layout accepted by the -before selector.
+const beforeDateFormat = "2006-01-02"
+
+// Version is one backup version found in the backup folder.
+type Version struct {
+	// Name is the timestamp of the version, without the .zip extension.
+	Name  string
+	Path  string
+	Time  time.Time
+	IsZip bool
+}
+
+// ListVersions returns the backup versions in the backup folder, newest first.
+// Entries whose name is not a version timestamp are ignored.
+func ListVersions(baseBackupPath string) ([]Version, error) {
+	entries, err := Fs.ReadDir(baseBackupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for _, entry := range entries {
+		entryName := entry.Name()
+		isDir := entry.IsDir()
+		isZipFile := !isDir && strings.HasSuffix(entryName, ".zip")
+		if !isDir && !isZipFile {
+			continue
+		}
+
+		name := strings.TrimSuffix(entryName, ".zip")
+		t, parseErr := time.ParseInLocation(versionTimestampFormat, name, time.Local)
+		if parseErr != nil {
+			continue
+		}
+
+		versions = append(versions, Version{
+			Name:  name,
+			Path:  Fs.Join(baseBackupPath, entryName),
+			Time:  t,
+			IsZip: isZipFile,
+		})
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Time.After(versions[j].Time)
+	})
+	return versions, nil
+}
+
+// SelectVersion picks a backup version from the backup folder.
+//
+// The selector is empty or "latest" for the newest version, "latest~N" for the
+// version N backups older than the newest, or the exact timestamp of a version
+// (with or without the .zip extension). When before is set (YYYY-MM-DD), only
+// versions created before that day are considered, so "latest" becomes the
+// newest version older than the date.
+func SelectVersion(baseBackupPath, selector, before string) (Version, error) {
+	if _, err := Fs.Stat(baseBackupPath); err != nil {
+		return Version{}, fmt.Errorf("backup path does not exist: %w", err)
+	}
+	versions, err := ListVersions(baseBackupPath)
+	if err != nil {
+		return Version{}, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	if before != "" {
+		cutoff, err := ParseBeforeDate(before)
+		if err != nil {
+			return Version{}, err
+		}
+		var older []Version
+		for _, v := range versions {
+			if v.Time.Before(cutoff) {
+				older = append(older, v)
+			}
+		}
+		if len(older) == 0 {
+			return Version{}, fmt.Errorf("no version backups found in %s before %s", baseBackupPath, before)
+		}
+		versions = older
+	}
+
+	if len(versions) == 0 {
+		return Version{}, fmt.Errorf("no version backups found in %s", baseBackupPath)
+	}
+
+	selector = strings.TrimSpace(selector)
+	if selector == "" || selector == "latest" {
+		return versions[0], nil
+	}
+
+	if rest, ok := strings.CutPrefix(selector, "latest~"); ok {
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version selector '%s': expected latest~N with N >= 0", selector)
+		}
+		if n >= len(versions) {
+			return Version{}, fmt.Errorf("version selector '%s' is out of range: only %d version(s) available", selector, len(versions))
+		}
+		return versions[n], nil
+	}
+
+	name := strings.TrimSuffix(selector, ".zip")
+	if _, err := time.Parse(versionTimestampFormat, name); err != nil {
+		return Version{}, fmt.Errorf("invalid version selector '%s': expected a timestamp (YYYYMMDD-HHMMSS), latest or latest~N", selector)
+	}
+	for _, v := range versions {
+		if v.Name == name {
+			return v, nil
+		}
+	}
+	return Version{}, fmt.Errorf("backup version '%s' not found in %s", selector, baseBackupPath)
+}
+
+// ParseBeforeDate parses the date of a -before selector. Versions created on
+// or after midnight (local time) of that day are excluded.
+func ParseBeforeDate(before string) (time.Time, error) {
+	cutoff, err := time.ParseInLocation(beforeDateFormat, strings.TrimSpace(before), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s': expected YYYY-MM-DD", before)
+	}
+	return cutoff, nil
+}
+
+// FindVersion returns the path of a backup version given either a path to the
+// version itself or a selector understood by SelectVersion.
+func FindVersion(baseBackupPath, selector, before string) (path string, isZip bool, err error) {
+	if selector != "" && before == "" {
+		if info, statErr := Fs.Stat(selector); statErr == nil {
+			if info.IsDir() {
+				return selector, false, nil
+			}
+			if strings.HasSuffix(selector, ".zip") {
+				return selector, true, nil
+			}
+		}
+	}
+
+	v, err := SelectVersion(baseBackupPath, selector, before)
+	if err != nil {
+		return "", false, err
+	}
+	return v.Path, v.IsZip, nil
+}
@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createTestVersions(t *testing.T, names ...string) string {
+	t.Helper()
+	setupBackupTestDependencies()
+	backupDir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(backupDir, name)
+		if filepath.Ext(name) == ".zip" {
+			createDummyFile(t, path, "")
+			continue
+		}
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create version %s: %v", name, err)
+		}
+	}
+	return backupDir
+}
+
+func TestListVersions(t *testing.T) {
+	backupDir := createTestVersions(t, "20260101-090000", "20260301-090000.zip", "20260201-090000", "not-a-version", "notes.txt")
+
+	versions, err := ListVersions(backupDir)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	want := []string{"20260301-090000", "20260201-090000", "20260101-090000"}
+	if len(versions) != len(want) {
+		t.Fatalf("Got %d versions, want %d: %+v", len(versions), len(want), versions)
+	}
+	for i, name := range want {
+		if versions[i].Name != name {
+			t.Errorf("versions[%d] = %s, want %s", i, versions[i].Name, name)
+		}
+	}
+	if !versions[0].IsZip || versions[0].Path != filepath.Join(backupDir, "20260301-090000.zip") {
+		t.Errorf("Unexpected zip version: %+v", versions[0])
+	}
+}
+
+func TestSelectVersion(t *testing.T) {
+	backupDir := createTestVersions(t, "20260101-090000", "20260201-090000.zip", "20260301-090000", "20260901-000000")
+
+	tests := []struct {
+		name      string
+		selector  string
+		before    string
+		want      string
+		expectErr bool
+	}{
+		{name: "default is latest", want: "20260901-000000"},
+		{name: "latest", selector: "latest", want: "20260901-000000"},
+		{name: "latest~0", selector: "latest~0", want: "20260901-000000"},
+		{name: "latest~2", selector: "latest~2", want: "20260201-090000"},
+		{name: "exact timestamp", selector: "20260101-090000", want: "20260101-090000"},
+		{name: "exact zip name", selector: "20260201-090000.zip", want: "20260201-090000"},
+		{name: "before date excludes that day", before: "2026-09-01", want: "20260301-090000"},
+		{name: "before combined with latest~N", selector: "latest~1", before: "2026-09-01", want: "20260201-090000"},
+		{name: "exact timestamp outside before", selector: "20260901-000000", before: "2026-09-01", expectErr: true},
+		{name: "latest~N out of range", selector: "latest~4", expectErr: true},
+		{name: "negative offset", selector: "latest~-1", expectErr: true},
+		{name: "unknown timestamp", selector: "20250101-090000", expectErr: true},
+		{name: "garbage selector", selector: "yesterday", expectErr: true},
+		{name: "nothing before date", before: "2025-01-01", expectErr: true},
+		{name: "malformed date", before: "2026/09/01", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := SelectVersion(backupDir, tt.selector, tt.before)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected error, got version %s", v.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectVersion failed: %v", err)
+			}
+			if v.Name != tt.want {
+				t.Errorf("SelectVersion(%q, %q) = %s, want %s", tt.selector, tt.before, v.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindVersion(t *testing.T) {
+	backupDir, versionPath, _ := createVerifyTestBackup(t, true, "")
+	name := filepath.Base(versionPath)
+
+	for _, query := range []string{"", name, name[:len(name)-len(".zip")], versionPath} {
+		path, isZip, err := FindVersion(backupDir, query, "")
+		if err != nil {
+			t.Fatalf("FindVersion(%q) failed: %v", query, err)
+		}
+		if path != versionPath || !isZip {
+			t.Errorf("FindVersion(%q) = %s (zip=%v), want %s", query, path, isZip, versionPath)
+		}
+	}
+
+	if _, _, err := FindVersion(backupDir, "19990101-000000", ""); err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+}
+
+func TestProcess_RestoreSpecificVersion(t *testing.T) {
+	configDir, backupDir, homeDir := setupLayoutTest(t)
+
+	settings := filepath.Join(homeDir, ".settings")
+	createDummyFile(t, filepath.Join(configDir, "app.cfg"), `[application]
+name = App
+[configuration_files]
+.settings
+`)
+
+	// Two versions with distinct timestamps: a known-good one and a bad one.
+	for _, v := range []struct{ name, content string }{
+		{"20260101-090000", "good"},
+		{"20260102-090000", "bad"},
+	} {
+		createDummyFile(t, filepath.Join(backupDir, v.name, "App", ".settings"), v.content)
+	}
+
+	restore := func(opts Options) string {
+		opts.ConfigFolder = configDir
+		opts.BackupFolder = backupDir
+		Process(opts)
+		data, err := os.ReadFile(settings)
+		if err != nil {
+			t.Fatalf("Failed to read restored file: %v", err)
+		}
+		return string(data)
+	}
+
+	if got := restore(Options{}); got != "bad" {
+		t.Errorf("Default restore = %q, want the latest version", got)
+	}
+	if got := restore(Options{Version: "latest~1"}); got != "good" {
+		t.Errorf("latest~1 restore = %q, want 'good'", got)
+	}
+	if got := restore(Options{Version: "20260102-090000"}); got != "bad" {
+		t.Errorf("Exact restore = %q, want 'bad'", got)
+	}
+	if got := restore(Options{Before: "2026-01-02"}); got != "good" {
+		t.Errorf("Before restore = %q, want 'good'", got)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings" // Needed for TestCleanupOldVersions_Mixed
 	"testing"
 	"time" // Needed for TestProcessConfiguration_ZipRestore setup
@@ -44,7 +45,7 @@ func verifyZipContent(t *testing.T, zipPath string, expectedFiles map[string]str
 
 	foundFiles := make(map[string]string)
 	for _, f := range r.File {
-		if f.FileInfo().IsDir() {
+		if f.FileInfo().IsDir() || f.Name == ManifestFileName {
 			// Optionally verify directory structure if needed
 			continue
 		}
@@ -137,82 +138,6 @@ func TestCreateZipArchive(t *testing.T) {
 	}
 }
 
-func TestExtractFromZip(t *testing.T) {
-	tempDir := t.TempDir()
-
-	// --- Setup: Create a test zip file ---
-	srcDir := filepath.Join(tempDir, "source_for_zip")
-	zipPath := filepath.Join(tempDir, "test_archive.zip")
-	createDummyFile(t, filepath.Join(srcDir, "app1", "config.txt"), "app1_content")
-	createDummyFile(t, filepath.Join(srcDir, "app1", "data", "file.dat"), "app1_data")
-	createDummyFile(t, filepath.Join(srcDir, "app2", "settings.json"), "app2_settings")
-	if err := os.MkdirAll(filepath.Join(srcDir, "app1", "empty_dir"), 0755); err != nil {
-		t.Fatalf("Setup failed: Cannot create empty_dir: %v", err)
-	}
-
-	err := createZipArchive(srcDir, zipPath)
-	if err != nil {
-		t.Fatalf("Setup failed: Cannot create test zip archive: %v", err)
-	}
-	// --- End Setup ---
-
-	// Test case 1: Extract single file
-	extractDest1 := filepath.Join(tempDir, "extract1")
-	entryPath1 := "app1/config.txt"
-	destPath1 := filepath.Join(extractDest1, "my_config.txt")
-	err = extractFromZip(zipPath, entryPath1, destPath1)
-	if err != nil {
-		t.Fatalf("Test case 1 failed: extractFromZip returned error: %v", err)
-	}
-	content1, err := os.ReadFile(destPath1)
-	if err != nil {
-		t.Fatalf("Test case 1 failed: Cannot read extracted file: %v", err)
-	}
-	if string(content1) != "app1_content" {
-		t.Errorf("Test case 1 failed: Content mismatch. Expected 'app1_content', got '%s'", string(content1))
-	}
-
-	// Test case 2: Extract directory
-	extractDest2 := filepath.Join(tempDir, "extract2")
-	entryPath2 := "app1"
-	destPath2 := filepath.Join(extractDest2, "restored_app1")
-	err = extractFromZip(zipPath, entryPath2, destPath2)
-	if err != nil {
-		t.Fatalf("Test case 2 failed: extractFromZip returned error: %v", err)
-	}
-	// Verify extracted files within the directory
-	content2a, err := os.ReadFile(filepath.Join(destPath2, "config.txt"))
-	if err != nil || string(content2a) != "app1_content" {
-		t.Errorf("Test case 2 failed: config.txt content mismatch or read error: %v", err)
-	}
-	content2b, err := os.ReadFile(filepath.Join(destPath2, "data", "file.dat"))
-	if err != nil || string(content2b) != "app1_data" {
-		t.Errorf("Test case 2 failed: data/file.dat content mismatch or read error: %v", err)
-	}
-	// Verify empty directory was created
-	if _, err := os.Stat(filepath.Join(destPath2, "empty_dir")); os.IsNotExist(err) {
-		t.Errorf("Test case 2 failed: empty_dir was not extracted")
-	}
-
-	// Test case 3: Extract non-existent entry
-	extractDest3 := filepath.Join(tempDir, "extract3")
-	entryPath3 := "app3/nonexistent.cfg"
-	destPath3 := filepath.Join(extractDest3, "wont_be_created.cfg")
-	err = extractFromZip(zipPath, entryPath3, destPath3)
-	if err != nil {
-		t.Fatalf("Test case 3 failed: extractFromZip returned error for non-existent entry: %v", err)
-	}
-	if _, err := os.Stat(destPath3); !os.IsNotExist(err) {
-		t.Errorf("Test case 3 failed: Destination file %s was created for non-existent entry", destPath3)
-	}
-
-	// Test case 4: Extract from non-existent zip file (should error)
-	err = extractFromZip(filepath.Join(tempDir, "nosuch.zip"), "any", "anywhere")
-	if err == nil {
-		t.Fatalf("Test case 4 failed: Expected error for non-existent zip file, got nil")
-	}
-}
-
 func TestGetLatestVersionPath_Mixed(t *testing.T) {
 	// Use real filesystem for this test
 	setupBackupTestDependencies() // Sets up util.Fs = OsFileSystem
@@ -400,6 +325,7 @@ name = MyZipApp
 	if err != nil {
 		t.Fatalf("Failed to read backup directory: %v", err)
 	}
+	entries = slices.DeleteFunc(entries, func(e os.DirEntry) bool { return e.Name() == lockFileName })
 	if len(entries) != 1 {
 		t.Fatalf("Expected 1 entry in backup dir, found %d", len(entries))
 	}
@@ -493,206 +419,3 @@ name = %s
 		t.Errorf("Restore failed: Content mismatch. Expected '%s', got '%s'", sourceFileContent, string(restoredContent))
 	}
 }
-
-// TestExtractFromZip_SymlinkAttack tests that extractFromZip properly rejects
-// symlink-based Zip Slip attacks
-func TestExtractFromZip_SymlinkAttack(t *testing.T) {
-	tempDir := t.TempDir()
-
-	// Create a safe directory outside the extraction destination
-	outsideDir := filepath.Join(tempDir, "outside_target")
-	if err := os.MkdirAll(outsideDir, 0755); err != nil {
-		t.Fatalf("Failed to create outside directory: %v", err)
-	}
-
-	// Create a malicious zip file containing a symlink pointing outside the destination
-	zipPath := filepath.Join(tempDir, "malicious.zip")
-	zipFile, err := os.Create(zipPath)
-	if err != nil {
-		t.Fatalf("Failed to create test zip file: %v", err)
-	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-
-	// Attack vector: Create a symlink that points outside the extraction directory
-	// The symlink will be created as "evil_link" pointing to the outside directory
-	symlinkHeader := &zip.FileHeader{
-		Name: "evil_link",
-	}
-	symlinkHeader.SetMode(os.ModeSymlink | 0755)
-	symlinkWriter, err := zipWriter.CreateHeader(symlinkHeader)
-	if err != nil {
-		t.Fatalf("Failed to create symlink header: %v", err)
-	}
-	// The "content" of a symlink entry is the target path (relative to extraction point)
-	// Point to the outside directory using absolute path
-	if _, err := symlinkWriter.Write([]byte(outsideDir)); err != nil {
-		t.Fatalf("Failed to write symlink target: %v", err)
-	}
-
-	// Create a file that would be written through the symlink
-	// This file will appear to be "evil_link/payload.txt" in the zip
-	// But when extracted, if symlinks are followed, it would write to outside_target/payload.txt
-	fileHeader := &zip.FileHeader{
-		Name: "evil_link/payload.txt",
-	}
-	fileHeader.SetMode(0644)
-	fileWriter, err := zipWriter.CreateHeader(fileHeader)
-	if err != nil {
-		t.Fatalf("Failed to create file header: %v", err)
-	}
-	if _, err := fileWriter.Write([]byte("MALICIOUS PAYLOAD")); err != nil {
-		t.Fatalf("Failed to write file content: %v", err)
-	}
-
-	if err := zipWriter.Close(); err != nil {
-		t.Fatalf("Failed to close zip writer: %v", err)
-	}
-	if err := zipFile.Close(); err != nil {
-		t.Fatalf("Failed to close zip file: %v", err)
-	}
-
-	// Try to extract - this should fail after the fix
-	extractDest := filepath.Join(tempDir, "extract_dest")
-	if err := os.MkdirAll(extractDest, 0755); err != nil {
-		t.Fatalf("Failed to create extraction destination: %v", err)
-	}
-
-	// Test: Attempt to extract the evil_link directory (which contains the symlink and file)
-	err = extractFromZip(zipPath, "evil_link", extractDest)
-
-	t.Logf("Extraction result: err=%v", err)
-	t.Logf("extractDest: %s", extractDest)
-	t.Logf("outsideDir: %s", outsideDir)
-
-	// Check what was actually extracted
-	extractedSymlink := filepath.Join(extractDest, "evil_link")
-	if symlinkInfo, statErr := os.Lstat(extractedSymlink); statErr == nil {
-		t.Logf("Symlink was extracted: %s, mode=%v", extractedSymlink, symlinkInfo.Mode())
-		if symlinkInfo.Mode()&os.ModeSymlink != 0 {
-			target, linkErr := os.Readlink(extractedSymlink)
-			t.Logf("Symlink target: %s (err=%v)", target, linkErr)
-		}
-	} else {
-		t.Logf("Symlink was NOT extracted (stat error: %v)", statErr)
-	}
-
-	// Check if the attack succeeded by seeing if file was written outside extractDest
-	outsidePayload := filepath.Join(outsideDir, "payload.txt")
-	if _, statErr := os.Stat(outsidePayload); statErr == nil {
-		// The attack succeeded - file was written outside the extraction directory
-		content, readErr := os.ReadFile(outsidePayload)
-		if readErr == nil && string(content) == "MALICIOUS PAYLOAD" {
-			t.Errorf("VULNERABILITY: Symlink attack succeeded! File written outside extraction directory at %s", outsidePayload)
-			t.Errorf("Extraction should have failed or rejected the symlink")
-		}
-	} else {
-		t.Logf("Outside payload does not exist (good): %v", statErr)
-	}
-
-	// After proper fix: extraction should return an error detecting symlink outside destination
-	// For now, we document the expected behavior
-	if err != nil {
-		// Check if it's the right kind of error
-		if !strings.Contains(err.Error(), "symlink") && !strings.Contains(err.Error(), "outside") {
-			t.Logf("Extraction failed with: %v", err)
-		}
-	}
-}
-
-// TestExtractFromZip_PathTraversal tests that extractFromZip properly rejects
-// basic path traversal attempts (existing Zip Slip protection)
-func TestExtractFromZip_PathTraversal(t *testing.T) {
-	tempDir := t.TempDir()
-
-	// Create a directory structure within a zip
-	srcDir := filepath.Join(tempDir, "source")
-	zipPath := filepath.Join(tempDir, "traversal.zip")
-
-	// Create the structure: app/../../outside.txt
-	createDummyFile(t, filepath.Join(srcDir, "app", "config.txt"), "safe content")
-
-	// Now we'll manually modify the zip to add a path traversal entry
-	if err := createZipArchive(srcDir, zipPath); err != nil {
-		t.Fatalf("Failed to create initial zip: %v", err)
-	}
-
-	// Re-open and add malicious entry
-	zipFile, err := os.OpenFile(zipPath, os.O_RDWR, 0644)
-	if err != nil {
-		t.Fatalf("Failed to open zip for modification: %v", err)
-	}
-
-	// Read existing content
-	zipInfo, err := zipFile.Stat()
-	if err != nil {
-		t.Fatalf("Failed to stat zip: %v", err)
-	}
-	zipBytes := make([]byte, zipInfo.Size())
-	if _, err := zipFile.ReadAt(zipBytes, 0); err != nil && err != io.EOF {
-		t.Fatalf("Failed to read zip: %v", err)
-	}
-	zipFile.Close()
-
-	// Create new zip with malicious entry
-	zipFile, err = os.Create(zipPath)
-	if err != nil {
-		t.Fatalf("Failed to recreate zip: %v", err)
-	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-
-	// Add the normal entry
-	normalHeader := &zip.FileHeader{
-		Name: "app/config.txt",
-	}
-	normalHeader.SetMode(0644)
-	normalWriter, err := zipWriter.CreateHeader(normalHeader)
-	if err != nil {
-		t.Fatalf("Failed to create normal header: %v", err)
-	}
-	if _, err := normalWriter.Write([]byte("safe content")); err != nil {
-		t.Fatalf("Failed to write normal content: %v", err)
-	}
-
-	// Add malicious entry with path traversal
-	maliciousHeader := &zip.FileHeader{
-		Name: "app/../../outside.txt",
-	}
-	maliciousHeader.SetMode(0644)
-	maliciousWriter, err := zipWriter.CreateHeader(maliciousHeader)
-	if err != nil {
-		t.Fatalf("Failed to create malicious header: %v", err)
-	}
-	if _, err := maliciousWriter.Write([]byte("malicious content")); err != nil {
-		t.Fatalf("Failed to write malicious content: %v", err)
-	}
-
-	if err := zipWriter.Close(); err != nil {
-		t.Fatalf("Failed to close zip writer: %v", err)
-	}
-
-	// Try to extract the app directory - this should fail when encountering the traversal
-	extractDest := filepath.Join(tempDir, "extract_dest")
-	if err := os.MkdirAll(extractDest, 0755); err != nil {
-		t.Fatalf("Failed to create extraction destination: %v", err)
-	}
-
-	// Test: Attempt to extract - should fail due to path traversal
-	err = extractFromZip(zipPath, "app", extractDest)
-
-	// Should return an error detecting path outside destination
-	if err == nil {
-		t.Errorf("Expected error when extracting directory with path traversal entry, got nil")
-
-		// Check if file was created outside the destination
-		outsidePath := filepath.Join(tempDir, "outside.txt")
-		if _, statErr := os.Stat(outsidePath); statErr == nil {
-			t.Errorf("Path traversal attack succeeded: file created at %s", outsidePath)
-		}
-	} else if !strings.Contains(err.Error(), "outside of destination") && !strings.Contains(err.Error(), "invalid file path") {
-		t.Errorf("Expected error message about 'outside of destination', got: %v", err)
-	}
-}
@@ -4,6 +4,9 @@ import (
 	"SettingsSentry/interfaces"
 	"SettingsSentry/logger"
 	"SettingsSentry/pkg/printer"
+	"bytes"
+	"errors"
+	"fmt"
 	"runtime/debug"
 )
 
@@ -27,19 +30,25 @@ func SafeExecute(operation string, fn func() error) error {
 }
 
 func ExecuteCommandLine(commandLine string) bool {
+	return RunCommandLine(commandLine, interfaces.CommandOptions{}) == nil
+}
+
+// RunCommandLine runs a command with the given options, printing its output
+// line by line. It returns an error when the command fails or times out.
+func RunCommandLine(commandLine string, opts interfaces.CommandOptions) error {
 	if commandLine == "" {
 		if Printer != nil {
 			Printer.Print("No command provided")
 		}
 		// No command is not an error
-		return true
+		return nil
 	}
 
 	if CmdExecutor == nil {
 		if AppLogger != nil {
-			AppLogger.Logf("Error: Command executor is not initialized.")
+			AppLogger.Errorf("Command executor is not initialized.")
 		}
-		return false
+		return errors.New("command executor is not initialized")
 	}
 
 	stdoutHandler := func(line string) {
@@ -54,20 +63,35 @@ func ExecuteCommandLine(commandLine string) bool {
 		}
 	}
 
-	result := CmdExecutor.ExecuteWithCallback(commandLine, stdoutHandler, stderrHandler)
+	err := CmdExecutor.ExecuteWithOptions(commandLine, opts, stdoutHandler, stderrHandler)
 
-	if !result {
+	if err != nil {
+		message := fmt.Sprintf("Error executing command: %s", commandLine)
+		if errors.Is(err, interfaces.ErrCommandTimeout) {
+			message = fmt.Sprintf("Command timed out after %s and was killed: %s", opts.Timeout, commandLine)
+		}
 		if Printer != nil {
-			Printer.Print("Error executing command: %s", commandLine)
+			Printer.Print("%s", message)
 		} else if AppLogger != nil {
-			AppLogger.Logf("Error executing command: %s", commandLine)
+			AppLogger.Logf("%s", message)
 		}
-		return false
+		return err
 	}
 
 	if Printer != nil {
 		Printer.Print("Command executed: %s", commandLine)
 	}
 
-	return true
+	return nil
+}
+
+// CaptureCommandLine runs a command like RunCommandLine but returns its
+// standard output instead of printing it.
+func CaptureCommandLine(commandLine string, opts interfaces.CommandOptions) ([]byte, error) {
+	var stdout bytes.Buffer
+	opts.Stdout = &stdout
+	if err := RunCommandLine(commandLine, opts); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
 }
@@ -167,3 +167,10 @@ func (m *MockCommandExecutor) ExecuteWithCallback(commandLine string, stdoutHand
 	
 	return m.shouldSucceed
 }
+
+func (m *MockCommandExecutor) ExecuteWithOptions(commandLine string, opts interfaces.CommandOptions, stdoutHandler, stderrHandler interfaces.OutputHandler) error {
+	if !m.ExecuteWithCallback(commandLine, stdoutHandler, stderrHandler) {
+		return errors.New("command failed")
+	}
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+	"unicode"
 )
 
 var (
@@ -28,10 +30,53 @@ var GetHomeDirectory = func() (string, error) {
 type Config struct {
 	Name                string
 	Files               []string
+	Excludes            []string
+	CommandOutputs      []CommandOutput
 	PreBackupCommands   []string
 	PostBackupCommands  []string
 	PreRestoreCommands  []string
 	PostRestoreCommands []string
+	OnHookFailure       string
+}
+
+// Policies for failed commands, set per app with on_hook_failure in the
+// [application] section or for the whole run with -on-hook-failure.
+const (
+	// HookFailureContinue logs the failure and carries on with the app.
+	HookFailureContinue = "continue"
+	// HookFailureSkipApp skips the rest of the app. This is the default.
+	HookFailureSkipApp = "skip-app"
+	// HookFailureAbortRun stops the whole run after the failing app.
+	HookFailureAbortRun = "abort-run"
+)
+
+// ValidHookFailurePolicy reports whether policy is a known hook failure policy.
+func ValidHookFailurePolicy(policy string) bool {
+	switch policy {
+	case HookFailureContinue, HookFailureSkipApp, HookFailureAbortRun:
+		return true
+	}
+	return false
+}
+
+// CommandOutput is a command whose standard output is backed up as a file
+// called Name. RestoreCommand, when set, is fed the stored content on standard
+// input on restore.
+type CommandOutput struct {
+	Name           string
+	Command        string
+	RestoreCommand string
+}
+
+// commandOutput returns the command output called name, adding it if needed.
+func (c *Config) commandOutput(name string) *CommandOutput {
+	for i := range c.CommandOutputs {
+		if c.CommandOutputs[i].Name == name {
+			return &c.CommandOutputs[i]
+		}
+	}
+	c.CommandOutputs = append(c.CommandOutputs, CommandOutput{Name: name})
+	return &c.CommandOutputs[len(c.CommandOutputs)-1]
 }
 
 func GetXDGConfigHome() (string, error) {
@@ -93,6 +138,111 @@ func ExpandEnvVars(value string) string {
 	return result
 }
 
+// DefaultCommandTimeout is the timeout of config commands without a @timeout
+// option, so a hanging command cannot stall an unattended run forever.
+const DefaultCommandTimeout = time.Hour
+
+// ParseCommand splits a config command into the command line and its options.
+// Options are written before the command as whitespace separated tokens:
+//
+//	@timeout=10m @cwd=~/dotfiles @env=HOMEBREW_NO_AUTO_UPDATE=1 brew bundle dump
+//
+// @timeout takes a duration, 0 disabling the timeout. @cwd is resolved like
+// configuration paths, relative to the home directory. @env may be repeated.
+// Values containing spaces are quoted as in a shell, e.g. @cwd="~/My Folder".
+func ParseCommand(line string) (string, interfaces.CommandOptions, error) {
+	opts := interfaces.CommandOptions{Timeout: DefaultCommandTimeout}
+	rest := strings.TrimSpace(line)
+
+	for strings.HasPrefix(rest, "@") {
+		token, remainder, err := nextCommandOption(rest)
+		if err != nil {
+			return "", opts, err
+		}
+		rest = strings.TrimSpace(remainder)
+
+		key, value, ok := strings.Cut(token[1:], "=")
+		if !ok || value == "" {
+			return "", opts, fmt.Errorf("command option %s needs a value", token)
+		}
+		switch key {
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout < 0 {
+				return "", opts, fmt.Errorf("invalid command timeout %q: use a duration like 30s or 10m", value)
+			}
+			opts.Timeout = timeout
+		case "cwd":
+			dir, err := resolveCommandDir(value)
+			if err != nil {
+				return "", opts, err
+			}
+			opts.Dir = dir
+		case "env":
+			name, _, ok := strings.Cut(value, "=")
+			if !ok || name == "" {
+				return "", opts, fmt.Errorf("invalid command environment %q: use @env=NAME=value", value)
+			}
+			opts.Env = append(opts.Env, value)
+		default:
+			return "", opts, fmt.Errorf("unknown command option %s", token)
+		}
+	}
+
+	if rest == "" {
+		return "", opts, fmt.Errorf("command options without a command: %s", line)
+	}
+	return rest, opts, nil
+}
+
+// nextCommandOption reads the option token at the start of a command and
+// returns it unquoted together with the rest of the command. Tokens end at
+// unquoted whitespace. Single quotes keep their content literally; inside
+// double quotes a backslash escapes a double quote or a backslash.
+func nextCommandOption(s string) (string, string, error) {
+	var token strings.Builder
+	var quote rune
+	escaped := false
+	for i, r := range s {
+		switch {
+		case escaped:
+			token.WriteRune(r)
+			escaped = false
+		case quote == '"' && r == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\'):
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			token.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+		case unicode.IsSpace(r):
+			return token.String(), s[i:], nil
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return "", "", fmt.Errorf("unterminated quote in command option: %s", s)
+	}
+	return token.String(), "", nil
+}
+
+// resolveCommandDir resolves the @cwd of a command against the home directory.
+func resolveCommandDir(dir string) (string, error) {
+	if filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	homeDir, err := GetHomeDirectory()
+	if err != nil {
+		return "", err
+	}
+	if dir == "~" {
+		return homeDir, nil
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(dir, "~/")), nil
+}
+
 func ValidateConfig(config Config) error {
 	if Fs == nil {
 		return errors.New("filesystem interface (Fs) is not initialized")
@@ -101,8 +251,37 @@ func ValidateConfig(config Config) error {
 		return errors.New("application name is required in configuration")
 	}
 
-	if len(config.Files) == 0 {
-		return errors.New("at least one configuration file must be specified")
+	if config.OnHookFailure != "" && !ValidHookFailurePolicy(config.OnHookFailure) {
+		return fmt.Errorf("invalid on_hook_failure %q: use continue, skip-app or abort-run", config.OnHookFailure)
+	}
+
+	if len(config.Files) == 0 && len(config.CommandOutputs) == 0 {
+		return errors.New("at least one configuration file or command output must be specified")
+	}
+
+	for _, output := range config.CommandOutputs {
+		if output.Name == "." || output.Name == ".." || strings.ContainsAny(output.Name, "/\\") {
+			return fmt.Errorf("invalid command output name %q: must be a plain file name", output.Name)
+		}
+		if output.Command == "" {
+			return fmt.Errorf("command input %q has no matching entry in [command_outputs]", output.Name)
+		}
+		for _, command := range []string{output.Command, output.RestoreCommand} {
+			if command == "" {
+				continue
+			}
+			if _, _, err := ParseCommand(command); err != nil {
+				return fmt.Errorf("invalid command %q: %w", command, err)
+			}
+		}
+	}
+
+	for _, commands := range [][]string{config.PreBackupCommands, config.PostBackupCommands, config.PreRestoreCommands, config.PostRestoreCommands} {
+		for _, command := range commands {
+			if _, _, err := ParseCommand(command); err != nil {
+				return fmt.Errorf("invalid command %q: %w", command, err)
+			}
+		}
 	}
 
 	for _, file := range config.Files {
@@ -172,6 +351,10 @@ func ParseConfig(filesystem iofs.FS, filePath string) (Config, error) {
 				config.Name = value
 				continue
 			}
+			if section == "application" && key == "on_hook_failure" {
+				config.OnHookFailure = strings.ToLower(value)
+				continue
+			}
 		}
 
 		switch section {
@@ -214,6 +397,23 @@ func ParseConfig(filesystem iofs.FS, filePath string) (Config, error) {
 			relativePath := strings.Replace(fullPath, homeDir+"/", "", 1)
 
 			config.Files = append(config.Files, relativePath)
+		case "exclude", "excluded_files":
+			config.Excludes = append(config.Excludes, ExpandEnvVars(line))
+		case "command_outputs", "command_inputs":
+			name, commandLine, ok := strings.Cut(line, "=")
+			name, commandLine = strings.TrimSpace(name), strings.TrimSpace(commandLine)
+			if !ok || name == "" || commandLine == "" {
+				if AppLogger != nil {
+					return config, AppLogger.LogErrorf("invalid %s entry %q: use name = command", section, line)
+				}
+				return config, errors.New("invalid command output entry")
+			}
+			output := config.commandOutput(name)
+			if section == "command_outputs" {
+				output.Command = ExpandEnvVars(commandLine)
+			} else {
+				output.RestoreCommand = ExpandEnvVars(commandLine)
+			}
 		case "backup", "backup_commands", "pre_backup_commands":
 			config.PreBackupCommands = append(config.PreBackupCommands, ExpandEnvVars(line))
 		case "post_backup_commands":
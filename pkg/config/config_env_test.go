@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestExpandEnvVars(t *testing.T) {
@@ -246,6 +247,10 @@ func (m *mockFileSystem) Abs(path string) (string, error) {
 	panic("unimplemented")
 }
 
+func (m *mockFileSystem) Remove(name string) error {
+	panic("unimplemented")
+}
+
 func (m *mockFileSystem) RemoveAll(path string) error {
 	panic("unimplemented")
 }
@@ -282,6 +287,10 @@ func (m *mockFileSystem) Create(name string) (interfaces.File, error) {
 	return nil, nil
 }
 
+func (m *mockFileSystem) OpenFile(name string, flag int, perm os.FileMode) (interfaces.File, error) {
+	return nil, nil
+}
+
 func (m *mockFileSystem) OpenIOFS(name string) (iofs.File, error) {
 	// Use readFileFunc to get content for the mock file
 	content, err := m.ReadFile(name)
@@ -309,6 +318,34 @@ func (m *mockFileSystem) EvalSymlinks(path string) (string, error) {
 	return path, nil
 }
 
+func (m *mockFileSystem) Lstat(path string) (os.FileInfo, error) {
+	return m.statFunc(path)
+}
+
+func (m *mockFileSystem) Readlink(name string) (string, error) {
+	return "", fmt.Errorf("mock Readlink not implemented/needed for this test")
+}
+
+func (m *mockFileSystem) Symlink(oldname, newname string) error {
+	panic("unimplemented")
+}
+
+func (m *mockFileSystem) Link(oldname, newname string) error {
+	panic("unimplemented")
+}
+
+func (m *mockFileSystem) Rename(oldpath, newpath string) error {
+	panic("unimplemented")
+}
+
+func (m *mockFileSystem) Chmod(name string, mode os.FileMode) error {
+	panic("unimplemented")
+}
+
+func (m *mockFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	panic("unimplemented")
+}
+
 type mockFSAdapter struct {
 	mock *mockFileSystem
 }
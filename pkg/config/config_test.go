@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func setupTestDependencies() {
@@ -371,6 +372,7 @@ func TestParseConfig_Sections(t *testing.T) {
 		expectedPostBkp int
 		expectedPreRst  int
 		expectedPostRst int
+		expectedExcludes int
 	}{
 		{
 			name: "all sections",
@@ -442,6 +444,25 @@ echo restore command
 			expectedFiles:  1,
 			expectedPreRst: 1,
 		},
+		{
+			name: "exclude sections",
+			configContent: `[application]
+name = ExcludeApp
+
+[configuration_files]
+.config/app
+
+[exclude]
+*.log
+Cache
+
+[excluded_files]
+.config/app/state/**
+`,
+			expectedName:     "ExcludeApp",
+			expectedFiles:    1,
+			expectedExcludes: 3,
+		},
 	}
 
 	for _, tt := range tests {
@@ -476,6 +497,9 @@ echo restore command
 			if len(config.PostRestoreCommands) != tt.expectedPostRst {
 				t.Errorf("PostRestoreCommands count = %d, want %d", len(config.PostRestoreCommands), tt.expectedPostRst)
 			}
+			if len(config.Excludes) != tt.expectedExcludes {
+				t.Errorf("Excludes count = %d, want %d", len(config.Excludes), tt.expectedExcludes)
+			}
 		})
 	}
 }
@@ -1042,3 +1066,97 @@ name = TestApp
 		t.Errorf("Name = %q, want 'TestApp'", config.Name)
 	}
 }
+
+func TestParseCommand(t *testing.T) {
+	originalGetHomeDir := GetHomeDirectory
+	GetHomeDirectory = func() (string, error) { return "/Users/test", nil }
+	defer func() { GetHomeDirectory = originalGetHomeDir }()
+
+	commandLine, opts, err := ParseCommand("brew bundle dump --force")
+	if err != nil || commandLine != "brew bundle dump --force" {
+		t.Fatalf("ParseCommand = %q, %v", commandLine, err)
+	}
+	if opts.Timeout != DefaultCommandTimeout || opts.Dir != "" || len(opts.Env) != 0 {
+		t.Errorf("Unexpected default options: %+v", opts)
+	}
+
+	commandLine, opts, err = ParseCommand("@timeout=10m @cwd=~/dotfiles @env=A=1 @env=B=x=y make install")
+	if err != nil || commandLine != "make install" {
+		t.Fatalf("ParseCommand = %q, %v", commandLine, err)
+	}
+	if opts.Timeout != 10*time.Minute || opts.Dir != "/Users/test/dotfiles" || len(opts.Env) != 2 || opts.Env[1] != "B=x=y" {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	if _, opts, err = ParseCommand("@timeout=0 @cwd=/opt/app ./run"); err != nil || opts.Timeout != 0 || opts.Dir != "/opt/app" {
+		t.Errorf("Unexpected result: %+v, %v", opts, err)
+	}
+
+	commandLine, opts, err = ParseCommand("@timeout=5s\t@cwd=\"~/My Projects\"  @env='GREETING=hello world' @env=QUOTE=\"say \\\"hi\\\"\"\techo  \"$GREETING\"")
+	if err != nil || commandLine != "echo  \"$GREETING\"" {
+		t.Fatalf("ParseCommand = %q, %v", commandLine, err)
+	}
+	if opts.Timeout != 5*time.Second || opts.Dir != "/Users/test/My Projects" || len(opts.Env) != 2 || opts.Env[0] != "GREETING=hello world" || opts.Env[1] != `QUOTE=say "hi"` {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	for _, invalid := range []string{
+		"@timeout=soon echo",
+		"@timeout=-1s echo",
+		"@env=noequals echo",
+		"@user=root echo",
+		"@cwd= echo",
+		"@cwd=\"\" echo",
+		"@cwd=\"~/My Projects echo",
+		"@timeout=5s",
+	} {
+		if _, _, err := ParseCommand(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseConfig_CommandOutputs(t *testing.T) {
+	setupTestDependencies()
+
+	tempDir := t.TempDir()
+	configContent := `[application]
+name = VSCode
+
+[command_outputs]
+extensions = code --list-extensions
+settings = @timeout=1m defaults export com.microsoft.VSCode -
+
+[command_inputs]
+extensions = xargs -n 1 code --install-extension
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "vscode.cfg"), []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := ParseConfig(os.DirFS(tempDir), "vscode.cfg")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	expected := []CommandOutput{
+		{Name: "extensions", Command: "code --list-extensions", RestoreCommand: "xargs -n 1 code --install-extension"},
+		{Name: "settings", Command: "@timeout=1m defaults export com.microsoft.VSCode -"},
+	}
+	if fmt.Sprint(config.CommandOutputs) != fmt.Sprint(expected) {
+		t.Errorf("CommandOutputs = %+v, want %+v", config.CommandOutputs, expected)
+	}
+
+	invalid := map[string]string{
+		"missing output":  "[application]\nname = A\n[command_inputs]\nlist = cat\n",
+		"path as name":    "[application]\nname = A\n[command_outputs]\n../list = ls\n",
+		"missing command": "[application]\nname = A\n[command_outputs]\nlist =\n",
+	}
+	for name, content := range invalid {
+		if err := os.WriteFile(filepath.Join(tempDir, "invalid.cfg"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		if _, err := ParseConfig(os.DirFS(tempDir), "invalid.cfg"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
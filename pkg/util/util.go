@@ -30,7 +30,7 @@ func EmbeddedFallback(rootEmbedFS embed.FS) iofs.FS {
 	if err != nil {
 		// Log the error but still return the root embed FS as a last resort.
 		if AppLogger != nil {
-			AppLogger.Logf("Failed to access embedded 'configs' subdirectory: %v. Falling back to root embed FS.", err)
+			AppLogger.Errorf("failed to access embedded 'configs' subdirectory: %v. Falling back to root embed FS.", err)
 		}
 		return rootEmbedFS // Return the original root FS on error
 	}
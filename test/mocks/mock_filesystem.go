@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -78,8 +79,9 @@ func (fi *MockFileInfo) Sys() interface{} {
 
 // MockDirEntry implements os.DirEntry for testing
 type MockDirEntry struct {
-	name  string
-	isDir bool
+	name    string
+	isDir   bool
+	symlink bool
 }
 
 func (e *MockDirEntry) Name() string {
@@ -94,6 +96,9 @@ func (e *MockDirEntry) Type() os.FileMode {
 	if e.isDir {
 		return os.ModeDir
 	}
+	if e.symlink {
+		return os.ModeSymlink
+	}
 	return 0
 }
 
@@ -111,6 +116,7 @@ type MockFileSystem struct {
 	files     map[string][]byte
 	dirs      map[string]bool
 	fileInfos map[string]*MockFileInfo
+	links     map[string]string
 	mu        sync.RWMutex
 }
 
@@ -120,6 +126,7 @@ func NewMockFileSystem() *MockFileSystem {
 		files:     make(map[string][]byte),
 		dirs:      make(map[string]bool),
 		fileInfos: make(map[string]*MockFileInfo),
+		links:     make(map[string]string),
 	}
 }
 
@@ -214,6 +221,11 @@ func (fs *MockFileSystem) Open(name string) (interfaces.File, error) {
 
 // Create creates a new file for writing
 func (fs *MockFileSystem) Create(name string) (interfaces.File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+// OpenFile creates or truncates a file for writing with the given permissions
+func (fs *MockFileSystem) OpenFile(name string, flag int, perm os.FileMode) (interfaces.File, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -226,12 +238,16 @@ func (fs *MockFileSystem) Create(name string) (interfaces.File, error) {
 		return nil, os.ErrNotExist
 	}
 
+	if _, exists := fs.files[name]; !exists && flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+
 	// Create a new empty file
 	fs.files[name] = []byte{}
 	fs.fileInfos[name] = &MockFileInfo{
 		name:    filepath.Base(name),
 		size:    0,
-		mode:    0644,
+		mode:    perm,
 		modTime: time.Now(),
 		isDir:   false,
 	}
@@ -273,6 +289,16 @@ func (fs *MockFileSystem) ReadDir(dirname string) ([]os.DirEntry, error) {
 		}
 	}
 
+	// Add all symbolic links in this directory
+	for link := range fs.links {
+		if filepath.Dir(link) == dirname {
+			entries = append(entries, &MockDirEntry{
+				name:    filepath.Base(link),
+				symlink: true,
+			})
+		}
+	}
+
 	// Add all directories in this directory
 	for dir := range fs.dirs {
 		parentDir := filepath.Dir(dir)
@@ -333,6 +359,7 @@ func (fs *MockFileSystem) RemoveAll(path string) error {
 	delete(fs.files, path)
 	delete(fs.dirs, path)
 	delete(fs.fileInfos, path)
+	delete(fs.links, path)
 
 	// Remove all children
 	prefix := path + "/"
@@ -350,16 +377,68 @@ func (fs *MockFileSystem) RemoveAll(path string) error {
 		}
 	}
 
+	for linkPath := range fs.links {
+		if strings.HasPrefix(linkPath, prefix) {
+			delete(fs.links, linkPath)
+		}
+	}
+
+	return nil
+}
+
+// Remove removes a file, a symbolic link or an empty directory
+func (fs *MockFileSystem) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Normalize path
+	name = filepath.Clean(name)
+	_, isFile := fs.files[name]
+	_, isDir := fs.dirs[name]
+	_, isLink := fs.links[name]
+	if !isFile && !isDir && !isLink {
+		return os.ErrNotExist
+	}
+
+	if isDir {
+		prefix := name + "/"
+		var children []string
+		for p := range fs.files {
+			children = append(children, p)
+		}
+		for p := range fs.dirs {
+			children = append(children, p)
+		}
+		for p := range fs.links {
+			children = append(children, p)
+		}
+		for _, p := range children {
+			if strings.HasPrefix(p, prefix) {
+				return syscall.ENOTEMPTY
+			}
+		}
+	}
+
+	delete(fs.files, name)
+	delete(fs.dirs, name)
+	delete(fs.fileInfos, name)
+	delete(fs.links, name)
 	return nil
 }
 
-// Stat returns file info
+// Stat returns file info, following symbolic links
 func (fs *MockFileSystem) Stat(name string) (os.FileInfo, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
-	// Normalize path
+	// Normalize path and follow symbolic links
 	name = filepath.Clean(name)
+	if target, isLink := fs.links[name]; isLink {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		name = filepath.Clean(target)
+	}
 
 	// Check if the file or directory exists
 	info, exists := fs.fileInfos[name]
@@ -370,6 +449,168 @@ func (fs *MockFileSystem) Stat(name string) (os.FileInfo, error) {
 	return info, nil
 }
 
+// Lstat returns file info without following symbolic links
+func (fs *MockFileSystem) Lstat(name string) (os.FileInfo, error) {
+	fs.mu.RLock()
+	name = filepath.Clean(name)
+	target, isLink := fs.links[name]
+	fs.mu.RUnlock()
+
+	if !isLink {
+		return fs.Stat(name)
+	}
+	return &MockFileInfo{
+		name:    filepath.Base(name),
+		size:    int64(len(target)),
+		mode:    os.ModeSymlink | 0777,
+		modTime: time.Now(),
+	}, nil
+}
+
+// Readlink returns the target of a symbolic link
+func (fs *MockFileSystem) Readlink(name string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	target, isLink := fs.links[filepath.Clean(name)]
+	if !isLink {
+		return "", os.ErrInvalid
+	}
+	return target, nil
+}
+
+// Symlink creates newname as a symbolic link to oldname
+func (fs *MockFileSystem) Symlink(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Normalize path
+	newname = filepath.Clean(newname)
+
+	// Check if the directory exists and the name is free
+	dir := filepath.Dir(newname)
+	if dir != "." && !fs.dirs[dir] {
+		return os.ErrNotExist
+	}
+	if _, exists := fs.fileInfos[newname]; exists {
+		return os.ErrExist
+	}
+	if _, exists := fs.links[newname]; exists {
+		return os.ErrExist
+	}
+
+	fs.links[newname] = oldname
+	return nil
+}
+
+// Link creates newname as a hard link to the file oldname
+func (fs *MockFileSystem) Link(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Normalize paths
+	oldname = filepath.Clean(oldname)
+	newname = filepath.Clean(newname)
+
+	content, exists := fs.files[oldname]
+	if !exists {
+		return os.ErrNotExist
+	}
+	dir := filepath.Dir(newname)
+	if dir != "." && !fs.dirs[dir] {
+		return os.ErrNotExist
+	}
+	if _, exists := fs.fileInfos[newname]; exists {
+		return os.ErrExist
+	}
+
+	info := *fs.fileInfos[oldname]
+	info.name = filepath.Base(newname)
+	fs.files[newname] = content
+	fs.fileInfos[newname] = &info
+	return nil
+}
+
+// Rename moves a file, symlink or directory with everything it contains
+func (fs *MockFileSystem) Rename(oldpath, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Normalize paths
+	oldpath = filepath.Clean(oldpath)
+	newpath = filepath.Clean(newpath)
+
+	if _, exists := fs.fileInfos[oldpath]; !exists {
+		return os.ErrNotExist
+	}
+	dir := filepath.Dir(newpath)
+	if dir != "." && dir != "/" && !fs.dirs[dir] {
+		return os.ErrNotExist
+	}
+
+	moved := func(p string) (string, bool) {
+		if p == oldpath {
+			return newpath, true
+		}
+		if rest, ok := strings.CutPrefix(p, oldpath+"/"); ok {
+			return filepath.Join(newpath, rest), true
+		}
+		return "", false
+	}
+	for p, content := range fs.files {
+		if target, ok := moved(p); ok {
+			delete(fs.files, p)
+			fs.files[target] = content
+		}
+	}
+	for p := range fs.dirs {
+		if target, ok := moved(p); ok {
+			delete(fs.dirs, p)
+			fs.dirs[target] = true
+		}
+	}
+	for p, linkTarget := range fs.links {
+		if target, ok := moved(p); ok {
+			delete(fs.links, p)
+			fs.links[target] = linkTarget
+		}
+	}
+	for p, info := range fs.fileInfos {
+		if target, ok := moved(p); ok {
+			delete(fs.fileInfos, p)
+			fs.fileInfos[target] = info
+		}
+	}
+	fs.fileInfos[newpath].name = filepath.Base(newpath)
+	return nil
+}
+
+// Chmod changes the permission bits of a file or directory
+func (fs *MockFileSystem) Chmod(name string, mode os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	info, exists := fs.fileInfos[filepath.Clean(name)]
+	if !exists {
+		return os.ErrNotExist
+	}
+	info.mode = info.mode&os.ModeType | mode.Perm()
+	return nil
+}
+
+// Chtimes changes the modification time of a file or directory
+func (fs *MockFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	info, exists := fs.fileInfos[filepath.Clean(name)]
+	if !exists {
+		return os.ErrNotExist
+	}
+	info.modTime = mtime
+	return nil
+}
+
 // ReadFile reads a file
 func (fs *MockFileSystem) ReadFile(filename string) ([]byte, error) {
 	fs.mu.RLock()